@@ -1,22 +1,64 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"flag"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"quiz-app/internal/routes"
-	"quiz-app/internal/storage"
+	"quiz-app/internal/config"
+	"quiz-app/internal/server"
 )
 
 func main() {
-	// Initialize storage
-	store := storage.NewMemoryStorage()
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Initialize router
-	router := routes.SetupRoutes(store)
+	// SIGINT or SIGTERM starts a graceful shutdown; a second signal kills
+	// the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-	// Start server
-	fmt.Println("Server is running on :8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	orgs, err := server.NewDirectory(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	authn, err := server.NewAuthenticator(ctx, cfg, orgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if authn == nil {
+		log.Println("Warning: no API keys, token keys or OIDC provider are configured, so every request is allowed")
+	}
+
+	throttle, err := server.NewThrottle(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	events, err := server.OpenAuditLog(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := server.OpenStorage(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := server.New(cfg, store, authn, orgs, throttle, events).ListenAndServe(ctx); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Server stopped")
 }
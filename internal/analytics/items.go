@@ -0,0 +1,143 @@
+// Package analytics computes psychometric statistics from quiz results.
+package analytics
+
+import "quiz-app/internal/models"
+
+// OptionStats describes how often one option of a choice question was
+// picked. NeverChosen flags distractors that attract nobody.
+type OptionStats struct {
+	Index       int     `json:"index"`
+	Text        string  `json:"text"`
+	Count       int     `json:"count"`
+	Rate        float64 `json:"rate"`
+	IsCorrect   bool    `json:"is_correct"`
+	NeverChosen bool    `json:"never_chosen"`
+}
+
+// ItemStats is the item analysis of one question.
+//
+// Difficulty is the classical p-value: the mean share of the marks earned by
+// the users who were given the question, counting unanswered as nothing, so
+// higher means easier. Discrimination is the point-biserial correlation of
+// that item score with each user's total score on the rest of the quiz.
+// Either is nil when there is not enough data to compute it.
+type ItemStats struct {
+	QuestionID     string              `json:"question_id"`
+	Kind           models.QuestionKind `json:"kind"`
+	Presented      int                 `json:"presented"`
+	Answered       int                 `json:"answered"`
+	Difficulty     *float64            `json:"p_value,omitempty"`
+	Discrimination *float64            `json:"discrimination,omitempty"`
+	Options        []OptionStats       `json:"options,omitempty"`
+}
+
+// Items analyses every question of quiz from the results of its users, in
+// the quiz's question order. For randomized quizzes a user only counts
+// towards the questions on their own paper.
+func Items(quiz *models.Quiz, results []models.Result) []ItemStats {
+	papers := make([]*models.Paper, len(results))
+	for i := range results {
+		papers[i] = quiz.PaperFor(results[i].UserID)
+	}
+
+	items := make([]ItemStats, len(quiz.Questions))
+	for qi := range quiz.Questions {
+		question := &quiz.Questions[qi]
+		kind := question.EffectiveKind()
+		item := ItemStats{QuestionID: question.ID, Kind: kind}
+
+		isChoice := kind == models.KindSingleChoice || kind == models.KindMultipleSelect
+		var counts []int
+		if isChoice {
+			counts = make([]int, len(question.Options))
+		}
+
+		var scores, rest []float64
+		for i := range results {
+			if papers[i].Question(question.ID) == nil {
+				continue
+			}
+			item.Presented++
+			score, earned := 0.0, 0.0
+			if answer, ok := results[i].Answers[question.ID]; ok {
+				item.Answered++
+				earned = float64(answer.Score)
+				score = itemScore(question, answer)
+				if isChoice {
+					countSelections(counts, kind, answer)
+				}
+			}
+			scores = append(scores, score)
+			rest = append(rest, float64(results[i].Score)-earned)
+		}
+
+		if item.Presented > 0 {
+			p := mean(scores)
+			item.Difficulty = &p
+		}
+		if r, ok := correlation(scores, rest); ok {
+			item.Discrimination = &r
+		}
+		if isChoice {
+			item.Options = optionStats(question, counts, item.Answered)
+		}
+		items[qi] = item
+	}
+	return items
+}
+
+// itemScore is the share of the question's marks an answer earned, between
+// 0 and 1. Penalties for wrong answers count as 0.
+func itemScore(question *models.Question, answer models.Answer) float64 {
+	if question.Marks <= 0 {
+		if answer.IsCorrect {
+			return 1
+		}
+		return 0
+	}
+	score := float64(answer.Score) / float64(question.Marks)
+	if score < 0 {
+		return 0
+	}
+	return min(score, 1)
+}
+
+// countSelections adds the options picked in answer to counts, ignoring
+// indexes that do not name an option
+func countSelections(counts []int, kind models.QuestionKind, answer models.Answer) {
+	selected := []int{answer.SelectedOption}
+	if kind == models.KindMultipleSelect {
+		selected = answer.SelectedOptions
+	}
+	for _, option := range selected {
+		if option >= 0 && option < len(counts) {
+			counts[option]++
+		}
+	}
+}
+
+func optionStats(question *models.Question, counts []int, answered int) []OptionStats {
+	correct := make(map[int]bool)
+	if question.EffectiveKind() == models.KindMultipleSelect {
+		for _, option := range question.CorrectOptions {
+			correct[option] = true
+		}
+	} else {
+		correct[question.CorrectOption] = true
+	}
+
+	options := make([]OptionStats, len(question.Options))
+	for i, text := range question.Options {
+		options[i] = OptionStats{
+			Index:       i,
+			Text:        text,
+			Count:       counts[i],
+			IsCorrect:   correct[i],
+			NeverChosen: counts[i] == 0,
+		}
+		if answered > 0 {
+			options[i].Rate = float64(counts[i]) / float64(answered)
+		}
+	}
+	return options
+}
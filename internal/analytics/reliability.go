@@ -0,0 +1,171 @@
+package analytics
+
+import (
+	"math"
+
+	"quiz-app/internal/models"
+)
+
+// Bin is one bar of a score histogram, counting scores in [From, To). The
+// last bin also includes its upper bound.
+type Bin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+// QuizStats summarises the scores of a quiz and how reliably it measures.
+//
+// Mean, StdDev, Min, Max and Histogram describe the users' total scores.
+// Reliability is estimated from the questions every user was given: KR20
+// is reported when every item was scored right or wrong, and Cronbach's
+// Alpha, its generalisation to partial credit, always. SEM is the standard
+// error of measurement derived from KR20 when there is one, otherwise Alpha,
+// and is on the scale of the totals that estimate was computed from: the
+// number of those questions answered right for KR20, or the marks earned on
+// them, without penalties, for Alpha. The estimates are nil when there are
+// too few users or items.
+type QuizStats struct {
+	Respondents int      `json:"respondents"`
+	Items       int      `json:"items"`
+	Mean        float64  `json:"mean"`
+	StdDev      float64  `json:"std_dev"`
+	Min         float64  `json:"min"`
+	Max         float64  `json:"max"`
+	KR20        *float64 `json:"kr20,omitempty"`
+	Alpha       *float64 `json:"cronbach_alpha,omitempty"`
+	SEM         *float64 `json:"sem,omitempty"`
+	Histogram   []Bin    `json:"histogram"`
+}
+
+// Summarize computes the quiz statistics from its results, with a histogram
+// of the given number of equal-width bins
+func Summarize(quiz *models.Quiz, results []models.Result, bins int) QuizStats {
+	stats := QuizStats{Respondents: len(results)}
+
+	totals := make([]float64, len(results))
+	maxMarks := 0
+	papers := make([]*models.Paper, len(results))
+	for i := range results {
+		totals[i] = float64(results[i].Score)
+		papers[i] = quiz.PaperFor(results[i].UserID)
+		maxMarks = max(maxMarks, totalMarks(papers[i].Quiz))
+	}
+	if len(results) == 0 {
+		maxMarks = totalMarks(quiz)
+	}
+
+	if len(totals) > 0 {
+		stats.Mean = mean(totals)
+		stats.StdDev = math.Sqrt(variance(totals))
+		stats.Min, stats.Max = totals[0], totals[0]
+		for _, total := range totals {
+			stats.Min, stats.Max = math.Min(stats.Min, total), math.Max(stats.Max, total)
+		}
+	}
+	stats.Histogram = histogram(totals, math.Min(0, stats.Min), math.Max(float64(maxMarks), stats.Max), bins)
+
+	// Item scores of the questions every user was given, one column per item
+	var earned, credit [][]float64
+	dichotomous := true
+	for qi := range quiz.Questions {
+		question := &quiz.Questions[qi]
+		var e, c []float64
+		for i := range results {
+			if papers[i].Question(question.ID) == nil {
+				break
+			}
+			answer, ok := results[i].Answers[question.ID]
+			if !ok {
+				e, c = append(e, 0), append(c, 0)
+				continue
+			}
+			score := itemScore(question, answer)
+			dichotomous = dichotomous && (score == 0 || score == 1)
+			e, c = append(e, math.Max(float64(answer.Score), 0)), append(c, score)
+		}
+		if len(results) > 0 && len(e) == len(results) {
+			earned, credit = append(earned, e), append(credit, c)
+		}
+	}
+	stats.Items = len(earned)
+
+	reliability, ok := cronbachAlpha(earned)
+	if ok {
+		stats.Alpha = &reliability
+	}
+	basis := earned
+	if dichotomous {
+		// For right-or-wrong items alpha on 0/1 scores is exactly KR-20
+		if kr20, ok := cronbachAlpha(credit); ok {
+			stats.KR20 = &kr20
+			reliability, basis = kr20, credit
+		}
+	}
+	if stats.Alpha != nil {
+		sem := math.Sqrt(variance(itemTotals(basis))) * math.Sqrt(math.Max(0, 1-reliability))
+		stats.SEM = &sem
+	}
+	return stats
+}
+
+// cronbachAlpha estimates reliability from item score columns:
+// k/(k-1) * (1 - sum of item variances / variance of totals)
+func cronbachAlpha(items [][]float64) (float64, bool) {
+	k := len(items)
+	if k < 2 || len(items[0]) < 2 {
+		return 0, false
+	}
+	itemVariance := 0.0
+	for _, item := range items {
+		itemVariance += variance(item)
+	}
+	totalVariance := variance(itemTotals(items))
+	if totalVariance == 0 {
+		return 0, false
+	}
+	return float64(k) / float64(k-1) * (1 - itemVariance/totalVariance), true
+}
+
+// itemTotals sums item score columns into each user's total
+func itemTotals(items [][]float64) []float64 {
+	if len(items) == 0 {
+		return nil
+	}
+	totals := make([]float64, len(items[0]))
+	for _, item := range items {
+		for i, score := range item {
+			totals[i] += score
+		}
+	}
+	return totals
+}
+
+// histogram counts values into n equal-width bins spanning [lo, hi]
+func histogram(values []float64, lo, hi float64, n int) []Bin {
+	if n < 1 {
+		n = 1
+	}
+	if hi <= lo {
+		hi = lo + 1
+	}
+	width := (hi - lo) / float64(n)
+	bins := make([]Bin, n)
+	for i := range bins {
+		bins[i] = Bin{From: lo + float64(i)*width, To: lo + float64(i+1)*width}
+	}
+	bins[n-1].To = hi
+	for _, v := range values {
+		i := int((v - lo) / width)
+		bins[min(max(i, 0), n-1)].Count++
+	}
+	return bins
+}
+
+func totalMarks(quiz *models.Quiz) int {
+	total := 0
+	for _, q := range quiz.Questions {
+		total += q.Marks
+	}
+	return total
+}
@@ -0,0 +1,45 @@
+package analytics
+
+import "math"
+
+// mean returns the arithmetic mean of xs, or 0 if xs is empty
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// variance returns the population variance of xs
+func variance(xs []float64) float64 {
+	m := mean(xs)
+	sum := 0.0
+	for _, x := range xs {
+		sum += (x - m) * (x - m)
+	}
+	if len(xs) == 0 {
+		return 0
+	}
+	return sum / float64(len(xs))
+}
+
+// correlation returns the Pearson correlation of xs and ys. ok is false when
+// either has no variance, in which case the correlation is undefined.
+func correlation(xs, ys []float64) (r float64, ok bool) {
+	mx, my := mean(xs), mean(ys)
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+	return sxy / math.Sqrt(sxx*syy), true
+}
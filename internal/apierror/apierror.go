@@ -0,0 +1,53 @@
+// Package apierror writes the JSON envelope every endpoint uses to report
+// an error:
+//
+//	{"error": {"code": "not_found", "message": "Quiz not found"}}
+//
+// Code is derived from the status so clients can branch on it without
+// parsing messages. Details, when present, lists individual problems such
+// as invalid fields or lines of an imported document.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Body is the error object inside the envelope
+type Body struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Envelope is the document written for every error response
+type Envelope struct {
+	Error Body `json:"error"`
+}
+
+// Write writes an error response with the given status and message
+func Write(w http.ResponseWriter, status int, message string) {
+	WriteDetails(w, status, message, nil)
+}
+
+// WriteDetails writes an error response that also lists individual problems
+func WriteDetails(w http.ResponseWriter, status int, message string, details interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Envelope{Error: Body{
+		Code:    Code(status),
+		Message: message,
+		Details: details,
+	}})
+}
+
+// Code returns the machine-readable code for a status, such as "not_found"
+func Code(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
@@ -0,0 +1,198 @@
+// Package audit keeps an append-only record of the changes made to quizzes
+// and of every answer submitted, so that a disputed score can be traced
+// back to the requests that produced it. Events are never changed once
+// written: a Log only appends and reads.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Action is the kind of change an event records
+type Action string
+
+const (
+	ActionQuizCreate   Action = "quiz.create"
+	ActionQuizUpdate   Action = "quiz.update"
+	ActionQuizDelete   Action = "quiz.delete"
+	ActionAnswerSubmit Action = "answer.submit"
+)
+
+// Event records one change, or attempted change, and who asked for it.
+// BeforeHash and AfterHash fingerprint the quiz or result the action
+// touched, so that the stored state can be matched to the event history;
+// they are empty when there was nothing stored. Error is set when the
+// change was refused.
+type Event struct {
+	Seq        int64       `json:"seq"`
+	Time       time.Time   `json:"time"`
+	Org        string      `json:"org"`
+	Actor      string      `json:"actor,omitempty"`
+	ActorRole  string      `json:"actor_role,omitempty"`
+	RequestID  string      `json:"request_id,omitempty"`
+	Action     Action      `json:"action"`
+	QuizID     string      `json:"quiz_id"`
+	UserID     string      `json:"user_id,omitempty"`
+	QuestionID string      `json:"question_id,omitempty"`
+	Submission *Submission `json:"submission,omitempty"`
+	Outcome    *Outcome    `json:"outcome,omitempty"`
+	BeforeHash string      `json:"before_hash,omitempty"`
+	AfterHash  string      `json:"after_hash,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// Submission is an answer as the user submitted it
+type Submission struct {
+	SelectedOption  int      `json:"selected_option"`
+	SelectedOptions []int    `json:"selected_options,omitempty"`
+	NumericValue    *float64 `json:"numeric_value,omitempty"`
+	Text            string   `json:"text,omitempty"`
+}
+
+// Outcome is how a submitted answer was graded. QuestionScore is the
+// score of the answer the result keeps for the question, which under the
+// best-answer policy may be an earlier one, and Score the user's total
+// afterwards.
+type Outcome struct {
+	Correct       bool    `json:"correct"`
+	CorrectAnswer string  `json:"correct_answer,omitempty"`
+	QuestionScore float32 `json:"question_score"`
+	Score         float32 `json:"score"`
+}
+
+// Filter selects events for Query. Empty fields match everything.
+type Filter struct {
+	Org       string
+	Actor     string
+	Action    Action
+	QuizID    string
+	UserID    string
+	RequestID string
+	Since     time.Time // events at or after
+	Until     time.Time // events before
+	Offset    int
+	Limit     int // zero means no limit
+}
+
+// Matches reports whether event passes the filter's conditions
+func (f *Filter) Matches(event *Event) bool {
+	switch {
+	case f.Org != "" && event.Org != f.Org,
+		f.Actor != "" && event.Actor != f.Actor,
+		f.Action != "" && event.Action != f.Action,
+		f.QuizID != "" && event.QuizID != f.QuizID,
+		f.UserID != "" && event.UserID != f.UserID,
+		f.RequestID != "" && event.RequestID != f.RequestID,
+		!f.Since.IsZero() && event.Time.Before(f.Since),
+		!f.Until.IsZero() && !event.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Log stores events. Append numbers the event and, unless it is set,
+// stamps its time. Query returns the page of events matching filter in
+// the order they were appended, along with the total number of matches.
+type Log interface {
+	Append(ctx context.Context, event *Event) error
+	Query(ctx context.Context, filter Filter) ([]Event, int, error)
+	Close() error
+}
+
+// Memory is a Log kept in memory. Events are held encoded, so that nothing
+// a caller does with an event it appended or read can change the log.
+type Memory struct {
+	lines [][]byte
+	now   func() time.Time
+	mu    sync.RWMutex
+}
+
+// NewMemory creates an empty in-memory log
+func NewMemory() *Memory {
+	return &Memory{now: time.Now}
+}
+
+// SetClock replaces the clock events are stamped with, for tests
+func (m *Memory) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+func (m *Memory) Append(ctx context.Context, event *Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	line, err := stamp(event, int64(len(m.lines))+1, m.now)
+	if err != nil {
+		return err
+	}
+	m.lines = append(m.lines, line)
+	return nil
+}
+
+func (m *Memory) Query(ctx context.Context, filter Filter) ([]Event, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p := page{filter: filter, events: []Event{}}
+	for _, line := range m.lines {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		if err := p.add(line); err != nil {
+			return nil, 0, err
+		}
+	}
+	return p.events, p.total, nil
+}
+
+// Close does nothing: the log is lost with the process
+func (m *Memory) Close() error {
+	return nil
+}
+
+// stamp numbers event as seq, sets its time if unset and encodes it
+func stamp(event *Event, seq int64, now func() time.Time) ([]byte, error) {
+	event.Seq = seq
+	if event.Time.IsZero() {
+		event.Time = now().UTC()
+	}
+	return json.Marshal(event)
+}
+
+// page collects the page of events matching a filter and counts every match
+type page struct {
+	filter Filter
+	events []Event
+	total  int
+}
+
+// add decodes an encoded event and keeps it if it belongs on the page
+func (p *page) add(line []byte) error {
+	var event Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		return err
+	}
+	if !p.filter.Matches(&event) {
+		return nil
+	}
+	p.total++
+	if p.total > p.filter.Offset && (p.filter.Limit == 0 || len(p.events) < p.filter.Limit) {
+		p.events = append(p.events, event)
+	}
+	return nil
+}
+
+// Hash fingerprints a stored quiz or result by its JSON encoding
+func Hash(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
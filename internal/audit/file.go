@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// File is a Log kept in a file of newline-delimited JSON, one event per
+// line. The file is only ever appended to, and each event is synced to
+// disk before Append returns. Queries read the file from the start, up to
+// the end of the last event appended when they began, so that they neither
+// see a half-written event nor hold up appends while they read.
+type File struct {
+	path string
+	f    *os.File
+	seq  int64
+	size int64 // length of the file after the last append
+	now  func() time.Time
+	mu   sync.Mutex
+}
+
+// OpenFile opens the log at path, creating it if it does not exist.
+// Numbering continues from the last event already in the file.
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	l := &File{path: path, f: f, size: info.Size(), now: time.Now}
+	err = l.scan(context.Background(), l.size, func(line []byte) error {
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		l.seq = event.Seq
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetClock replaces the clock events are stamped with, for tests
+func (l *File) SetClock(now func() time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.now = now
+}
+
+func (l *File) Append(ctx context.Context, event *Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	line, err := stamp(event, l.seq+1, l.now)
+	if err != nil {
+		return err
+	}
+	n, err := l.f.Write(append(line, '\n'))
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	l.seq = event.Seq
+	return nil
+}
+
+func (l *File) Query(ctx context.Context, filter Filter) ([]Event, int, error) {
+	l.mu.Lock()
+	size := l.size
+	l.mu.Unlock()
+	p := page{filter: filter, events: []Event{}}
+	if err := l.scan(ctx, size, p.add); err != nil {
+		return nil, 0, err
+	}
+	return p.events, p.total, nil
+}
+
+// Close closes the file
+func (l *File) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// scan calls fn with each line in the first size bytes of the file in turn
+func (l *File) scan(ctx context.Context, size int64, fn func(line []byte) error) error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(io.LimitReader(f, size))
+	for n := 1; ; n++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("audit: %w", err)
+		}
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("audit: %s: line %d: %w", l.path, n, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"context"
+	"log"
+
+	"quiz-app/internal/auth"
+	"quiz-app/internal/middleware"
+	"quiz-app/internal/models"
+	"quiz-app/internal/storage"
+	"quiz-app/internal/tenant"
+)
+
+// Store records the quiz changes and answers made through a storage in a
+// log. It is a storage.Storage itself, so that every caller of the storage
+// is audited without knowing it.
+//
+// The hashes before and after a change are taken by reading the storage
+// around the call, so a change made concurrently by another request can
+// show up in them. An event that cannot be appended is logged and the
+// request goes ahead: the change has already been made by then.
+type Store struct {
+	storage.Storage
+	log Log
+}
+
+// Wrap returns store with its changes recorded in events
+func Wrap(store storage.Storage, events Log) *Store {
+	return &Store{Storage: store, log: events}
+}
+
+func (s *Store) CreateQuiz(ctx context.Context, quiz *models.Quiz) error {
+	event := newEvent(ctx, ActionQuizCreate, quiz.ID)
+	err := s.Storage.CreateQuiz(ctx, quiz)
+	if err == nil {
+		event.AfterHash = s.quizHash(ctx, quiz.ID)
+	}
+	s.append(ctx, event, err)
+	return err
+}
+
+func (s *Store) UpdateQuiz(ctx context.Context, quiz *models.Quiz) error {
+	event := newEvent(ctx, ActionQuizUpdate, quiz.ID)
+	event.BeforeHash = s.quizHash(ctx, quiz.ID)
+	err := s.Storage.UpdateQuiz(ctx, quiz)
+	if err == nil {
+		event.AfterHash = s.quizHash(ctx, quiz.ID)
+	}
+	s.append(ctx, event, err)
+	return err
+}
+
+func (s *Store) DeleteQuiz(ctx context.Context, id string, archiveResults bool) error {
+	event := newEvent(ctx, ActionQuizDelete, id)
+	event.BeforeHash = s.quizHash(ctx, id)
+	err := s.Storage.DeleteQuiz(ctx, id, archiveResults)
+	if err != nil {
+		event.AfterHash = event.BeforeHash
+	}
+	s.append(ctx, event, err)
+	return err
+}
+
+// SubmitAnswer records the answer, how it was graded and the result it
+// left the user with. The hashes are of the user's result.
+func (s *Store) SubmitAnswer(ctx context.Context, quizID, userID string, answer *models.Answer) (bool, string, error) {
+	event := newEvent(ctx, ActionAnswerSubmit, quizID)
+	event.UserID = userID
+	event.QuestionID = answer.QuestionID
+	event.Submission = &Submission{
+		SelectedOption:  answer.SelectedOption,
+		SelectedOptions: append([]int(nil), answer.SelectedOptions...),
+		NumericValue:    answer.NumericValue,
+		Text:            answer.Text,
+	}
+	before, _ := s.result(ctx, quizID, userID)
+	if before != nil {
+		event.BeforeHash = Hash(before)
+	}
+
+	correct, correctAnswer, err := s.Storage.SubmitAnswer(ctx, quizID, userID, answer)
+	if err == nil {
+		event.Outcome = &Outcome{Correct: correct, CorrectAnswer: correctAnswer}
+		if after, _ := s.result(ctx, quizID, userID); after != nil {
+			event.AfterHash = Hash(after)
+			event.Outcome.QuestionScore = after.Answers[answer.QuestionID].Score
+			event.Outcome.Score = after.Score
+		}
+	} else {
+		event.AfterHash = event.BeforeHash
+	}
+	s.append(ctx, event, err)
+	return correct, correctAnswer, err
+}
+
+// newEvent starts an event for an action by the caller of ctx
+func newEvent(ctx context.Context, action Action, quizID string) *Event {
+	event := &Event{
+		Org:       tenant.FromContext(ctx).ID,
+		RequestID: middleware.RequestIDFrom(ctx),
+		Action:    action,
+		QuizID:    quizID,
+	}
+	if principal, ok := auth.FromContext(ctx); ok {
+		event.Actor = principal.Subject
+		event.ActorRole = string(principal.Role)
+	}
+	return event
+}
+
+// append records event, with err as its error if the change failed. The
+// event is recorded even if the request has been cancelled meanwhile.
+func (s *Store) append(ctx context.Context, event *Event, err error) {
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if err := s.log.Append(context.WithoutCancel(ctx), event); err != nil {
+		log.Printf("audit: failed to record %s of quiz %s: %v", event.Action, event.QuizID, err)
+	}
+}
+
+// quizHash returns the hash of the stored quiz, or "" if there is none
+func (s *Store) quizHash(ctx context.Context, id string) string {
+	quiz, err := s.Storage.GetQuiz(context.WithoutCancel(ctx), id)
+	if err != nil {
+		return ""
+	}
+	return Hash(quiz)
+}
+
+func (s *Store) result(ctx context.Context, quizID, userID string) (*models.Result, error) {
+	return s.Storage.GetResults(context.WithoutCancel(ctx), quizID, userID)
+}
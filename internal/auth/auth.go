@@ -0,0 +1,337 @@
+// Package auth identifies the caller of a request from an API key or a
+// signed JWT and decides what their role allows them to do.
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"quiz-app/internal/apierror"
+	"quiz-app/internal/oidc"
+	"quiz-app/internal/tenant"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Role is what a caller is allowed to do
+type Role string
+
+const (
+	// RoleAdmin may do anything
+	RoleAdmin Role = "admin"
+	// RoleAuthor may create and edit quizzes and see everyone's results
+	RoleAuthor Role = "author"
+	// RoleTaker may answer quizzes and see their own results
+	RoleTaker Role = "taker"
+)
+
+// APIKeyHeader is the request header that carries an API key
+const APIKeyHeader = "X-API-Key"
+
+var (
+	// ErrUnauthenticated is returned when a request has no valid credentials
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden is returned when the caller's role does not allow a request
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Principal is the authenticated caller of a request. Org is the
+// organization the caller belongs to; callers without one belong to the
+// default organization, except admins, who may act in every organization.
+type Principal struct {
+	Subject string
+	Role    Role
+	Org     string
+}
+
+// APIKey grants a fixed principal to whoever presents Key
+type APIKey struct {
+	Key       string
+	Principal Principal
+}
+
+// ParseAPIKeys reads API keys written as comma-separated subject:role:key
+// entries. The key is everything after the second colon. A subject written
+// as org/subject belongs to that organization.
+func ParseAPIKeys(s string) ([]APIKey, error) {
+	var keys []APIKey
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("auth: API key entry %q is not subject:role:key", entry)
+		}
+		principal := Principal{Subject: parts[0], Role: Role(parts[1])}
+		if org, subject, ok := strings.Cut(parts[0], "/"); ok {
+			principal.Org, principal.Subject = org, subject
+		}
+		keys = append(keys, APIKey{Key: parts[2], Principal: principal})
+	}
+	return keys, nil
+}
+
+// Options configures an Authenticator. Tokens signed with HS256 are
+// accepted when HMACSecret is set and RS256 when RSAPublicKey is set.
+// Issuer and Audience, when set, must match the token's claims.
+//
+// With OIDC set, users can also sign in with an OpenID Connect provider
+// and ID tokens from it are accepted. Users whose ID token grants no known
+// role get OIDCDefaultRole, which defaults to taker.
+//
+// Orgs holds the organizations callers may belong to; nil means only the
+// default organization.
+type Options struct {
+	APIKeys         []APIKey
+	HMACSecret      []byte
+	RSAPublicKey    *rsa.PublicKey
+	Issuer          string
+	Audience        string
+	OIDC            *oidc.RelyingParty
+	OIDCDefaultRole Role
+	Orgs            *tenant.Directory
+}
+
+// Claims are the JWT claims read by the Authenticator. The standard
+// subject claim names the user, the role claim their role and the org
+// claim their organization.
+type Claims struct {
+	Role Role   `json:"role"`
+	Org  string `json:"org,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Authenticator checks the credentials of requests
+type Authenticator struct {
+	apiKeys map[[sha256.Size]byte]Principal
+	opts    Options
+	methods []string
+}
+
+// New creates an Authenticator, or returns an error if opts accepts no
+// credentials at all
+func New(opts Options) (*Authenticator, error) {
+	a := &Authenticator{apiKeys: make(map[[sha256.Size]byte]Principal), opts: opts}
+	for _, key := range opts.APIKeys {
+		if key.Key == "" {
+			return nil, errors.New("auth: empty API key")
+		}
+		if err := key.Principal.validate(); err != nil {
+			return nil, fmt.Errorf("auth: API key for %q: %w", key.Principal.Subject, err)
+		}
+		// Keys are looked up by hash so that the lookup does not leak
+		// through timing how much of a guessed key was right
+		a.apiKeys[sha256.Sum256([]byte(key.Key))] = key.Principal
+	}
+	if len(opts.HMACSecret) > 0 {
+		a.methods = append(a.methods, jwt.SigningMethodHS256.Alg())
+	}
+	if opts.RSAPublicKey != nil {
+		a.methods = append(a.methods, jwt.SigningMethodRS256.Alg())
+	}
+	if a.opts.OIDCDefaultRole == "" {
+		a.opts.OIDCDefaultRole = RoleTaker
+	}
+	if err := (Principal{Subject: "oidc", Role: a.opts.OIDCDefaultRole}).validate(); err != nil {
+		return nil, fmt.Errorf("auth: OIDC default role: %w", err)
+	}
+	if len(a.apiKeys) == 0 && len(a.methods) == 0 && opts.OIDC == nil {
+		return nil, errors.New("auth: no API keys, token keys or OIDC provider configured")
+	}
+	return a, nil
+}
+
+func (p Principal) validate() error {
+	if p.Subject == "" {
+		return errors.New("subject is required")
+	}
+	switch p.Role {
+	case RoleAdmin, RoleAuthor, RoleTaker:
+		return nil
+	}
+	return fmt.Errorf("unknown role %q", p.Role)
+}
+
+// Authenticate identifies the caller of r from its X-API-Key header, its
+// bearer token or its OIDC session cookie, in that order
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		principal, ok := a.apiKeys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown API key", ErrUnauthenticated)
+		}
+		return &principal, nil
+	}
+
+	var token string
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, value, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return nil, fmt.Errorf("%w: unsupported authorization scheme", ErrUnauthenticated)
+		}
+		token = value
+	} else if cookie, err := r.Cookie(SessionCookie); err == nil && a.opts.OIDC != nil {
+		token = cookie.Value
+	}
+	if token == "" {
+		return nil, fmt.Errorf("%w: no credentials", ErrUnauthenticated)
+	}
+	return a.parseToken(r.Context(), token)
+}
+
+func (a *Authenticator) parseToken(ctx context.Context, token string) (*Principal, error) {
+	if a.isOIDCToken(token) {
+		principal, err := a.verifyOIDCToken(ctx, token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+		}
+		return principal, nil
+	}
+	if len(a.methods) == 0 {
+		return nil, fmt.Errorf("%w: tokens are not accepted", ErrUnauthenticated)
+	}
+	opts := []jwt.ParserOption{jwt.WithValidMethods(a.methods), jwt.WithExpirationRequired()}
+	if a.opts.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.opts.Issuer))
+	}
+	if a.opts.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.opts.Audience))
+	}
+
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, a.key, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	principal := Principal{Subject: claims.Subject, Role: claims.Role, Org: claims.Org}
+	if err := principal.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	return &principal, nil
+}
+
+// key returns the key that verifies token. The parser has already checked
+// that its algorithm is one the Authenticator accepts.
+func (a *Authenticator) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return a.opts.HMACSecret, nil
+	case *jwt.SigningMethodRSA:
+		return a.opts.RSAPublicKey, nil
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+// Require wraps next so that only callers with one of roles may reach it.
+// Admins may reach everything. Callers without valid credentials get 401
+// and callers with the wrong role 403. The request acts within the
+// caller's organization, as decided by scope; naming an organization the
+// caller may not act in is also 403.
+//
+// A nil Authenticator disables authentication: every request reaches next
+// with no principal, and handlers trust the user named in the request.
+func (a *Authenticator) Require(roles ...Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if a == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := a.Authenticate(r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="quiz"`)
+				apierror.Write(w, http.StatusUnauthorized, "Valid credentials are required")
+				return
+			}
+			org, principal, err := a.scope(r, principal)
+			if err != nil {
+				apierror.Write(w, http.StatusForbidden, "You may not act in this organization")
+				return
+			}
+			if !principal.Is(roles...) {
+				apierror.Write(w, http.StatusForbidden, "Your role does not allow this request")
+				return
+			}
+			ctx := tenant.WithOrg(WithPrincipal(r.Context(), principal), org)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// scope returns the organization a request acts within and the principal
+// as it acts there. That is the organization named in the request path,
+// or else the caller's own. Callers may only act in their own
+// organization, except admins who belong to none. A caller listed as an
+// admin of their organization acts as an admin in it.
+func (a *Authenticator) scope(r *http.Request, principal *Principal) (*tenant.Org, *Principal, error) {
+	home := principal.Org
+	if home == "" {
+		home = tenant.DefaultID
+	}
+	id := tenant.Requested(r)
+	switch {
+	case id == "":
+		id = home
+	case id != home && !(principal.Role == RoleAdmin && principal.Org == ""):
+		return nil, nil, ErrForbidden
+	}
+	org, ok := a.opts.Orgs.Lookup(id)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", tenant.ErrUnknownOrg, id)
+	}
+	if id == home && org.IsAdmin(principal.Subject) {
+		principal = &Principal{Subject: principal.Subject, Role: RoleAdmin, Org: org.ID}
+	}
+	return org, principal, nil
+}
+
+// Is reports whether the principal has one of roles. Admins have every role.
+func (p *Principal) Is(roles ...Role) bool {
+	if p.Role == RoleAdmin {
+		return true
+	}
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context that carries principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal of the request ctx belongs to. It
+// returns false when authentication is disabled.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// UserFor returns the user a request acts for, given the user it names.
+// Takers always act for themselves: naming anyone else is forbidden and
+// naming nobody means the token's subject. Authors and admins act for the
+// user named, or themselves if none is. Without authentication the named
+// user is trusted.
+func UserFor(ctx context.Context, named string) (string, error) {
+	principal, ok := FromContext(ctx)
+	switch {
+	case !ok:
+		return named, nil
+	case named == "":
+		return principal.Subject, nil
+	case named != principal.Subject && !principal.Is(RoleAuthor):
+		return "", ErrForbidden
+	}
+	return named, nil
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"quiz-app/internal/apierror"
+	"quiz-app/internal/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SessionCookie holds the ID token of a user who signed in with OIDC, so
+// that browsers are authenticated without sending a bearer token
+const SessionCookie = "quiz_session"
+
+// OIDCEnabled reports whether users can sign in with an OIDC provider
+func (a *Authenticator) OIDCEnabled() bool {
+	return a != nil && a.opts.OIDC != nil
+}
+
+// Login starts signing in with the OIDC provider
+func (a *Authenticator) Login(w http.ResponseWriter, r *http.Request) {
+	if err := a.opts.OIDC.Login(w, r); err != nil {
+		log.Printf("OIDC sign-in refused: %v", err)
+		w.Header().Set("Retry-After", "60")
+		apierror.Write(w, http.StatusServiceUnavailable, "Too many sign-ins in progress")
+	}
+}
+
+// Callback completes signing in with the OIDC provider. The ID token is
+// stored in the session cookie and also returned, for clients that send
+// it as a bearer token instead.
+func (a *Authenticator) Callback(w http.ResponseWriter, r *http.Request) {
+	identity, rawIDToken, err := a.opts.OIDC.Callback(w, r)
+	if errors.Is(err, oidc.ErrLoginState) {
+		apierror.Write(w, http.StatusBadRequest, "Sign-in was not started from this browser or has expired")
+		return
+	}
+	if err != nil {
+		log.Printf("OIDC sign-in failed: %v", err)
+		apierror.Write(w, http.StatusUnauthorized, "Sign-in failed")
+		return
+	}
+	principal := a.oidcPrincipal(identity)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    rawIDToken,
+		Path:     "/",
+		Expires:  identity.Expiry,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"user_id":    principal.Subject,
+		"role":       principal.Role,
+		"id_token":   rawIDToken,
+		"expires_at": identity.Expiry.UTC().Format(time.RFC3339),
+	}
+	if principal.Org != "" {
+		response["org"] = principal.Org
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Logout clears the session cookie
+func (a *Authenticator) Logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isOIDCToken reports whether token claims to be an ID token from the OIDC
+// provider. The claim is only used to pick a verifier.
+func (a *Authenticator) isOIDCToken(token string) bool {
+	if a.opts.OIDC == nil {
+		return false
+	}
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil {
+		return false
+	}
+	return claims.Issuer == a.opts.OIDC.Issuer()
+}
+
+func (a *Authenticator) verifyOIDCToken(ctx context.Context, token string) (*Principal, error) {
+	identity, err := a.opts.OIDC.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return a.oidcPrincipal(identity), nil
+}
+
+// oidcPrincipal maps a verified identity to a principal with the most
+// capable role the provider granted, or the default role if it granted
+// none this server knows
+func (a *Authenticator) oidcPrincipal(identity *oidc.Identity) *Principal {
+	role := a.opts.OIDCDefaultRole
+	for _, candidate := range []Role{RoleAdmin, RoleAuthor, RoleTaker} {
+		if contains(identity.Roles, string(candidate)) {
+			role = candidate
+			break
+		}
+	}
+	return &Principal{Subject: identity.Subject, Role: role, Org: identity.Org}
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
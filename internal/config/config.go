@@ -0,0 +1,388 @@
+// Package config loads the server's settings. Each setting has a default
+// and may be given in a config file, an environment variable or a flag;
+// later sources override earlier ones in that order.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings the server is started with
+type Config struct {
+	Addr            string
+	Storage         string // "memory" or "sqlite"
+	DBPath          string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	RequestTimeout  time.Duration
+	MaxBodyBytes    int64
+	TLSCertFile     string
+	TLSKeyFile      string
+
+	// Authentication is enabled when API keys or a token key are set
+	APIKeys          string // comma-separated subject:role:key entries
+	JWTSecret        string // HS256 signing secret
+	JWTPublicKeyFile string // PEM file with the RS256 public key
+	JWTIssuer        string
+	JWTAudience      string
+
+	// OIDC sign-in is enabled when an issuer is set
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       string // space-separated
+	OIDCUserClaim    string
+	OIDCRoleClaim    string
+	OIDCDefaultRole  string
+	OIDCOrgClaim     string
+
+	// Organizations beyond the default one, as parsed by tenant.ParseOrgs
+	Orgs string
+
+	// Rate limiting is enabled when route limits or an answer limit are set
+	RateLimits   string // comma-separated route=tokens/period entries
+	RateLimitKey string // "user", "api_key" or "ip"
+	AnswerLimit  string // tokens/period per user and question
+
+	// Audit events are kept in memory unless a file is set
+	AuditLog string
+}
+
+// Default returns the settings used when nothing else is configured
+func Default() *Config {
+	return &Config{
+		Addr:            ":8080",
+		Storage:         "memory",
+		DBPath:          "quiz.db",
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    30 * time.Second,
+		IdleTimeout:     2 * time.Minute,
+		ShutdownTimeout: 30 * time.Second,
+		RequestTimeout:  30 * time.Second,
+		MaxBodyBytes:    10 << 20,
+		OIDCScopes:      "openid profile email",
+		OIDCUserClaim:   "sub",
+		OIDCRoleClaim:   "role",
+		OIDCDefaultRole: "taker",
+		OIDCOrgClaim:    "org",
+		RateLimitKey:    "user",
+	}
+}
+
+// setting is one configurable value: its key in config files, its flag
+// name, the environment variable that sets it and how to parse it
+type setting struct {
+	key   string
+	flag  string
+	env   string
+	usage string
+	set   func(string) error
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"addr", "addr", "QUIZ_ADDR", "address to listen on", stringValue(&c.Addr)},
+		{"storage", "storage", "QUIZ_STORAGE", "storage backend: memory or sqlite", stringValue(&c.Storage)},
+		{"db_path", "db", "QUIZ_DB", "path to the SQLite database file (sqlite backend only)", stringValue(&c.DBPath)},
+		{"read_timeout", "read-timeout", "QUIZ_READ_TIMEOUT", "maximum time to read a request", durationValue(&c.ReadTimeout)},
+		{"write_timeout", "write-timeout", "QUIZ_WRITE_TIMEOUT", "maximum time to write a response", durationValue(&c.WriteTimeout)},
+		{"idle_timeout", "idle-timeout", "QUIZ_IDLE_TIMEOUT", "how long idle keep-alive connections are kept", durationValue(&c.IdleTimeout)},
+		{"shutdown_timeout", "shutdown-timeout", "QUIZ_SHUTDOWN_TIMEOUT", "how long to wait for in-flight requests on shutdown", durationValue(&c.ShutdownTimeout)},
+		{"request_timeout", "request-timeout", "QUIZ_REQUEST_TIMEOUT", "deadline for the storage calls of a request; 0 for none", durationValue(&c.RequestTimeout)},
+		{"max_body_bytes", "max-body-bytes", "QUIZ_MAX_BODY_BYTES", "largest request body accepted, in bytes", int64Value(&c.MaxBodyBytes)},
+		{"tls_cert_file", "tls-cert", "QUIZ_TLS_CERT", "TLS certificate file; serves HTTPS when set with -tls-key", stringValue(&c.TLSCertFile)},
+		{"tls_key_file", "tls-key", "QUIZ_TLS_KEY", "TLS private key file", stringValue(&c.TLSKeyFile)},
+		{"api_keys", "api-keys", "QUIZ_API_KEYS", "API keys as comma-separated subject:role:key entries", stringValue(&c.APIKeys)},
+		{"jwt_secret", "jwt-secret", "QUIZ_JWT_SECRET", "secret that verifies HS256 tokens", stringValue(&c.JWTSecret)},
+		{"jwt_public_key_file", "jwt-public-key", "QUIZ_JWT_PUBLIC_KEY", "PEM file with the public key that verifies RS256 tokens", stringValue(&c.JWTPublicKeyFile)},
+		{"jwt_issuer", "jwt-issuer", "QUIZ_JWT_ISSUER", "required issuer of tokens", stringValue(&c.JWTIssuer)},
+		{"jwt_audience", "jwt-audience", "QUIZ_JWT_AUDIENCE", "required audience of tokens", stringValue(&c.JWTAudience)},
+		{"oidc_issuer", "oidc-issuer", "QUIZ_OIDC_ISSUER", "OpenID Connect provider to sign users in with", stringValue(&c.OIDCIssuer)},
+		{"oidc_client_id", "oidc-client-id", "QUIZ_OIDC_CLIENT_ID", "client ID registered with the OIDC provider", stringValue(&c.OIDCClientID)},
+		{"oidc_client_secret", "oidc-client-secret", "QUIZ_OIDC_CLIENT_SECRET", "client secret registered with the OIDC provider", stringValue(&c.OIDCClientSecret)},
+		{"oidc_redirect_url", "oidc-redirect-url", "QUIZ_OIDC_REDIRECT_URL", "this server's /auth/callback URL as registered with the provider", stringValue(&c.OIDCRedirectURL)},
+		{"oidc_scopes", "oidc-scopes", "QUIZ_OIDC_SCOPES", "space-separated scopes to request", stringValue(&c.OIDCScopes)},
+		{"oidc_user_claim", "oidc-user-claim", "QUIZ_OIDC_USER_CLAIM", "ID token claim that names the user", stringValue(&c.OIDCUserClaim)},
+		{"oidc_role_claim", "oidc-role-claim", "QUIZ_OIDC_ROLE_CLAIM", "ID token claim that grants roles", stringValue(&c.OIDCRoleClaim)},
+		{"oidc_default_role", "oidc-default-role", "QUIZ_OIDC_DEFAULT_ROLE", "role of users whose ID token grants none", stringValue(&c.OIDCDefaultRole)},
+		{"oidc_org_claim", "oidc-org-claim", "QUIZ_OIDC_ORG_CLAIM", "ID token claim that names the user's organization", stringValue(&c.OIDCOrgClaim)},
+		{"orgs", "orgs", "QUIZ_ORGS", "organizations as comma-separated id:quizzes=N:attempts=N:admin=user entries", stringValue(&c.Orgs)},
+		{"rate_limits", "rate-limits", "QUIZ_RATE_LIMITS", `per-client route limits as comma-separated "METHOD /path=N/period" entries; * is any other route`, stringValue(&c.RateLimits)},
+		{"rate_limit_key", "rate-limit-key", "QUIZ_RATE_LIMIT_KEY", "what tells rate-limited clients apart: user, api_key or ip", stringValue(&c.RateLimitKey)},
+		{"answer_limit", "answer-limit", "QUIZ_ANSWER_LIMIT", "how often a user may answer one question, as N/period", stringValue(&c.AnswerLimit)},
+		{"audit_log", "audit-log", "QUIZ_AUDIT_LOG", "file to append audit events to as NDJSON; kept in memory if empty", stringValue(&c.AuditLog)},
+	}
+}
+
+// Load builds the configuration from the defaults, the config file named
+// by -config or QUIZ_CONFIG, the environment read through getenv and the
+// command-line args, in increasing order of precedence.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	// Flags are collected first but applied last, so that they override
+	// the file and environment
+	var flagged []func() error
+	fs, configPath := flagSet(settings, getenv("QUIZ_CONFIG"), func(s setting, value string) {
+		flagged = append(flagged, func() error { return s.set(value) })
+	})
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		values, err := readFile(*configPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range settings {
+			if value, ok := values[s.key]; ok {
+				if err := s.set(value); err != nil {
+					return nil, fmt.Errorf("%s: %s: %w", *configPath, s.key, err)
+				}
+				delete(values, s.key)
+			}
+		}
+		if len(values) > 0 {
+			return nil, fmt.Errorf("%s: unknown settings %s", *configPath, strings.Join(sortedKeys(values), ", "))
+		}
+	}
+
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	for _, apply := range flagged {
+		if err := apply(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Usage writes the command-line flags and their descriptions to w
+func Usage(w io.Writer) {
+	fs, _ := flagSet(Default().settings(), "", func(setting, string) {})
+	fs.SetOutput(w)
+	fmt.Fprintln(w, "Usage of server:")
+	fs.PrintDefaults()
+}
+
+// flagSet declares a flag for each setting, calling set with the values
+// given, and the -config flag
+func flagSet(settings []setting, configPath string, set func(setting, string)) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	path := fs.String("config", configPath, "YAML or TOML config file")
+	for _, s := range settings {
+		s := s
+		fs.Func(s.flag, s.usage, func(value string) error {
+			set(s, value)
+			return nil
+		})
+	}
+	return fs, path
+}
+
+// Validate reports settings that cannot be used together or at all
+func (c *Config) Validate() error {
+	var problems []string
+	if c.Addr == "" {
+		problems = append(problems, "addr is required")
+	}
+	switch c.Storage {
+	case "memory":
+	case "sqlite":
+		if c.DBPath == "" {
+			problems = append(problems, "db_path is required for the sqlite backend")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown storage backend %q", c.Storage))
+	}
+	for name, d := range map[string]time.Duration{
+		"read_timeout": c.ReadTimeout, "write_timeout": c.WriteTimeout, "idle_timeout": c.IdleTimeout,
+		"shutdown_timeout": c.ShutdownTimeout, "request_timeout": c.RequestTimeout,
+	} {
+		if d < 0 {
+			problems = append(problems, name+" must not be negative")
+		}
+	}
+	if c.MaxBodyBytes <= 0 {
+		problems = append(problems, "max_body_bytes must be positive")
+	}
+	if c.OIDCIssuer != "" && (c.OIDCClientID == "" || c.OIDCRedirectURL == "") {
+		problems = append(problems, "oidc_client_id and oidc_redirect_url are required with oidc_issuer")
+	}
+	switch c.RateLimitKey {
+	case "user", "api_key", "ip":
+	default:
+		problems = append(problems, fmt.Sprintf("unknown rate_limit_key %q", c.RateLimitKey))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		problems = append(problems, "tls_cert_file and tls_key_file must be set together")
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// AuthEnabled reports whether requests must be authenticated
+func (c *Config) AuthEnabled() bool {
+	return c.APIKeys != "" || c.JWTSecret != "" || c.JWTPublicKeyFile != "" || c.OIDCIssuer != ""
+}
+
+// TLS reports whether the server should serve HTTPS
+func (c *Config) TLS() bool {
+	return c.TLSCertFile != ""
+}
+
+// readFile reads a config file into raw values by key. The format is
+// chosen by extension: .yaml/.yml, or .toml for a flat TOML document.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		values := make(map[string]string, len(doc))
+		for key, value := range doc {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				return nil, fmt.Errorf("%s: %s must be a single value", path, key)
+			}
+			values[key] = fmt.Sprint(value)
+		}
+		return values, nil
+	case ".toml":
+		values, err := parseTOML(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file type %q", path, ext)
+	}
+}
+
+// parseTOML reads the subset of TOML a flat config needs: comments and
+// key = value pairs whose values are strings, integers or booleans.
+// Tables and arrays are rejected.
+func parseTOML(doc string) (map[string]string, error) {
+	values := make(map[string]string)
+	for i, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			unquoted, rest, err := cutTOMLString(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("line %d: unexpected %q after value", i+1, rest)
+			}
+			value = unquoted
+		} else {
+			if before, _, found := strings.Cut(value, "#"); found {
+				value = strings.TrimSpace(before)
+			}
+			if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") || value == "" {
+				return nil, fmt.Errorf("line %d: %s must be a string, integer or boolean", i+1, key)
+			}
+			value = strings.ReplaceAll(value, "_", "")
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: %s is set twice", i+1, key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// cutTOMLString unquotes the basic string at the start of s and returns
+// what follows it
+func cutTOMLString(s string) (string, string, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			unquoted, err := strconv.Unquote(s[:i+1])
+			return unquoted, s[i+1:], err
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
+
+func stringValue(p *string) func(string) error {
+	return func(s string) error {
+		*p = s
+		return nil
+	}
+}
+
+func durationValue(p *time.Duration) func(string) error {
+	return func(s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*p = d
+		return nil
+	}
+}
+
+func int64Value(p *int64) func(string) error {
+	return func(s string) error {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		*p = n
+		return nil
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
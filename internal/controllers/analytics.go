@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"quiz-app/internal/analytics"
+	"quiz-app/internal/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// ItemAnalysis reports the difficulty, discrimination and option choices of
+// every question of a quiz, computed from all of its results
+func (c *QuizController) ItemAnalysis(w http.ResponseWriter, r *http.Request) {
+	quizID := mux.Vars(r)["quizId"]
+
+	quiz, err := c.store.GetQuiz(r.Context(), quizID)
+	if err != nil {
+		writeError(w, err, "Failed to load quiz")
+		return
+	}
+	results, err := c.store.ListResults(r.Context(), quizID)
+	if err != nil {
+		writeError(w, err, "Failed to load results")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"quiz_id":     quizID,
+		"respondents": len(results),
+		"items":       analytics.Items(quiz, results),
+	})
+}
+
+// defaultHistogramBins is the number of histogram bins used when ?bins= is
+// not given
+const defaultHistogramBins = 10
+
+// QuizStats reports the score distribution of a quiz and its reliability
+// (KR-20, Cronbach's alpha and the standard error of measurement). The
+// score histogram has ?bins= equal-width bins.
+func (c *QuizController) QuizStats(w http.ResponseWriter, r *http.Request) {
+	quizID := mux.Vars(r)["quizId"]
+
+	bins := defaultHistogramBins
+	if v := r.URL.Query().Get("bins"); v != "" {
+		var err error
+		if bins, err = strconv.Atoi(v); err != nil || bins < 1 || bins > maxPageSize {
+			apierror.Write(w, http.StatusBadRequest, "Invalid bins")
+			return
+		}
+	}
+
+	quiz, err := c.store.GetQuiz(r.Context(), quizID)
+	if err != nil {
+		writeError(w, err, "Failed to load quiz")
+		return
+	}
+	results, err := c.store.ListResults(r.Context(), quizID)
+	if err != nil {
+		writeError(w, err, "Failed to load results")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analytics.Summarize(quiz, results, bins))
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"quiz-app/internal/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// StartAttempt opens a timed attempt at a quiz. The user is named in the
+// request body as {"user_id": "..."}, which takers may leave out since
+// their credentials name them.
+func (c *QuizController) StartAttempt(w http.ResponseWriter, r *http.Request) {
+	quizID := mux.Vars(r)["quizId"]
+
+	var body struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	userID, ok := actingUser(w, r, body.UserID)
+	if !ok {
+		return
+	}
+
+	result, err := c.store.StartAttempt(r.Context(), quizID, userID)
+	if err != nil {
+		writeError(w, err, "Failed to start attempt")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
+
+// FinishAttempt submits a user's attempt and locks it against further answers
+func (c *QuizController) FinishAttempt(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	quizID := params["quizId"]
+	userID, ok := actingUser(w, r, params["userId"])
+	if !ok {
+		return
+	}
+
+	result, err := c.store.FinishAttempt(r.Context(), quizID, userID)
+	if err != nil {
+		writeError(w, err, "Failed to finish attempt")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"quiz-app/internal/apierror"
+	"quiz-app/internal/audit"
+	"quiz-app/internal/tenant"
+)
+
+// AuditController serves the audit log
+type AuditController struct {
+	events audit.Log
+}
+
+// NewAuditController creates a new AuditController
+func NewAuditController(events audit.Log) *AuditController {
+	return &AuditController{events: events}
+}
+
+// ListEvents returns a page of the organization's audit events, oldest
+// first. They are filtered by ?actor=, ?action=, ?quiz_id=, ?user_id=,
+// ?request_id=, and ?since= and ?until= as RFC 3339 times. With
+// ?format=ndjson every matching event is exported as newline-delimited
+// JSON instead, unless ?offset= or ?limit= select a page.
+func (c *AuditController) ListEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Org:       tenant.FromContext(r.Context()).ID,
+		Actor:     query.Get("actor"),
+		Action:    audit.Action(query.Get("action")),
+		QuizID:    query.Get("quiz_id"),
+		UserID:    query.Get("user_id"),
+		RequestID: query.Get("request_id"),
+	}
+	format := query.Get("format")
+	if format != "" && format != "ndjson" {
+		apierror.Write(w, http.StatusBadRequest, "Unsupported format")
+		return
+	}
+	if format == "" {
+		filter.Limit = defaultPageSize
+	}
+
+	var err error
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := query.Get(name); v != "" {
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				apierror.Write(w, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil || filter.Offset < 0 {
+			apierror.Write(w, http.StatusBadRequest, "Invalid offset")
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > maxPageSize {
+			apierror.Write(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+
+	events, total, err := c.events.Query(r.Context(), filter)
+	if err != nil {
+		writeError(w, err, "Failed to read audit log")
+		return
+	}
+
+	if format == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition",
+			mime.FormatMediaType("attachment", map[string]string{"filename": "audit-" + filter.Org + ".ndjson"}))
+		enc := json.NewEncoder(w)
+		for i := range events {
+			enc.Encode(&events[i])
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": events,
+		"total":  total,
+		"offset": filter.Offset,
+		"limit":  filter.Limit,
+	})
+}
@@ -2,10 +2,17 @@ package controllers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"quiz-app/internal/apierror"
+	"quiz-app/internal/auth"
 	"quiz-app/internal/models"
 	"quiz-app/internal/storage"
+	"quiz-app/internal/validation"
 
 	"github.com/gorilla/mux"
 )
@@ -13,23 +20,33 @@ import (
 // QuizController handles quiz-related operations
 type QuizController struct {
 	store storage.Storage
+	now   func() time.Time
 }
 
 // NewQuizController creates a new QuizController
 func NewQuizController(store storage.Storage) *QuizController {
-	return &QuizController{store: store}
+	return &QuizController{store: store, now: time.Now}
+}
+
+// SetClock replaces the clock used to decide when feedback is released, for tests
+func (c *QuizController) SetClock(now func() time.Time) {
+	c.now = now
 }
 
 // CreateQuiz handles the creation of a new quiz
 func (c *QuizController) CreateQuiz(w http.ResponseWriter, r *http.Request) {
 	var quiz models.Quiz
 	if err := json.NewDecoder(r.Body).Decode(&quiz); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := validation.ValidateQuiz(&quiz); errs != nil {
+		writeError(w, errs, "Invalid quiz definition")
 		return
 	}
 
-	if err := c.store.CreateQuiz(&quiz); err != nil {
-		http.Error(w, "Failed to create quiz", http.StatusInternalServerError)
+	if err := c.store.CreateQuiz(r.Context(), &quiz); err != nil {
+		writeError(w, err, "Failed to create quiz")
 		return
 	}
 
@@ -37,47 +54,234 @@ func (c *QuizController) CreateQuiz(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Quiz created successfully"})
 }
 
-// GetQuiz retrieves a quiz by ID
+// GetQuiz retrieves a quiz by ID. Takers get a view without answer keys;
+// ?view=author returns the full definition to authors, and so is refused
+// when authentication is disabled and no caller is known. Randomized quizzes
+// show each taker their own paper, so they need ?user_id= unless the taker
+// is known from their credentials.
 func (c *QuizController) GetQuiz(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	quizID := params["id"]
 
-	quiz, err := c.store.GetQuiz(quizID)
+	quiz, err := c.store.GetQuiz(r.Context(), quizID)
+	if err != nil {
+		writeError(w, err, "Failed to load quiz")
+		return
+	}
+
+	query := r.URL.Query()
+	view := query.Get("view")
+	if principal, ok := auth.FromContext(r.Context()); view == "author" && (!ok || !principal.Is(auth.RoleAuthor)) {
+		writeError(w, auth.ErrForbidden, "Failed to load quiz")
+		return
+	}
+	userID, err := auth.UserFor(r.Context(), query.Get("user_id"))
 	if err != nil {
-		http.Error(w, "Quiz not found", http.StatusNotFound)
+		writeError(w, err, "Failed to load quiz")
+		return
+	}
+	if view != "author" && quiz.IsRandomized() && userID == "" {
+		// Each taker gets their own paper, so there is no single taker view
+		apierror.Write(w, http.StatusBadRequest, "user_id is required for randomized quizzes")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(quiz.Version))
+
+	if view == "author" {
+		json.NewEncoder(w).Encode(models.NewAuthorQuiz(quiz))
+		return
+	}
+	json.NewEncoder(w).Encode(models.NewTakerQuiz(quiz.PaperFor(userID).Quiz))
+}
+
+// ListQuizzes returns a page of quiz summaries, optionally filtered by title
+func (c *QuizController) ListQuizzes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := storage.QuizFilter{Title: query.Get("title"), Limit: defaultPageSize}
+
+	var err error
+	if v := query.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil || filter.Offset < 0 {
+			apierror.Write(w, http.StatusBadRequest, "Invalid offset")
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > maxPageSize {
+			apierror.Write(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+
+	quizzes, total, err := c.store.ListQuizzes(r.Context(), filter)
+	if err != nil {
+		writeError(w, err, "Failed to list quizzes")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"quizzes": quizzes,
+		"total":   total,
+		"offset":  filter.Offset,
+		"limit":   filter.Limit,
+	})
+}
+
+// UpdateQuiz replaces a quiz. The expected version may be given in an
+// If-Match header or in the body; a stale version is rejected with 409.
+func (c *QuizController) UpdateQuiz(w http.ResponseWriter, r *http.Request) {
+	quizID := mux.Vars(r)["id"]
+
+	var quiz models.Quiz
+	if err := json.NewDecoder(r.Body).Decode(&quiz); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if quiz.ID == "" {
+		quiz.ID = quizID
+	}
+	if quiz.ID != quizID {
+		apierror.Write(w, http.StatusBadRequest, "Quiz ID does not match URL")
 		return
 	}
 
-	// Remove correct_option and marks from questions
-	for i := range quiz.Questions {
-		quiz.Questions[i].CorrectOption = 0
-		quiz.Questions[i].Marks = 0
+	version, err := expectedVersion(r, quiz.Version)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
 	}
+	quiz.Version = version
 
-	json.NewEncoder(w).Encode(quiz)
+	c.saveQuiz(w, r, &quiz)
+}
+
+// PatchQuiz applies a JSON merge patch (RFC 7396) to a quiz. Unless the
+// client pins a version, the version that was read is used so that a
+// concurrent update between read and write is reported as a conflict.
+func (c *QuizController) PatchQuiz(w http.ResponseWriter, r *http.Request) {
+	quizID := mux.Vars(r)["id"]
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	existing, err := c.store.GetQuiz(r.Context(), quizID)
+	if err != nil {
+		writeError(w, err, "Failed to load quiz")
+		return
+	}
+
+	var doc map[string]interface{}
+	raw, _ := json.Marshal(existing)
+	json.Unmarshal(raw, &doc)
+
+	merged, _ := json.Marshal(mergePatch(doc, patch))
+	var quiz models.Quiz
+	if err := json.Unmarshal(merged, &quiz); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if quiz.ID != quizID {
+		apierror.Write(w, http.StatusBadRequest, "Quiz ID cannot be changed")
+		return
+	}
+
+	version, err := expectedVersion(r, quiz.Version)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+	quiz.Version = version
+
+	c.saveQuiz(w, r, &quiz)
+}
+
+// saveQuiz stores an updated quiz and writes the response shared by PUT and PATCH
+func (c *QuizController) saveQuiz(w http.ResponseWriter, r *http.Request, quiz *models.Quiz) {
+	if errs := validation.ValidateQuiz(quiz); errs != nil {
+		writeError(w, errs, "Invalid quiz definition")
+		return
+	}
+	if err := c.store.UpdateQuiz(r.Context(), quiz); err != nil {
+		writeError(w, err, "Failed to update quiz")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(quiz.Version))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Quiz updated successfully",
+		"version": quiz.Version,
+	})
+}
+
+// DeleteQuiz removes a quiz and its results. With ?archive=true the results
+// are archived rather than discarded, where the storage keeps archives.
+func (c *QuizController) DeleteQuiz(w http.ResponseWriter, r *http.Request) {
+	quizID := mux.Vars(r)["id"]
+	archive := r.URL.Query().Get("archive") == "true"
+
+	if err := c.store.DeleteQuiz(r.Context(), quizID, archive); err != nil {
+		writeError(w, err, "Failed to delete quiz")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // SubmitAnswer handles the submission of an answer to a quiz question
 func (c *QuizController) SubmitAnswer(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	quizID := params["quizId"]
-	userID := params["userId"]
 
 	var answer models.Answer
 	if err := json.NewDecoder(r.Body).Decode(&answer); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	userID, ok := actingUser(w, r, params["userId"])
+	if !ok {
 		return
 	}
 
-	isCorrect, correctAnswer, err := c.store.SubmitAnswer(quizID, userID, &answer)
+	isCorrect, correctAnswer, err := c.store.SubmitAnswer(r.Context(), quizID, userID, &answer)
 	if err != nil {
-		http.Error(w, "Failed to submit answer", http.StatusInternalServerError)
+		writeError(w, err, "Failed to submit answer")
 		return
 	}
 
+	// Only immediate feedback is revealed at answer time: every other mode
+	// waits for the attempt or the quiz to end, which cannot have happened
+	// while answers are still accepted
+	mode := models.FeedbackImmediate
+	if quiz, err := c.store.GetQuiz(r.Context(), quizID); err != nil {
+		mode = models.FeedbackNever
+	} else if quiz.FeedbackMode != "" {
+		mode = quiz.FeedbackMode
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	if mode != models.FeedbackImmediate {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":       "Answer recorded",
+			"feedback_mode": mode,
+		})
+		return
+	}
+
 	response := map[string]interface{}{
 		"is_correct": isCorrect,
+		"score":      answer.Score,
 	}
 
 	if !isCorrect {
@@ -91,13 +295,79 @@ func (c *QuizController) SubmitAnswer(w http.ResponseWriter, r *http.Request) {
 func (c *QuizController) GetResults(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	quizID := params["quizId"]
-	userID := params["userId"]
+	userID, ok := actingUser(w, r, params["userId"])
+	if !ok {
+		return
+	}
 
-	result, err := c.store.GetResults(quizID, userID)
+	result, err := c.store.GetResults(r.Context(), quizID, userID)
 	if err != nil {
-		http.Error(w, "Results not found", http.StatusNotFound)
+		writeError(w, err, "Failed to load results")
 		return
 	}
 
-	json.NewEncoder(w).Encode(result)
+	quiz, err := c.store.GetQuiz(r.Context(), quizID)
+	if err != nil {
+		writeError(w, err, "Failed to load quiz")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.NewResultView(quiz, result, c.now()))
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// actingUser returns the user a request acts for, given the user named in
+// its path or body, as decided by auth.UserFor. It reports false after
+// writing an error if the caller may not act for that user or no user is
+// known.
+func actingUser(w http.ResponseWriter, r *http.Request, named string) (string, bool) {
+	userID, err := auth.UserFor(r.Context(), named)
+	if err != nil {
+		writeError(w, err, "Failed to identify user")
+		return "", false
+	}
+	if userID == "" {
+		apierror.Write(w, http.StatusBadRequest, "user_id is required")
+		return "", false
+	}
+	return userID, true
+}
+
+// etag formats a quiz version as a strong entity tag
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// expectedVersion returns the version named by the If-Match header, or
+// fallback when the header is absent.
+func expectedVersion(r *http.Request, fallback int) (int, error) {
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(strings.Trim(strings.TrimPrefix(match, "W/"), `"`))
+}
+
+// mergePatch applies an RFC 7396 JSON merge patch to doc
+func mergePatch(doc, patch map[string]interface{}) map[string]interface{} {
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(doc, key)
+			continue
+		}
+		if sub, ok := value.(map[string]interface{}); ok {
+			target, _ := doc[key].(map[string]interface{})
+			doc[key] = mergePatch(target, sub)
+			continue
+		}
+		doc[key] = value
+	}
+	return doc
 }
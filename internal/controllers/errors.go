@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"quiz-app/internal/apierror"
+	"quiz-app/internal/auth"
+	"quiz-app/internal/storage"
+	"quiz-app/internal/validation"
+)
+
+// knownErrors gives the status and message for each error a client can
+// cause. They are checked in order, so specific errors come before the
+// kinds they belong to.
+var knownErrors = []struct {
+	err     error
+	status  int
+	message string
+}{
+	{storage.ErrQuizNotFound, http.StatusNotFound, "Quiz not found"},
+	{storage.ErrQuestionNotFound, http.StatusNotFound, "Question not found"},
+	{storage.ErrResultsNotFound, http.StatusNotFound, "Results not found"},
+	{storage.ErrAttemptNotFound, http.StatusNotFound, "Attempt not found"},
+	{storage.ErrQuizNotOpen, http.StatusForbidden, "Quiz is not open"},
+	{storage.ErrQuizExists, http.StatusConflict, "Quiz already exists"},
+	{storage.ErrVersionConflict, http.StatusConflict, "Quiz was modified by another request"},
+	{storage.ErrAttemptExists, http.StatusConflict, "Attempt already started"},
+	{storage.ErrAttemptClosed, http.StatusConflict, "Attempt is closed"},
+	{storage.ErrAlreadyAnswered, http.StatusConflict, "Question already answered"},
+	{storage.ErrQuotaExceeded, http.StatusForbidden, "Organization quota exceeded"},
+	{storage.ErrNotFound, http.StatusNotFound, "Not found"},
+	{storage.ErrConflict, http.StatusConflict, "Conflict"},
+	{auth.ErrForbidden, http.StatusForbidden, "Your role does not allow this request"},
+}
+
+// writeError is the single path by which controllers report a failed
+// operation. Storage and authorization errors are mapped to their status,
+// an invalid quiz is reported with 422 and its field errors, and anything
+// else is a 500 with the fallback message so that internal details are not
+// leaked. A request abandoned because its context ended is reported as 503.
+func writeError(w http.ResponseWriter, err error, fallback string) {
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		apierror.WriteDetails(w, http.StatusUnprocessableEntity, "Invalid quiz definition", fieldErrs)
+		return
+	}
+	for _, e := range knownErrors {
+		if errors.Is(err, e.err) {
+			apierror.Write(w, e.status, e.message)
+			return
+		}
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		apierror.Write(w, http.StatusServiceUnavailable, "Request timed out")
+	case errors.Is(err, context.Canceled):
+		apierror.Write(w, http.StatusServiceUnavailable, "Request was cancelled")
+	default:
+		apierror.Write(w, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"quiz-app/internal/apierror"
+	"quiz-app/internal/leaderboard"
+
+	"github.com/gorilla/mux"
+)
+
+// leaderboardResponse is a page of a quiz leaderboard. Me is the entry of
+// the user named by ?user_id=, wherever they are ranked.
+type leaderboardResponse struct {
+	QuizID string `json:"quiz_id"`
+	Total  int    `json:"total"`
+	leaderboard.Page
+	Me *leaderboard.Entry `json:"me,omitempty"`
+}
+
+// Leaderboard ranks the users of a quiz by score. Only results whose score
+// has been released under the quiz's feedback mode are ranked. Pages are
+// selected with ?limit= and the ?cursor= returned by the previous page.
+func (c *QuizController) Leaderboard(w http.ResponseWriter, r *http.Request) {
+	quizID := mux.Vars(r)["quizId"]
+	query := r.URL.Query()
+
+	limit := defaultPageSize
+	if v := query.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxPageSize {
+			apierror.Write(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+
+	quiz, err := c.store.GetQuiz(r.Context(), quizID)
+	if err != nil {
+		writeError(w, err, "Failed to load quiz")
+		return
+	}
+	results, err := c.store.ListResults(r.Context(), quizID)
+	if err != nil {
+		writeError(w, err, "Failed to load results")
+		return
+	}
+
+	now := c.now()
+	released := results[:0]
+	for i := range results {
+		if quiz.ScoreReleased(&results[i], now) {
+			released = append(released, results[i])
+		}
+	}
+	entries := leaderboard.Rank(released, now)
+
+	page, err := leaderboard.Paginate(entries, query.Get("cursor"), limit)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	response := leaderboardResponse{QuizID: quizID, Total: len(entries), Page: page}
+	if userID := query.Get("user_id"); userID != "" {
+		if response.Me = leaderboard.Find(entries, userID); response.Me == nil {
+			apierror.Write(w, http.StatusNotFound, "User is not on the leaderboard")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"quiz-app/internal/apierror"
+	"quiz-app/internal/models"
+	"quiz-app/internal/quizfmt"
+	"quiz-app/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+// quizFormat is an interchange format quizzes can be imported from and
+// exported to
+type quizFormat struct {
+	contentType string
+	extension   string
+	parse       func(io.Reader) (*models.Quiz, error)
+	write       func(io.Writer, *models.Quiz) error
+}
+
+// quizFormats are the formats accepted by ?format= on import and export
+var quizFormats = map[string]quizFormat{
+	"gift": {contentType: "text/plain; charset=utf-8", extension: ".gift", parse: quizfmt.ParseGIFT, write: quizfmt.WriteGIFT},
+	"qti":  {contentType: "application/zip", extension: ".zip", parse: quizfmt.ParseQTI, write: quizfmt.WriteQTI},
+	"markdown": {contentType: "text/markdown; charset=utf-8", extension: ".md",
+		parse: quizfmt.ParseMarkdown, write: quizfmt.WriteMarkdown},
+}
+
+// ImportQuiz creates a quiz from a document in the format named by ?format=.
+// The quiz ID is given by ?id=, or by the document if its format has one,
+// and ?title= overrides any title in the document. Documents with problems
+// are rejected with 422 and the list of problems by line or, for packages,
+// by item. A document that parses but defines an invalid quiz is rejected
+// with 422 and the problems by field, as for CreateQuiz.
+//
+// With ?validate=true the document is only checked: the response reports
+// whether it is valid and its problems, and nothing is created.
+func (c *QuizController) ImportQuiz(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format, ok := quizFormats[query.Get("format")]
+	if !ok {
+		apierror.Write(w, http.StatusBadRequest, "Unsupported format")
+		return
+	}
+	validateOnly := query.Get("validate") == "true"
+
+	quiz, err := format.parse(r.Body)
+	if err != nil {
+		var lineErrs quizfmt.ErrorList
+		var itemErrs quizfmt.ItemErrorList
+		var problems interface{}
+		switch {
+		case errors.As(err, &lineErrs):
+			problems = lineErrs
+		case errors.As(err, &itemErrs):
+			problems = itemErrs
+		default:
+			apierror.Write(w, http.StatusBadRequest, "Invalid document: "+err.Error())
+			return
+		}
+		if validateOnly {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"valid": false, "errors": problems})
+			return
+		}
+		apierror.WriteDetails(w, http.StatusUnprocessableEntity, "Invalid document", problems)
+		return
+	}
+	if id := query.Get("id"); id != "" {
+		quiz.ID = id
+	}
+	if title := query.Get("title"); title != "" {
+		quiz.Title = title
+	}
+	fieldErrs := validateImported(quiz)
+	if validateOnly {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"valid":          len(fieldErrs) == 0,
+			"errors":         append(validation.Errors{}, fieldErrs...),
+			"question_count": len(quiz.Questions),
+		})
+		return
+	}
+	if quiz.ID == "" {
+		apierror.Write(w, http.StatusBadRequest, "id is required")
+		return
+	}
+	if fieldErrs != nil {
+		writeError(w, fieldErrs, "Invalid quiz definition")
+		return
+	}
+
+	if err := c.store.CreateQuiz(r.Context(), quiz); err != nil {
+		writeError(w, err, "Failed to create quiz")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Quiz imported successfully",
+		"id":             quiz.ID,
+		"question_count": len(quiz.Questions),
+	})
+}
+
+// ExportQuiz writes a quiz as a document in the format named by ?format=
+func (c *QuizController) ExportQuiz(w http.ResponseWriter, r *http.Request) {
+	format, ok := quizFormats[r.URL.Query().Get("format")]
+	if !ok {
+		apierror.Write(w, http.StatusBadRequest, "Unsupported format")
+		return
+	}
+
+	quiz, err := c.store.GetQuiz(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, err, "Failed to load quiz")
+		return
+	}
+
+	// Render fully before writing so that a quiz the format cannot express
+	// is reported instead of sent half-written
+	var doc bytes.Buffer
+	if err := format.write(&doc, quiz); err != nil {
+		apierror.Write(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment",
+		map[string]string{"filename": quiz.ID + format.extension}))
+	w.Write(doc.Bytes())
+}
+
+// ImportCSV creates a quiz from a spreadsheet of questions exported as CSV.
+// The quiz is named by ?id= and ?title=. Columns are mapped with ?text=,
+// ?option= (repeated, in order), ?correct=, ?question_id=, ?marks= and
+// ?time_limit=, each defaulting to the Kahoot template's headers.
+//
+// The response reports on every row. If any row has problems nothing is
+// created and the status is 422. With ?dry_run=true the rows are only
+// checked and the status is 200 either way.
+func (c *QuizController) ImportCSV(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	dryRun := query.Get("dry_run") == "true"
+	id := query.Get("id")
+	if id == "" && !dryRun {
+		apierror.Write(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	mapping := quizfmt.DefaultCSVMapping()
+	override := func(field *string, param string) {
+		if query.Has(param) {
+			*field = query.Get(param)
+		}
+	}
+	override(&mapping.Text, "text")
+	override(&mapping.Correct, "correct")
+	override(&mapping.ID, "question_id")
+	override(&mapping.Marks, "marks")
+	override(&mapping.TimeLimit, "time_limit")
+	mapping.Options = query["option"]
+
+	quiz, rows, err := quizfmt.ParseCSV(r.Body, mapping)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid CSV: "+err.Error())
+		return
+	}
+	quiz.ID, quiz.Title = id, query.Get("title")
+	valid := true
+	for _, row := range rows {
+		valid = valid && len(row.Errors) == 0
+	}
+	report := map[string]interface{}{
+		"valid":          valid,
+		"dry_run":        dryRun,
+		"rows":           rows,
+		"question_count": len(quiz.Questions),
+	}
+	// Rows with problems are left out of the quiz, so the definition is only
+	// checked once every row has been accepted
+	if valid {
+		if fieldErrs := validateImported(quiz); fieldErrs != nil {
+			valid = false
+			report["valid"] = false
+			report["errors"] = fieldErrs
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case dryRun:
+		json.NewEncoder(w).Encode(report)
+		return
+	case !valid:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	if err := c.store.CreateQuiz(r.Context(), quiz); err != nil {
+		writeError(w, err, "Failed to create quiz")
+		return
+	}
+	report["id"] = quiz.ID
+	report["message"] = "Quiz imported successfully"
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
+
+// validateImported checks an imported quiz. A missing ID is not reported,
+// since a document may be checked before it is given one.
+func validateImported(quiz *models.Quiz) validation.Errors {
+	var errs validation.Errors
+	for _, fe := range validation.ValidateQuiz(quiz) {
+		if quiz.ID == "" && fe.Field == "id" {
+			continue
+		}
+		errs = append(errs, fe)
+	}
+	return errs
+}
@@ -0,0 +1,175 @@
+// Package leaderboard ranks the results of a quiz.
+package leaderboard
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"quiz-app/internal/models"
+)
+
+// ErrInvalidCursor is returned by Paginate when the cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Entry is one user's place on a leaderboard
+type Entry struct {
+	Rank        int        `json:"rank"`
+	UserID      string     `json:"user_id"`
+	Score       float32    `json:"score"`
+	Percentile  float64    `json:"percentile"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Page is a window onto a leaderboard. NextCursor is empty on the last page.
+type Page struct {
+	Entries    []Entry `json:"entries"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// Rank orders results best first. Higher scores rank higher; equal scores
+// are broken by completion time, earliest first, with attempts that are
+// still in progress after completed ones. Users who tie on both share a
+// rank and are listed by user ID.
+//
+// An attempt whose deadline passed at time now counts as completed at its
+// deadline. Percentile is the share of users scoring lower, counting half
+// of those with the same score.
+func Rank(results []models.Result, now time.Time) []Entry {
+	entries := make([]Entry, len(results))
+	for i := range results {
+		entries[i] = Entry{
+			UserID:      results[i].UserID,
+			Score:       results[i].Score,
+			CompletedAt: completedAt(&results[i], now),
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+
+	below := 0
+	for i := range entries {
+		if i > 0 && tied(entries[i-1], entries[i]) {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+	// Walk from the bottom so that "below" counts strictly lower scores
+	for i := len(entries) - 1; i >= 0; {
+		j := i
+		for j > 0 && entries[j-1].Score == entries[i].Score {
+			j--
+		}
+		equal := i - j + 1
+		percentile := (float64(below) + float64(equal)/2) / float64(len(entries)) * 100
+		for k := j; k <= i; k++ {
+			entries[k].Percentile = percentile
+		}
+		below += equal
+		i = j - 1
+	}
+	return entries
+}
+
+// Find returns the entry for userID, or nil if the user is not ranked
+func Find(entries []Entry, userID string) *Entry {
+	for i := range entries {
+		if entries[i].UserID == userID {
+			entry := entries[i]
+			return &entry
+		}
+	}
+	return nil
+}
+
+// Paginate returns up to limit entries following the one cursor points at,
+// or from the top when cursor is empty. Cursors name a position in the
+// ordering rather than an offset, so pages stay consistent as new results
+// arrive.
+func Paginate(entries []Entry, cursor string, limit int) (Page, error) {
+	start := 0
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		start = sort.Search(len(entries), func(i int) bool { return less(after, entries[i]) })
+	}
+
+	end := len(entries)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	page := Page{Entries: append([]Entry{}, entries[start:end]...)}
+	if end < len(entries) {
+		page.NextCursor = encodeCursor(entries[end-1])
+	}
+	return page, nil
+}
+
+// less reports whether a ranks above b
+func less(a, b Entry) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	if !sameTime(a.CompletedAt, b.CompletedAt) {
+		switch {
+		case a.CompletedAt == nil:
+			return false
+		case b.CompletedAt == nil:
+			return true
+		default:
+			return a.CompletedAt.Before(*b.CompletedAt)
+		}
+	}
+	return a.UserID < b.UserID
+}
+
+// tied reports whether a and b share a rank
+func tied(a, b Entry) bool {
+	return a.Score == b.Score && sameTime(a.CompletedAt, b.CompletedAt)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func completedAt(result *models.Result, now time.Time) *time.Time {
+	switch {
+	case result.FinishedAt != nil:
+		return result.FinishedAt
+	case result.IsOver(now):
+		return result.Deadline
+	default:
+		return nil
+	}
+}
+
+// cursor is the sort key of the last entry on a page
+type cursor struct {
+	Score       float32    `json:"s"`
+	CompletedAt *time.Time `json:"c,omitempty"`
+	UserID      string     `json:"u"`
+}
+
+func encodeCursor(entry Entry) string {
+	raw, _ := json.Marshal(cursor{Score: entry.Score, CompletedAt: entry.CompletedAt, UserID: entry.UserID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (Entry, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Entry{}, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return Entry{}, ErrInvalidCursor
+	}
+	return Entry{Score: c.Score, CompletedAt: c.CompletedAt, UserID: c.UserID}, nil
+}
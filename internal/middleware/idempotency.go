@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"quiz-app/internal/apierror"
+	"quiz-app/internal/auth"
+	"quiz-app/internal/tenant"
+)
+
+// IdempotencyHeader is the request header clients use to make a retried
+// request safe to repeat.
+const IdempotencyHeader = "Idempotency-Key"
+
+// defaultMaxIdempotentEntries caps the responses a cache keeps
+const defaultMaxIdempotentEntries = 10000
+
+// errCacheFull is returned by reserve when the cache holds as many live
+// entries as it may
+var errCacheFull = errors.New("idempotency cache is full")
+
+// IdempotencyCache remembers the responses of requests that carried an
+// Idempotency-Key so that retries are answered with the original response
+// instead of being executed again. Keys are scoped to the method and path,
+// the organization, and the caller when the request is authenticated. A
+// key reused with a different body is rejected. Entries are kept in memory
+// for the cache's TTL, up to a maximum number; while the cache is full, new
+// keys are refused with 503 Service Unavailable.
+type IdempotencyCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	entries    map[string]*idempotentEntry
+	lastPrune  time.Time
+	mu         sync.Mutex
+}
+
+type idempotentEntry struct {
+	fingerprint [sha256.Size]byte
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// NewIdempotencyCache creates a cache that keeps up to maxEntries responses
+// for ttl. A maxEntries of zero or less uses a default of 10000.
+func NewIdempotencyCache(ttl time.Duration, maxEntries int) *IdempotencyCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxIdempotentEntries
+	}
+	return &IdempotencyCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*idempotentEntry),
+	}
+}
+
+// Middleware wraps next so that requests with an Idempotency-Key are executed
+// at most once. Server errors and throttled requests are not remembered, so
+// a request that failed with a 5xx or 429 status can be retried with the
+// same key.
+func (c *IdempotencyCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var body bytes.Buffer
+		if r.Body != nil {
+			if _, err := body.ReadFrom(r.Body); err != nil {
+				apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body.Bytes()))
+		fingerprint := sha256.Sum256(body.Bytes())
+		cacheKey := tenant.FromContext(r.Context()).ID + " " + r.Method + " " + r.URL.Path + " " + key
+		if principal, ok := auth.FromContext(r.Context()); ok {
+			// Callers cannot replay, or be blocked by, each other's keys
+			cacheKey = principal.Subject + " " + cacheKey
+		}
+
+		entry, fresh, err := c.reserve(cacheKey, fingerprint)
+		switch {
+		case err != nil:
+			w.Header().Set("Retry-After", "60")
+			apierror.Write(w, http.StatusServiceUnavailable, "Too many requests with an Idempotency-Key in progress")
+			return
+		case entry.fingerprint != fingerprint:
+			apierror.Write(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+			return
+		case !fresh && !entry.done:
+			apierror.Write(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+			return
+		case !fresh:
+			replay(w, entry)
+			return
+		}
+
+		// If the handler panics, release the key rather than leaving it
+		// in progress until it expires
+		completed := false
+		defer func() {
+			if !completed {
+				c.forget(cacheKey)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		c.complete(cacheKey, rec)
+		completed = true
+	})
+}
+
+// reserve returns a snapshot of the live entry for key, creating an
+// in-progress entry if there is none. fresh reports whether the caller
+// created it. It returns errCacheFull if a new entry is needed and the cache
+// is still full after dropping the expired ones.
+func (c *IdempotencyCache) reserve(key string, fingerprint [sha256.Size]byte) (entry idempotentEntry, fresh bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.prune(now, false)
+	if existing, ok := c.entries[key]; ok && now.Before(existing.expires) {
+		return *existing, false, nil
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.prune(now, true)
+		if len(c.entries) >= c.maxEntries {
+			return idempotentEntry{}, false, errCacheFull
+		}
+	}
+	c.entries[key] = &idempotentEntry{fingerprint: fingerprint, expires: now.Add(c.ttl)}
+	return *c.entries[key], true, nil
+}
+
+// complete stores the recorded response, or forgets the key after a server
+// error or throttling so that the client may retry.
+func (c *IdempotencyCache) complete(key string, rec *responseRecorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	if rec.status >= http.StatusInternalServerError || rec.status == http.StatusTooManyRequests {
+		delete(c.entries, key)
+		return
+	}
+	entry.status = rec.status
+	entry.header = rec.Header().Clone()
+	entry.body = rec.body.Bytes()
+	entry.done = true
+}
+
+// forget drops key so that the request can be retried
+func (c *IdempotencyCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// prune drops expired entries, at most once a minute unless forced
+func (c *IdempotencyCache) prune(now time.Time, force bool) {
+	if !force && now.Sub(c.lastPrune) < time.Minute {
+		return
+	}
+	c.lastPrune = now
+	for key, entry := range c.entries {
+		if entry.done && !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// replay writes a remembered response
+func replay(w http.ResponseWriter, entry idempotentEntry) {
+	for name, values := range entry.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"quiz-app/internal/apierror"
+)
+
+// MaxBody rejects request bodies larger than limit bytes. Requests that
+// declare a larger Content-Length are refused with 413 before the handler
+// runs; for the rest, reading past the limit fails and the handler reports
+// the body as invalid.
+func MaxBody(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				apierror.Write(w, http.StatusRequestEntityTooLarge, "Request body is too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"quiz-app/internal/apierror"
+	"quiz-app/internal/auth"
+	"quiz-app/internal/ratelimit"
+	"quiz-app/internal/tenant"
+
+	"github.com/gorilla/mux"
+)
+
+// AnyRoute names the limit of routes that have none of their own
+const AnyRoute = "*"
+
+// How a Throttle tells clients apart. Clients without the chosen identity,
+// such as unauthenticated ones when keying by user, are told apart by IP.
+const (
+	KeyByUser   = "user"
+	KeyByAPIKey = "api_key"
+	KeyByIP     = "ip"
+)
+
+// ThrottleOptions configures a Throttle. Routes holds the limit of each
+// route by name, such as "POST /quiz/{quizId}/answer", and AnyRoute the
+// limit of routes without one. Answers caps how often one user may answer
+// one question. A zero limit allows everything.
+type ThrottleOptions struct {
+	Limiter ratelimit.Limiter
+	Routes  map[string]ratelimit.Limit
+	KeyBy   string
+	Answers ratelimit.Limit
+}
+
+// Throttle rate-limits requests per client and route. Throttled requests
+// get 429 with a Retry-After header. If the limiter fails the request is
+// let through, so that an outage of a shared limiter does not take the
+// API down with it.
+type Throttle struct {
+	opts   ThrottleOptions
+	routed map[string]bool // names Route was called with
+}
+
+// NewThrottle creates a Throttle
+func NewThrottle(opts ThrottleOptions) (*Throttle, error) {
+	switch opts.KeyBy {
+	case "":
+		opts.KeyBy = KeyByUser
+	case KeyByUser, KeyByAPIKey, KeyByIP:
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %q", opts.KeyBy)
+	}
+	if opts.Limiter == nil {
+		opts.Limiter = ratelimit.NewMemory()
+	}
+	return &Throttle{opts: opts, routed: make(map[string]bool)}, nil
+}
+
+// UnknownRoutes lists the routes given a limit that Route has not been
+// called for, which are most likely misspelt
+func (t *Throttle) UnknownRoutes() []string {
+	if t == nil {
+		return nil
+	}
+	var unknown []string
+	for name := range t.opts.Routes {
+		if name != AnyRoute && !t.routed[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// Route wraps next in the limit of the named route. It belongs inside the
+// authentication guard so that clients can be told apart by user. Routes
+// are set up before serving, so Route is not safe for concurrent use. A
+// nil Throttle limits nothing.
+func (t *Throttle) Route(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if t == nil {
+			return next
+		}
+		t.routed[name] = true
+		limit, ok := t.opts.Routes[name]
+		if !ok {
+			limit = t.opts.Routes[AnyRoute]
+		}
+		if limit.IsZero() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if t.allow(w, r, "route "+name+" "+t.client(r), limit) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// Answers wraps an answer handler so that each user may answer each
+// question at most as often as the answer limit allows, however many
+// requests the route limit leaves them. It stops clients from trying every
+// option of a question in turn. A nil Throttle limits nothing.
+func (t *Throttle) Answers(next http.Handler) http.Handler {
+	if t == nil || t.opts.Answers.IsZero() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		if r.Body != nil {
+			if _, err := body.ReadFrom(r.Body); err != nil {
+				apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body.Bytes()))
+
+		// Requests the handler will refuse anyway are not counted
+		var answer struct {
+			QuestionID string `json:"question_id"`
+		}
+		userID, err := auth.UserFor(r.Context(), mux.Vars(r)["userId"])
+		if json.Unmarshal(body.Bytes(), &answer) != nil || err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if userID == "" {
+			userID = t.client(r)
+		}
+
+		key := fmt.Sprintf("answers %s/%s/%s %s", tenant.FromContext(r.Context()).ID, mux.Vars(r)["quizId"],
+			answer.QuestionID, userID)
+		if t.allow(w, r, key, t.opts.Answers) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// allow takes a token for key, or answers the request with 429 and reports
+// false if there is none
+func (t *Throttle) allow(w http.ResponseWriter, r *http.Request, key string, limit ratelimit.Limit) bool {
+	ok, retryAfter, err := t.opts.Limiter.Allow(r.Context(), key, limit)
+	if err != nil {
+		log.Printf("rate limiter failed, allowing request: %v", err)
+		return true
+	}
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		apierror.Write(w, http.StatusTooManyRequests, "Too many requests")
+		return false
+	}
+	return true
+}
+
+// client returns the key that identifies the client of r
+func (t *Throttle) client(r *http.Request) string {
+	switch t.opts.KeyBy {
+	case KeyByUser:
+		if principal, ok := auth.FromContext(r.Context()); ok {
+			return "user " + tenant.FromContext(r.Context()).ID + "/" + principal.Subject
+		}
+	case KeyByAPIKey:
+		if key := r.Header.Get(auth.APIKeyHeader); key != "" {
+			// Keys are hashed so that they are not kept in memory or
+			// sent to a shared limiter
+			sum := sha256.Sum256([]byte(key))
+			return "key " + hex.EncodeToString(sum[:16])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip " + host
+}
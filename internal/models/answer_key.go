@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// CorrectAnswer describes the correct answer of a question: the correct
+// option, the correct options joined by ", ", the expected number with its
+// tolerance, or the first full-credit text answer.
+func (q *Question) CorrectAnswer() (string, error) {
+	switch q.EffectiveKind() {
+	case KindNumeric:
+		if q.Numeric == nil {
+			return "", errors.New("invalid numeric answer key")
+		}
+		return formatNumericKey(q.Numeric), nil
+	case KindShortText:
+		for _, rule := range q.TextRules {
+			if rule.Match != MatchRegex && (rule.Score == 0 || rule.Score == 1) {
+				return rule.Pattern, nil
+			}
+		}
+		if len(q.TextRules) > 0 {
+			return q.TextRules[0].Pattern, nil
+		}
+		return "", errors.New("invalid text rules")
+	}
+
+	indexes := q.CorrectOptions
+	if !q.IsMultipleSelect() {
+		indexes = []int{q.CorrectOption}
+	}
+
+	texts := make([]string, len(indexes))
+	for i, o := range indexes {
+		if o < 0 || o >= len(q.Options) {
+			return "", errors.New("invalid correct option")
+		}
+		texts[i] = q.Options[o]
+	}
+	return strings.Join(texts, ", "), nil
+}
+
+// formatNumericKey renders a numeric key such as "9.81 ± 0.05" or "300 ± 5%"
+func formatNumericKey(key *NumericKey) string {
+	value := strconv.FormatFloat(key.Value, 'g', -1, 64)
+	switch {
+	case key.Tolerance == 0:
+		return value
+	case key.Relative:
+		return value + " ± " + strconv.FormatFloat(key.Tolerance*100, 'g', -1, 64) + "%"
+	default:
+		return value + " ± " + strconv.FormatFloat(key.Tolerance, 'g', -1, 64)
+	}
+}
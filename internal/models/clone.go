@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Clone returns a deep copy of the quiz that shares no slices, maps or
+// pointers with the original, so that either can be modified safely.
+func (q *Quiz) Clone() *Quiz {
+	if q == nil {
+		return nil
+	}
+	c := *q
+	c.OpensAt = cloneTime(q.OpensAt)
+	c.ClosesAt = cloneTime(q.ClosesAt)
+	c.Draws = cloneSlice(q.Draws)
+	if q.Questions != nil {
+		c.Questions = make([]Question, len(q.Questions))
+		for i := range q.Questions {
+			c.Questions[i] = q.Questions[i].Clone()
+		}
+	}
+	return &c
+}
+
+// Clone returns a deep copy of the question
+func (q Question) Clone() Question {
+	q.Options = cloneSlice(q.Options)
+	q.CorrectOptions = cloneSlice(q.CorrectOptions)
+	q.TextRules = cloneSlice(q.TextRules)
+	if q.Numeric != nil {
+		numeric := *q.Numeric
+		q.Numeric = &numeric
+	}
+	return q
+}
+
+// Clone returns a deep copy of the result
+func (r *Result) Clone() *Result {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	c.StartedAt = cloneTime(r.StartedAt)
+	c.Deadline = cloneTime(r.Deadline)
+	c.FinishedAt = cloneTime(r.FinishedAt)
+	if r.Answers != nil {
+		c.Answers = make(map[string]Answer, len(r.Answers))
+		for id, a := range r.Answers {
+			c.Answers[id] = a.Clone()
+		}
+	}
+	return &c
+}
+
+// Clone returns a deep copy of the answer
+func (a Answer) Clone() Answer {
+	a.SelectedOptions = cloneSlice(a.SelectedOptions)
+	if a.NumericValue != nil {
+		v := *a.NumericValue
+		a.NumericValue = &v
+	}
+	return a
+}
+
+func cloneSlice[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
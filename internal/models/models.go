@@ -1,34 +1,235 @@
 package models
 
-// Quiz represents a quiz with multiple questions
+import "time"
+
+// AnswerPolicy decides what happens when a user answers a question again
+type AnswerPolicy string
+
+const (
+	// PolicyLastWins replaces the earlier answer and recomputes the score. It
+	// is the default.
+	PolicyLastWins AnswerPolicy = "last_wins"
+	// PolicyFirstFinal rejects any answer after the first
+	PolicyFirstFinal AnswerPolicy = "first_final"
+	// PolicyBest keeps whichever answer scored highest
+	PolicyBest AnswerPolicy = "best"
+)
+
+// FeedbackMode decides when users learn whether their answers were correct
+type FeedbackMode string
+
+const (
+	// FeedbackImmediate reveals correctness and the correct answer as soon as
+	// a question is answered. It is the default.
+	FeedbackImmediate FeedbackMode = "immediate"
+	// FeedbackAfterAttempt reveals feedback once the attempt is finished
+	FeedbackAfterAttempt FeedbackMode = "after_attempt"
+	// FeedbackAfterClose reveals feedback once the quiz has closed
+	FeedbackAfterClose FeedbackMode = "after_close"
+	// FeedbackNever never reveals per-question feedback; only the final score
+	// is shown once the attempt is finished
+	FeedbackNever FeedbackMode = "never"
+)
+
+// Quiz represents a quiz with multiple questions. DurationSeconds limits how
+// long each attempt may run; OpensAt and ClosesAt bound when attempts may be
+// made at all. Zero values mean no limit. ShowMarks lets takers see how much
+// each question is worth.
+//
+// Draws, ShuffleQuestions and ShuffleOptions make each user's paper
+// different; see PaperFor. Seed varies the shuffle between quizzes that
+// would otherwise produce the same papers.
 type Quiz struct {
-	ID                string     `json:"id"`
-	Title             string     `json:"title"`
-	Questions         []Question `json:"questions"`
-	IsNegativeMarking bool       `json:"is_negative_marking"`
-	Penalty           float32    `json:"penalty"`
+	ID                string       `json:"id"`
+	Title             string       `json:"title"`
+	Questions         []Question   `json:"questions"`
+	IsNegativeMarking bool         `json:"is_negative_marking"`
+	Penalty           float32      `json:"penalty"`
+	AnswerPolicy      AnswerPolicy `json:"answer_policy,omitempty"`
+	FeedbackMode      FeedbackMode `json:"feedback_mode,omitempty"`
+	ShowMarks         bool         `json:"show_marks,omitempty"`
+	Draws             []PoolDraw   `json:"draws,omitempty"`
+	ShuffleQuestions  bool         `json:"shuffle_questions,omitempty"`
+	ShuffleOptions    bool         `json:"shuffle_options,omitempty"`
+	Seed              int64        `json:"seed,omitempty"`
+	DurationSeconds   int          `json:"duration_seconds,omitempty"`
+	OpensAt           *time.Time   `json:"opens_at,omitempty"`
+	ClosesAt          *time.Time   `json:"closes_at,omitempty"`
+	Version           int          `json:"version"`
+}
+
+// FeedbackReleased reports whether per-question feedback on result may be
+// shown at time now under the quiz's feedback mode
+func (q *Quiz) FeedbackReleased(result *Result, now time.Time) bool {
+	switch q.FeedbackMode {
+	case FeedbackAfterAttempt:
+		return result.IsOver(now)
+	case FeedbackAfterClose:
+		return q.ClosesAt != nil && !now.Before(*q.ClosesAt)
+	case FeedbackNever:
+		return false
+	default:
+		return true
+	}
+}
+
+// ScoreReleased reports whether the total score of result may be shown at
+// time now. It is released with the feedback, or under FeedbackNever once
+// the attempt is over.
+func (q *Quiz) ScoreReleased(result *Result, now time.Time) bool {
+	return q.FeedbackReleased(result, now) || (q.FeedbackMode == FeedbackNever && result.IsOver(now))
 }
 
-// Question represents a single question in a quiz
+// PoolDraw asks for Count questions to be drawn at random from a pool
+type PoolDraw struct {
+	Pool  string `json:"pool"`
+	Count int    `json:"count"`
+}
+
+// QuizSummary is the listing view of a quiz, without its questions
+type QuizSummary struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	QuestionCount int    `json:"question_count"`
+	Version       int    `json:"version"`
+}
+
+// Summary returns the listing view of the quiz
+func (q *Quiz) Summary() QuizSummary {
+	return QuizSummary{ID: q.ID, Title: q.Title, QuestionCount: len(q.Questions), Version: q.Version}
+}
+
+// ScoringMode controls how partial answers to multiple-select questions are credited
+type ScoringMode string
+
+const (
+	// ScoringAllOrNothing awards full marks only for exactly the correct set of options
+	ScoringAllOrNothing ScoringMode = "all_or_nothing"
+	// ScoringProportional treats every option as a true/false judgement and
+	// awards the fraction of options judged correctly
+	ScoringProportional ScoringMode = "proportional"
+	// ScoringRightMinusWrong awards one share per correct option selected and
+	// deducts one share per incorrect option selected, never going below zero
+	ScoringRightMinusWrong ScoringMode = "right_minus_wrong"
+)
+
+// QuestionKind identifies how a question is answered and graded
+type QuestionKind string
+
+const (
+	// KindSingleChoice questions are answered with one index into Options
+	KindSingleChoice QuestionKind = "single_choice"
+	// KindMultipleSelect questions are answered with a set of indexes into Options
+	KindMultipleSelect QuestionKind = "multiple_select"
+	// KindNumeric questions are answered with a number
+	KindNumeric QuestionKind = "numeric"
+	// KindShortText questions are answered with free text
+	KindShortText QuestionKind = "short_text"
+)
+
+// NumericKey is the answer key of a numeric question. Tolerance is an
+// absolute distance from Value, or a fraction of Value when Relative is set.
+type NumericKey struct {
+	Value     float64 `json:"value"`
+	Tolerance float64 `json:"tolerance,omitempty"`
+	Relative  bool    `json:"relative,omitempty"`
+}
+
+// TextMatch is the comparison a TextRule applies to a short-text answer
+type TextMatch string
+
+const (
+	// MatchCaseInsensitive compares trimmed text ignoring case
+	MatchCaseInsensitive TextMatch = "case_insensitive"
+	// MatchNormalized also collapses runs of whitespace before comparing
+	MatchNormalized TextMatch = "normalized"
+	// MatchRegex matches the whole answer against Pattern as a regular expression
+	MatchRegex TextMatch = "regex"
+	// MatchEditDistance accepts normalized answers within MaxDistance edits of Pattern
+	MatchEditDistance TextMatch = "edit_distance"
+)
+
+// TextRule is one accepted answer to a short-text question. Score is the
+// fraction of the question's marks it earns; a rule without a score earns
+// full marks. When several rules match, the best score wins.
+type TextRule struct {
+	Match       TextMatch `json:"match"`
+	Pattern     string    `json:"pattern"`
+	MaxDistance int       `json:"max_distance,omitempty"`
+	Score       float64   `json:"score,omitempty"`
+}
+
+// Question represents a single question in a quiz. Choice questions use
+// Options with CorrectOption, or CorrectOptions for "select all that apply";
+// numeric questions use Numeric and short-text questions use TextRules.
+// When Kind is empty it is inferred from CorrectOptions for compatibility.
+// When the quiz has draws, a question in a Pool is only asked if it is drawn.
+// TimeLimitSeconds is advice for clients on how long to show the question;
+// it is not enforced when grading.
 type Question struct {
-	ID            string   `json:"id"`
-	Text          string   `json:"text"`
-	Options       []string `json:"options"`
-	CorrectOption int      `json:"correct_option,omitempty"`
-	Marks         int      `json:"marks"`
+	ID               string       `json:"id"`
+	Kind             QuestionKind `json:"kind,omitempty"`
+	Text             string       `json:"text"`
+	Options          []string     `json:"options"`
+	CorrectOption    int          `json:"correct_option"`
+	CorrectOptions   []int        `json:"correct_options,omitempty"`
+	Scoring          ScoringMode  `json:"scoring,omitempty"`
+	Numeric          *NumericKey  `json:"numeric,omitempty"`
+	TextRules        []TextRule   `json:"text_rules,omitempty"`
+	Marks            int          `json:"marks"`
+	Pool             string       `json:"pool,omitempty"`
+	TimeLimitSeconds int          `json:"time_limit_seconds,omitempty"`
 }
 
-// Answer represents a user's answer to a question
+// EffectiveKind returns the question's kind, inferring it when unset
+func (q *Question) EffectiveKind() QuestionKind {
+	if q.Kind != "" {
+		return q.Kind
+	}
+	if len(q.CorrectOptions) > 0 {
+		return KindMultipleSelect
+	}
+	return KindSingleChoice
+}
+
+// IsMultipleSelect reports whether the question accepts several options
+func (q *Question) IsMultipleSelect() bool {
+	return q.EffectiveKind() == KindMultipleSelect
+}
+
+// Answer represents a user's answer to a question. Only the field matching
+// the question's kind is read. Score is the credit it earned, which is
+// negative when a penalty was applied.
 type Answer struct {
-	QuestionID     string `json:"question_id"`
-	SelectedOption int    `json:"selected_option"`
-	IsCorrect      bool   `json:"is_correct"`
+	QuestionID      string   `json:"question_id"`
+	SelectedOption  int      `json:"selected_option"`
+	SelectedOptions []int    `json:"selected_options,omitempty"`
+	NumericValue    *float64 `json:"numeric_value,omitempty"`
+	Text            string   `json:"text,omitempty"`
+	IsCorrect       bool     `json:"is_correct"`
+	Score           float32  `json:"score"`
 }
 
-// Result represents the overall result of a user's quiz attempt
+// Result represents the overall result of a user's quiz attempt. The attempt
+// is open from StartedAt until it is finished or its Deadline passes.
 type Result struct {
-	QuizID  string            `json:"quiz_id"`
-	UserID  string            `json:"user_id"`
-	Score   float32           `json:"score"`
-	Answers map[string]Answer `json:"answers"`
+	QuizID         string            `json:"quiz_id"`
+	UserID         string            `json:"user_id"`
+	Score          float32           `json:"score"`
+	Answers        map[string]Answer `json:"answers"`
+	StartedAt      *time.Time        `json:"started_at,omitempty"`
+	Deadline       *time.Time        `json:"deadline,omitempty"`
+	FinishedAt     *time.Time        `json:"finished_at,omitempty"`
+	ElapsedSeconds float64           `json:"elapsed_seconds,omitempty"`
+}
+
+// IsFinished reports whether the attempt has been submitted or timed out
+func (r *Result) IsFinished() bool {
+	return r.FinishedAt != nil
+}
+
+// IsOver reports whether the attempt is finished or its deadline has passed
+// by time now, even if storage has not yet recorded it as finished
+func (r *Result) IsOver(now time.Time) bool {
+	return r.IsFinished() || (r.Deadline != nil && now.After(*r.Deadline))
 }
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+)
+
+// Paper is a quiz as assembled for one user: the drawn questions in the
+// order that user sees them, each with its options in display order.
+type Paper struct {
+	// Quiz holds the drawn questions in display order. Option lists are
+	// reordered and answer keys remapped to match.
+	Quiz *Quiz
+	// optionOrder maps a question ID to the canonical index of each option
+	// in display order, for questions whose options were shuffled
+	optionOrder map[string][]int
+}
+
+// IsRandomized reports whether users get different papers for the quiz
+func (q *Quiz) IsRandomized() bool {
+	return len(q.Draws) > 0 || q.ShuffleQuestions || q.ShuffleOptions
+}
+
+// PaperFor assembles the paper userID sees. The same quiz and user always
+// produce the same paper, so it never needs to be stored; editing the
+// quiz's questions, draws or seed may change it.
+//
+// Questions outside any pool are always asked. When the quiz has draws,
+// each draw picks Count questions from its pool, or the whole pool if it
+// is smaller, and questions in undrawn pools are left out.
+func (q *Quiz) PaperFor(userID string) *Paper {
+	quiz := q.Clone()
+	paper := &Paper{Quiz: quiz, optionOrder: make(map[string][]int)}
+	if !q.IsRandomized() {
+		return paper
+	}
+	rng := rand.New(rand.NewPCG(paperSeed(q.ID, userID), uint64(q.Seed)))
+
+	questions := quiz.Questions
+	if len(q.Draws) > 0 {
+		picked := make(map[string]bool)
+		for _, draw := range q.Draws {
+			var pool []string
+			for _, question := range questions {
+				if question.Pool == draw.Pool && draw.Pool != "" && !picked[question.ID] {
+					pool = append(pool, question.ID)
+				}
+			}
+			rng.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+			for _, id := range pool[:min(draw.Count, len(pool))] {
+				picked[id] = true
+			}
+		}
+		drawn := make([]Question, 0, len(questions))
+		for _, question := range questions {
+			if question.Pool == "" || picked[question.ID] {
+				drawn = append(drawn, question)
+			}
+		}
+		questions = drawn
+	}
+
+	if q.ShuffleQuestions {
+		rng.Shuffle(len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	}
+
+	if q.ShuffleOptions {
+		for i := range questions {
+			question := &questions[i]
+			if kind := question.EffectiveKind(); kind != KindSingleChoice && kind != KindMultipleSelect {
+				continue
+			}
+			order := rng.Perm(len(question.Options))
+			paper.optionOrder[question.ID] = order
+			shuffleOptions(question, order)
+		}
+	}
+	quiz.Questions = questions
+	return paper
+}
+
+// Question returns the question with the given ID if it is on the paper
+func (p *Paper) Question(id string) *Question {
+	for i := range p.Quiz.Questions {
+		if p.Quiz.Questions[i].ID == id {
+			return &p.Quiz.Questions[i]
+		}
+	}
+	return nil
+}
+
+// ToCanonical rewrites the option indexes in answer from the paper's display
+// order to the quiz's canonical order. Indexes that do not name an option
+// are left alone for grading to reject.
+func (p *Paper) ToCanonical(answer *Answer) {
+	if order, ok := p.optionOrder[answer.QuestionID]; ok {
+		remapOptions(answer, order)
+	}
+}
+
+// ToDisplay rewrites the option indexes in answer from the quiz's canonical
+// order back to the paper's display order, undoing ToCanonical
+func (p *Paper) ToDisplay(answer *Answer) {
+	order, ok := p.optionOrder[answer.QuestionID]
+	if !ok {
+		return
+	}
+	display := make([]int, len(order))
+	for i, canonical := range order {
+		display[canonical] = i
+	}
+	remapOptions(answer, display)
+}
+
+// remapOptions replaces each option index i in answer with to[i], leaving
+// indexes out of range alone. SelectedOptions is copied, not changed.
+func remapOptions(answer *Answer, to []int) {
+	remap := func(i int) int {
+		if i < 0 || i >= len(to) {
+			return i
+		}
+		return to[i]
+	}
+	answer.SelectedOption = remap(answer.SelectedOption)
+	if answer.SelectedOptions != nil {
+		selected := make([]int, len(answer.SelectedOptions))
+		for i, option := range answer.SelectedOptions {
+			selected[i] = remap(option)
+		}
+		answer.SelectedOptions = selected
+	}
+}
+
+// shuffleOptions puts question's options in the given order, where order[i]
+// is the canonical index of the option shown at i, and remaps its answer key
+func shuffleOptions(question *Question, order []int) {
+	display := make([]int, len(order))
+	options := make([]string, len(order))
+	for i, canonical := range order {
+		options[i] = question.Options[canonical]
+		display[canonical] = i
+	}
+	question.Options = options
+	if question.CorrectOption >= 0 && question.CorrectOption < len(display) {
+		question.CorrectOption = display[question.CorrectOption]
+	}
+	for i, correct := range question.CorrectOptions {
+		if correct >= 0 && correct < len(display) {
+			question.CorrectOptions[i] = display[correct]
+		}
+	}
+}
+
+// paperSeed derives the shuffle seed for a user's paper
+func paperSeed(quizID, userID string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(quizID))
+	h.Write([]byte{0})
+	h.Write([]byte(userID))
+	return h.Sum64()
+}
@@ -0,0 +1,161 @@
+package models
+
+import "time"
+
+// AnswerView is an answer as shown to the user who gave it. The grading
+// fields are left out while feedback is withheld.
+type AnswerView struct {
+	QuestionID      string   `json:"question_id"`
+	SelectedOption  int      `json:"selected_option"`
+	SelectedOptions []int    `json:"selected_options,omitempty"`
+	NumericValue    *float64 `json:"numeric_value,omitempty"`
+	Text            string   `json:"text,omitempty"`
+	IsCorrect       *bool    `json:"is_correct,omitempty"`
+	Score           *float32 `json:"score,omitempty"`
+	CorrectAnswer   string   `json:"correct_answer,omitempty"`
+}
+
+// ResultView is a result as shown to its user, redacted according to the
+// quiz's feedback mode. Score is left out until it may be shown.
+type ResultView struct {
+	QuizID           string                `json:"quiz_id"`
+	UserID           string                `json:"user_id"`
+	Score            *float32              `json:"score,omitempty"`
+	Answers          map[string]AnswerView `json:"answers"`
+	StartedAt        *time.Time            `json:"started_at,omitempty"`
+	Deadline         *time.Time            `json:"deadline,omitempty"`
+	FinishedAt       *time.Time            `json:"finished_at,omitempty"`
+	ElapsedSeconds   float64               `json:"elapsed_seconds,omitempty"`
+	FeedbackMode     FeedbackMode          `json:"feedback_mode,omitempty"`
+	FeedbackReleased bool                  `json:"feedback_released"`
+}
+
+// NewResultView builds the view of result that its user may see at time now.
+// Once feedback is released each answer carries its grading and the correct
+// answer; under FeedbackNever the total score alone is shown once the
+// attempt is over. Selected options are numbered as the user's paper showed
+// them, not in the quiz's canonical order.
+func NewResultView(quiz *Quiz, result *Result, now time.Time) ResultView {
+	released := quiz.FeedbackReleased(result, now)
+	view := ResultView{
+		QuizID:           result.QuizID,
+		UserID:           result.UserID,
+		Answers:          make(map[string]AnswerView, len(result.Answers)),
+		StartedAt:        result.StartedAt,
+		Deadline:         result.Deadline,
+		FinishedAt:       result.FinishedAt,
+		ElapsedSeconds:   result.ElapsedSeconds,
+		FeedbackMode:     quiz.FeedbackMode,
+		FeedbackReleased: released,
+	}
+	if quiz.ScoreReleased(result, now) {
+		score := result.Score
+		view.Score = &score
+	}
+
+	questions := make(map[string]*Question, len(quiz.Questions))
+	for i := range quiz.Questions {
+		questions[quiz.Questions[i].ID] = &quiz.Questions[i]
+	}
+
+	paper := quiz.PaperFor(result.UserID)
+	for id, answer := range result.Answers {
+		paper.ToDisplay(&answer)
+		av := AnswerView{
+			QuestionID:      answer.QuestionID,
+			SelectedOption:  answer.SelectedOption,
+			SelectedOptions: answer.SelectedOptions,
+			NumericValue:    answer.NumericValue,
+			Text:            answer.Text,
+		}
+		if released {
+			isCorrect, score := answer.IsCorrect, answer.Score
+			av.IsCorrect, av.Score = &isCorrect, &score
+			if question, ok := questions[id]; ok && !answer.IsCorrect {
+				av.CorrectAnswer, _ = question.CorrectAnswer()
+			}
+		}
+		view.Answers[id] = av
+	}
+	return view
+}
+
+// TakerQuestion is a question as shown to someone taking the quiz. It never
+// carries the answer key, and Marks is only set when the quiz shows marks.
+type TakerQuestion struct {
+	ID               string       `json:"id"`
+	Kind             QuestionKind `json:"kind"`
+	Text             string       `json:"text"`
+	Options          []string     `json:"options,omitempty"`
+	Scoring          ScoringMode  `json:"scoring,omitempty"`
+	Marks            *int         `json:"marks,omitempty"`
+	TimeLimitSeconds int          `json:"time_limit_seconds,omitempty"`
+}
+
+// TakerQuiz is a quiz as shown to someone taking it
+type TakerQuiz struct {
+	ID                string          `json:"id"`
+	Title             string          `json:"title"`
+	Questions         []TakerQuestion `json:"questions"`
+	IsNegativeMarking bool            `json:"is_negative_marking"`
+	Penalty           float32         `json:"penalty"`
+	DurationSeconds   int             `json:"duration_seconds,omitempty"`
+	OpensAt           *time.Time      `json:"opens_at,omitempty"`
+	ClosesAt          *time.Time      `json:"closes_at,omitempty"`
+	FeedbackMode      FeedbackMode    `json:"feedback_mode,omitempty"`
+	Version           int             `json:"version"`
+}
+
+// NewTakerQuiz projects quiz into the view shown to takers. The result
+// shares nothing with quiz.
+func NewTakerQuiz(quiz *Quiz) TakerQuiz {
+	quiz = quiz.Clone()
+	view := TakerQuiz{
+		ID:                quiz.ID,
+		Title:             quiz.Title,
+		Questions:         make([]TakerQuestion, len(quiz.Questions)),
+		IsNegativeMarking: quiz.IsNegativeMarking,
+		Penalty:           quiz.Penalty,
+		DurationSeconds:   quiz.DurationSeconds,
+		OpensAt:           quiz.OpensAt,
+		ClosesAt:          quiz.ClosesAt,
+		FeedbackMode:      quiz.FeedbackMode,
+		Version:           quiz.Version,
+	}
+	for i, q := range quiz.Questions {
+		tq := TakerQuestion{
+			ID:               q.ID,
+			Kind:             q.EffectiveKind(),
+			Text:             q.Text,
+			Options:          q.Options,
+			TimeLimitSeconds: q.TimeLimitSeconds,
+		}
+		if tq.Kind == KindMultipleSelect {
+			tq.Scoring = q.Scoring
+		}
+		if quiz.ShowMarks {
+			marks := q.Marks
+			tq.Marks = &marks
+		}
+		view.Questions[i] = tq
+	}
+	return view
+}
+
+// AuthorQuiz is a quiz as shown to its author: the full definition,
+// including answer keys, with totals for convenience
+type AuthorQuiz struct {
+	*Quiz
+	QuestionCount int `json:"question_count"`
+	TotalMarks    int `json:"total_marks"`
+}
+
+// NewAuthorQuiz projects quiz into the view shown to its author. The result
+// shares nothing with quiz.
+func NewAuthorQuiz(quiz *Quiz) AuthorQuiz {
+	view := AuthorQuiz{Quiz: quiz.Clone(), QuestionCount: len(quiz.Questions)}
+	for _, q := range quiz.Questions {
+		view.TotalMarks += q.Marks
+	}
+	return view
+}
@@ -0,0 +1,307 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// stateCookie binds a login to the browser that started it
+	stateCookie = "quiz_oidc_state"
+	// loginTimeout is how long a user has to complete a login at the provider
+	loginTimeout = 10 * time.Minute
+	// defaultMaxPendingLogins caps the logins kept waiting for a callback
+	defaultMaxPendingLogins = 10000
+)
+
+// ErrLoginState is returned when a callback does not match a login this
+// browser started, or the login has expired
+var ErrLoginState = errors.New("oidc: unknown or expired login")
+
+// ErrTooManyLogins is returned when so many logins are waiting for a
+// callback that no more can be started until some complete or expire
+var ErrTooManyLogins = errors.New("oidc: too many logins in progress")
+
+// Config registers the server as a client of the provider. UserClaim,
+// RoleClaim and OrgClaim name the ID token claims that identify the user,
+// their role and their organization; they default to "sub", "role" and
+// "org". MaxPendingLogins caps the logins waiting for a callback, which
+// anyone can start; it defaults to 10000.
+type Config struct {
+	ClientID         string
+	ClientSecret     string
+	RedirectURL      string
+	Scopes           []string
+	UserClaim        string
+	RoleClaim        string
+	OrgClaim         string
+	MaxPendingLogins int
+}
+
+// Identity is the user an ID token vouches for. Roles holds the role
+// claim, which providers send as a string or, for groups, a list. Org is
+// empty when the token has no organization claim.
+type Identity struct {
+	Subject string
+	Roles   []string
+	Org     string
+	Expiry  time.Time
+}
+
+// pendingLogin is a login that has been sent to the provider
+type pendingLogin struct {
+	nonce    string
+	verifier string
+	expires  time.Time
+}
+
+// RelyingParty signs users in with a provider and verifies its ID tokens
+type RelyingParty struct {
+	provider  *Provider
+	cfg       Config
+	mu        sync.Mutex
+	pending   map[string]pendingLogin // by state
+	lastPrune time.Time
+}
+
+// New creates a relying party for provider
+func New(provider *Provider, cfg Config) *RelyingParty {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid"}
+	}
+	if cfg.UserClaim == "" {
+		cfg.UserClaim = "sub"
+	}
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = "role"
+	}
+	if cfg.OrgClaim == "" {
+		cfg.OrgClaim = "org"
+	}
+	if cfg.MaxPendingLogins <= 0 {
+		cfg.MaxPendingLogins = defaultMaxPendingLogins
+	}
+	return &RelyingParty{provider: provider, cfg: cfg, pending: make(map[string]pendingLogin)}
+}
+
+// Issuer returns the issuer of the ID tokens the relying party accepts
+func (rp *RelyingParty) Issuer() string {
+	return rp.provider.Issuer
+}
+
+// Login redirects the browser to the provider to sign in. The state, nonce
+// and PKCE verifier of the login are kept until the provider redirects back
+// to the callback. When the maximum number of logins are already waiting
+// it writes nothing and returns ErrTooManyLogins.
+func (rp *RelyingParty) Login(w http.ResponseWriter, r *http.Request) error {
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	now := time.Now()
+	rp.mu.Lock()
+	if len(rp.pending) >= rp.cfg.MaxPendingLogins {
+		rp.prune(now)
+	}
+	if len(rp.pending) >= rp.cfg.MaxPendingLogins {
+		rp.mu.Unlock()
+		return ErrTooManyLogins
+	}
+	rp.pending[state] = pendingLogin{nonce: nonce, verifier: verifier, expires: now.Add(loginTimeout)}
+	rp.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   int(loginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {rp.cfg.ClientID},
+		"redirect_uri":          {rp.cfg.RedirectURL},
+		"scope":                 {strings.Join(rp.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	target := rp.provider.AuthorizationEndpoint
+	if strings.Contains(target, "?") {
+		target += "&" + query.Encode()
+	} else {
+		target += "?" + query.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+	return nil
+}
+
+// prune drops expired logins, at most once a minute so that a full map is
+// not scanned on every login
+func (rp *RelyingParty) prune(now time.Time) {
+	if now.Sub(rp.lastPrune) < time.Minute {
+		return
+	}
+	rp.lastPrune = now
+	for state, login := range rp.pending {
+		if now.After(login.expires) {
+			delete(rp.pending, state)
+		}
+	}
+}
+
+// Callback completes a login when the provider redirects back: it checks
+// the state against the browser's cookie, exchanges the code for tokens and
+// verifies the ID token. It returns the identity and the raw ID token.
+func (rp *RelyingParty) Callback(w http.ResponseWriter, r *http.Request) (*Identity, string, error) {
+	query := r.URL.Query()
+	state := query.Get("state")
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		return nil, "", ErrLoginState
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/", MaxAge: -1})
+
+	rp.mu.Lock()
+	login, ok := rp.pending[state]
+	delete(rp.pending, state)
+	rp.mu.Unlock()
+	if !ok || time.Now().After(login.expires) {
+		return nil, "", ErrLoginState
+	}
+
+	if errCode := query.Get("error"); errCode != "" {
+		return nil, "", fmt.Errorf("oidc: provider refused login: %s %s", errCode, query.Get("error_description"))
+	}
+	rawIDToken, err := rp.exchange(r.Context(), query.Get("code"), login.verifier)
+	if err != nil {
+		return nil, "", err
+	}
+	identity, err := rp.verify(r.Context(), rawIDToken, login.nonce)
+	if err != nil {
+		return nil, "", err
+	}
+	return identity, rawIDToken, nil
+}
+
+// exchange redeems an authorization code at the token endpoint and returns
+// the ID token
+func (rp *RelyingParty) exchange(ctx context.Context, code, verifier string) (string, error) {
+	if code == "" {
+		return "", errors.New("oidc: callback has no code")
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {rp.cfg.RedirectURL},
+		"client_id":     {rp.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rp.provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if rp.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(rp.cfg.ClientID), url.QueryEscape(rp.cfg.ClientSecret))
+	}
+
+	resp, err := rp.provider.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oidc: token exchange: %w", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("oidc: token exchange: %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc: token exchange: %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("oidc: token exchange: response has no ID token")
+	}
+	return body.IDToken, nil
+}
+
+// Verify checks an ID token presented by a client: its signature against
+// the provider's keys, its issuer, audience and expiry.
+func (rp *RelyingParty) Verify(ctx context.Context, rawIDToken string) (*Identity, error) {
+	return rp.verify(ctx, rawIDToken, "")
+}
+
+// verify checks an ID token as Verify does, and also its nonce unless
+// nonce is empty
+func (rp *RelyingParty) verify(ctx context.Context, rawIDToken, nonce string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return rp.provider.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(rp.provider.Issuer),
+		jwt.WithAudience(rp.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid ID token: %w", err)
+	}
+
+	// A token issued to several clients must name us as the party it was
+	// authorized for
+	if aud, _ := claims.GetAudience(); len(aud) > 1 && claims["azp"] != rp.cfg.ClientID {
+		return nil, errors.New("oidc: invalid ID token: authorized party is not this client")
+	}
+	if nonce != "" && claims["nonce"] != nonce {
+		return nil, errors.New("oidc: invalid ID token: nonce does not match login")
+	}
+
+	subject, _ := claims[rp.cfg.UserClaim].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("oidc: invalid ID token: no %s claim", rp.cfg.UserClaim)
+	}
+	expiry, _ := claims.GetExpirationTime()
+	org, _ := claims[rp.cfg.OrgClaim].(string)
+	identity := &Identity{Subject: subject, Org: org, Expiry: expiry.Time}
+	switch roles := claims[rp.cfg.RoleClaim].(type) {
+	case string:
+		identity.Roles = []string{roles}
+	case []interface{}:
+		for _, role := range roles {
+			if s, ok := role.(string); ok {
+				identity.Roles = append(identity.Roles, s)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// randomString returns 32 random bytes encoded for use in URLs
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
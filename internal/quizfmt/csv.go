@@ -0,0 +1,261 @@
+package quizfmt
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"quiz-app/internal/models"
+)
+
+// CSVMapping says which spreadsheet columns hold each part of a question.
+// A column is named by its header, matched ignoring case and, failing an
+// exact match, by a unique header prefix so that templates such as
+// Kahoot's "Question - max 120 characters" match "Question". A column may
+// also be given by its 1-based number. Optional columns may be left empty.
+type CSVMapping struct {
+	Text      string   // required
+	Options   []string // when empty, every "Answer N" or "Option N" column in order
+	Correct   string   // required: option numbers (1-based) or letters, separated by commas
+	ID        string
+	Marks     string
+	TimeLimit string // seconds, optionally suffixed with "s"
+}
+
+// DefaultCSVMapping matches Kahoot-style question templates
+func DefaultCSVMapping() CSVMapping {
+	return CSVMapping{Text: "Question", Correct: "Correct", Marks: "Marks", TimeLimit: "Time limit", ID: "ID"}
+}
+
+// RowReport is the outcome of importing one spreadsheet row. Row is the
+// 1-based record number in the file.
+type RowReport struct {
+	Row        int      `json:"row"`
+	QuestionID string   `json:"question_id,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// ParseCSV builds a quiz from a spreadsheet of questions, one per row,
+// reporting on every row. Rows above the header row, which is the first row
+// naming the text column, are ignored, as are blank rows. Empty option cells
+// are skipped, so a true/false question may fill only two option columns.
+//
+// The returned error is for problems with the file as a whole, such as a
+// missing column. The quiz is only usable when no row has errors.
+func ParseCSV(r io.Reader, mapping CSVMapping) (*models.Quiz, []RowReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := -1
+	for i, record := range records {
+		if _, ok := findColumn(record, mapping.Text); ok {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return nil, nil, fmt.Errorf("no header row with a %q column", mapping.Text)
+	}
+	columns, err := resolveCSVColumns(records[header], mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quiz := &models.Quiz{Questions: []models.Question{}}
+	var reports []RowReport
+	for i := header + 1; i < len(records); i++ {
+		record := records[i]
+		if blankRecord(record) {
+			continue
+		}
+		question, problems := columns.question(record)
+		if question.ID == "" {
+			question.ID = "q" + strconv.Itoa(len(quiz.Questions)+1)
+		}
+		reports = append(reports, RowReport{Row: i + 1, QuestionID: question.ID, Errors: problems})
+		quiz.Questions = append(quiz.Questions, question)
+	}
+	return quiz, reports, nil
+}
+
+// csvColumns are the resolved column indexes of a mapping; -1 means absent
+type csvColumns struct {
+	text, correct, id, marks, timeLimit int
+	options                             []int
+}
+
+func resolveCSVColumns(header []string, mapping CSVMapping) (csvColumns, error) {
+	var cols csvColumns
+	var missing []string
+	find := func(name string, required bool) int {
+		if name == "" {
+			return -1
+		}
+		i, ok := findColumn(header, name)
+		if !ok && required {
+			missing = append(missing, name)
+		}
+		return i
+	}
+	cols.text = find(mapping.Text, true)
+	cols.correct = find(mapping.Correct, true)
+	cols.id = find(mapping.ID, false)
+	cols.marks = find(mapping.Marks, false)
+	cols.timeLimit = find(mapping.TimeLimit, false)
+
+	if len(mapping.Options) > 0 {
+		for _, name := range mapping.Options {
+			cols.options = append(cols.options, find(name, true))
+		}
+	} else {
+		for i, cell := range header {
+			name := strings.ToLower(strings.TrimSpace(cell))
+			for _, prefix := range []string{"answer ", "option "} {
+				if rest, ok := strings.CutPrefix(name, prefix); ok && rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+					cols.options = append(cols.options, i)
+				}
+			}
+		}
+		if len(cols.options) == 0 {
+			missing = append(missing, "Answer 1")
+		}
+	}
+	if len(missing) > 0 {
+		return cols, fmt.Errorf("missing column %s", strings.Join(missing, ", "))
+	}
+	return cols, nil
+}
+
+// findColumn locates a column by number, header or unique header prefix
+func findColumn(header []string, name string) (int, bool) {
+	if n, err := strconv.Atoi(name); err == nil {
+		return n - 1, n >= 1 && n <= len(header)
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	prefixed := -1
+	for i, cell := range header {
+		cell = strings.ToLower(strings.TrimSpace(cell))
+		if cell == name {
+			return i, true
+		}
+		if strings.HasPrefix(cell, name) {
+			if prefixed >= 0 {
+				prefixed = -2
+			} else if prefixed == -1 {
+				prefixed = i
+			}
+		}
+	}
+	return prefixed, prefixed >= 0
+}
+
+// question reads one row, returning the problems with it
+func (c csvColumns) question(record []string) (models.Question, []string) {
+	cell := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	var problems []string
+	question := models.Question{ID: cell(c.id), Text: cell(c.text), Marks: 1}
+	if question.Text == "" {
+		problems = append(problems, "question text is empty")
+	}
+
+	// Map option column positions to the compacted option list
+	position := make(map[int]int)
+	for n, col := range c.options {
+		if text := cell(col); text != "" {
+			position[n] = len(question.Options)
+			question.Options = append(question.Options, text)
+		}
+	}
+	if len(question.Options) < 2 {
+		problems = append(problems, "at least two options are required")
+	}
+
+	correct, err := parseCorrectOptions(cell(c.correct))
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	var indexes []int
+	for _, n := range correct {
+		i, ok := position[n]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("correct answer %d is not a filled-in option", n+1))
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	if len(indexes) > 1 {
+		question.Kind = models.KindMultipleSelect
+		question.CorrectOptions = indexes
+	} else {
+		question.Kind = models.KindSingleChoice
+		if len(indexes) == 1 {
+			question.CorrectOption = indexes[0]
+		}
+	}
+
+	if v := cell(c.marks); v != "" {
+		marks, err := strconv.Atoi(v)
+		if err != nil || marks < 1 {
+			problems = append(problems, fmt.Sprintf("marks %q must be a positive whole number", v))
+		}
+		question.Marks = marks
+	}
+	if v := cell(c.timeLimit); v != "" {
+		seconds, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(v), "s"))
+		if err != nil || seconds < 0 {
+			problems = append(problems, fmt.Sprintf("time limit %q must be a number of seconds", v))
+		}
+		question.TimeLimitSeconds = seconds
+	}
+	return question, problems
+}
+
+// parseCorrectOptions reads "2", "B", "1,3" or "A; C" as 0-based option
+// column positions
+func parseCorrectOptions(s string) ([]int, error) {
+	if s == "" {
+		return nil, errors.New("correct answer is empty")
+	}
+	var options []int
+	seen := make(map[int]bool)
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		var n int
+		if number, err := strconv.Atoi(field); err == nil {
+			n = number - 1
+		} else if len(field) == 1 && strings.ToUpper(field)[0] >= 'A' && strings.ToUpper(field)[0] <= 'Z' {
+			n = int(strings.ToUpper(field)[0] - 'A')
+		} else {
+			return nil, fmt.Errorf("correct answer %q is not an option number or letter", field)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("correct answer %q is not an option number or letter", field)
+		}
+		if !seen[n] {
+			seen[n] = true
+			options = append(options, n)
+		}
+	}
+	return options, nil
+}
+
+func blankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+// Package quizfmt converts quizzes to and from the interchange formats that
+// authoring tools and learning management systems use.
+package quizfmt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LineError is a problem found at a line of the input
+type LineError struct {
+	Line int    `json:"line"`
+	Msg  string `json:"message"`
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// ErrorList collects every problem found in an input, in line order
+type ErrorList []*LineError
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// add records a problem at line
+func (l *ErrorList) add(line int, format string, args ...any) {
+	*l = append(*l, &LineError{Line: line, Msg: fmt.Sprintf(format, args...)})
+}
+
+// err returns the list in line order as an error, or nil if it is empty
+func (l ErrorList) err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	sort.SliceStable(l, func(i, j int) bool { return l[i].Line < l[j].Line })
+	return l
+}
@@ -0,0 +1,477 @@
+package quizfmt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"quiz-app/internal/models"
+)
+
+// ParseGIFT reads questions in Moodle's GIFT format. Multiple choice
+// questions become single choice, or multiple select when answers carry
+// weights; true/false becomes a single choice between "True" and "False";
+// short answer becomes short text matched case-insensitively; numeric keeps
+// its tolerance or range. Question titles become question IDs and a
+// $CATEGORY becomes the quiz title. Every question is worth one mark.
+//
+// Feedback and text formats such as [html] are dropped. Problems are
+// reported as an ErrorList with the line of each.
+func ParseGIFT(r io.Reader) (*models.Quiz, error) {
+	quiz := &models.Quiz{Questions: []models.Question{}}
+	var errs ErrorList
+
+	blocks, err := giftBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		if category, ok := strings.CutPrefix(b.text, "$CATEGORY:"); ok {
+			if quiz.Title == "" {
+				quiz.Title = strings.TrimSpace(category)
+			}
+			continue
+		}
+		question, ok := parseGIFTQuestion(b, &errs)
+		if !ok {
+			continue
+		}
+		if question.ID == "" {
+			question.ID = "q" + strconv.Itoa(len(quiz.Questions)+1)
+		}
+		quiz.Questions = append(quiz.Questions, question)
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	return quiz, nil
+}
+
+// giftBlock is the text of one question and the line it starts on
+type giftBlock struct {
+	line int
+	text string
+}
+
+// giftBlocks splits the input into questions at blank lines, dropping
+// comment lines
+func giftBlocks(r io.Reader) ([]giftBlock, error) {
+	var blocks []giftBlock
+	var current []string
+	start := 0
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, giftBlock{line: start, text: strings.Join(current, "\n")})
+			current = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "//"):
+		case trimmed == "":
+			flush()
+		default:
+			if len(current) == 0 {
+				start = n
+			}
+			current = append(current, line)
+		}
+	}
+	flush()
+	return blocks, scanner.Err()
+}
+
+func parseGIFTQuestion(b giftBlock, errs *ErrorList) (models.Question, bool) {
+	question := models.Question{Marks: 1}
+	text := strings.TrimLeft(b.text, " \t")
+
+	if strings.HasPrefix(text, "::") {
+		end := indexUnescaped(text[2:], "::")
+		if end < 0 {
+			errs.add(b.line, "unterminated question title")
+			return question, false
+		}
+		question.ID = strings.TrimSpace(unescapeGIFT(text[2 : 2+end]))
+		text = text[2+end+2:]
+	}
+
+	open := indexUnescaped(text, "{")
+	if open < 0 {
+		errs.add(b.line, "missing answer block")
+		return question, false
+	}
+	// text is a suffix of the block, so this is the line the answers start on
+	answerLine := b.line + strings.Count(b.text[:len(b.text)-len(text)+open], "\n")
+	closing := indexUnescaped(text[open:], "}")
+	if closing < 0 {
+		errs.add(answerLine, "unterminated answer block")
+		return question, false
+	}
+	closing += open
+
+	stem := cleanGIFTText(text[:open])
+	if after := cleanGIFTText(text[closing+1:]); after != "" {
+		// Missing word format: the answer goes in the gap
+		stem += " _____ " + after
+	}
+	question.Text = strings.TrimSpace(stem)
+	if question.Text == "" {
+		errs.add(b.line, "question has no text")
+		return question, false
+	}
+
+	if err := parseGIFTAnswers(&question, strings.TrimSpace(text[open+1:closing])); err != "" {
+		errs.add(answerLine, "%s", err)
+		return question, false
+	}
+	return question, true
+}
+
+// parseGIFTAnswers fills in the kind and answer key of question from the
+// contents of its answer block, returning a description of any problem
+func parseGIFTAnswers(question *models.Question, block string) string {
+	feedbackless, _ := splitUnescaped(block, '#')
+	switch strings.ToUpper(strings.TrimSpace(feedbackless)) {
+	case "T", "TRUE", "F", "FALSE":
+		question.Kind = models.KindSingleChoice
+		question.Options = []string{"True", "False"}
+		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(feedbackless)), "F") {
+			question.CorrectOption = 1
+		}
+		return ""
+	}
+	if strings.HasPrefix(block, "#") {
+		return parseGIFTNumeric(question, block[1:])
+	}
+
+	answers, problem := giftAnswers(block)
+	if problem != "" {
+		return problem
+	}
+	if len(answers) == 0 {
+		return "empty answer block"
+	}
+
+	choice, weighted := false, false
+	for _, a := range answers {
+		choice = choice || a.op == '~'
+		weighted = weighted || (a.op == '~' && a.weight > 0)
+	}
+
+	if !choice {
+		question.Kind = models.KindShortText
+		for _, a := range answers {
+			rule := models.TextRule{Match: models.MatchCaseInsensitive, Pattern: a.text}
+			if a.hasWeight && a.weight != 100 {
+				rule.Score = a.weight / 100
+			}
+			question.TextRules = append(question.TextRules, rule)
+		}
+		return ""
+	}
+
+	correct := []int{}
+	for i, a := range answers {
+		question.Options = append(question.Options, a.text)
+		if (a.op == '=' && (!a.hasWeight || a.weight > 0)) || (a.op == '~' && a.weight > 0) {
+			correct = append(correct, i)
+		}
+	}
+	switch {
+	case len(correct) == 0:
+		return "multiple choice question has no correct answer"
+	case len(correct) == 1 && !weighted:
+		question.Kind = models.KindSingleChoice
+		question.CorrectOption = correct[0]
+	default:
+		question.Kind = models.KindMultipleSelect
+		question.CorrectOptions = correct
+		question.Scoring = models.ScoringProportional
+	}
+	return ""
+}
+
+// parseGIFTNumeric reads a numeric answer block such as "3.14:0.01",
+// "1..5" or "=3:0.1 =%50%3:1". The first answer worth full marks is the key.
+func parseGIFTNumeric(question *models.Question, block string) string {
+	question.Kind = models.KindNumeric
+	block = strings.TrimSpace(block)
+	var answers []giftAnswer
+	if strings.HasPrefix(block, "=") {
+		var problem string
+		if answers, problem = giftAnswers(block); problem != "" {
+			return problem
+		}
+	} else {
+		text, _ := splitUnescaped(block, '#')
+		answers = []giftAnswer{{op: '=', text: strings.TrimSpace(text)}}
+	}
+
+	for _, a := range answers {
+		if a.hasWeight && a.weight != 100 {
+			continue
+		}
+		key, ok := parseNumericKey(a.text)
+		if !ok {
+			return fmt.Sprintf("invalid numeric answer %q", a.text)
+		}
+		question.Numeric = key
+		return ""
+	}
+	return "numeric question has no answer worth full marks"
+}
+
+func parseNumericKey(s string) (*models.NumericKey, bool) {
+	if lo, hi, ok := strings.Cut(s, ".."); ok {
+		min, err1 := strconv.ParseFloat(strings.TrimSpace(lo), 64)
+		max, err2 := strconv.ParseFloat(strings.TrimSpace(hi), 64)
+		if err1 != nil || err2 != nil || max < min {
+			return nil, false
+		}
+		return &models.NumericKey{Value: (min + max) / 2, Tolerance: (max - min) / 2}, true
+	}
+	value, tolerance, _ := strings.Cut(s, ":")
+	key := &models.NumericKey{}
+	var err error
+	if key.Value, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+		return nil, false
+	}
+	if tolerance != "" {
+		if key.Tolerance, err = strconv.ParseFloat(strings.TrimSpace(tolerance), 64); err != nil || key.Tolerance < 0 {
+			return nil, false
+		}
+	}
+	return key, true
+}
+
+// giftAnswer is one "=answer" or "~answer" of an answer block. weight is
+// the %n% percentage in front of the answer, if any.
+type giftAnswer struct {
+	op        byte
+	weight    float64
+	hasWeight bool
+	text      string
+}
+
+// giftAnswers splits an answer block into its answers, dropping feedback
+func giftAnswers(block string) ([]giftAnswer, string) {
+	var answers []giftAnswer
+	var current *giftAnswer
+	var text strings.Builder
+	finish := func() string {
+		if current == nil {
+			return ""
+		}
+		body, _ := splitUnescaped(text.String(), '#')
+		body = strings.TrimSpace(body)
+		if strings.HasPrefix(body, "%") {
+			end := strings.Index(body[1:], "%")
+			if end < 0 {
+				return "unterminated answer weight"
+			}
+			weight, err := strconv.ParseFloat(body[1:1+end], 64)
+			if err != nil {
+				return fmt.Sprintf("invalid answer weight %q", body[1:1+end])
+			}
+			current.weight, current.hasWeight = weight, true
+			body = strings.TrimSpace(body[2+end:])
+		}
+		current.text = unescapeGIFT(body)
+		if current.text == "" {
+			return "empty answer"
+		}
+		answers = append(answers, *current)
+		text.Reset()
+		return ""
+	}
+
+	for i := 0; i < len(block); i++ {
+		c := block[i]
+		switch {
+		case c == '\\' && i+1 < len(block):
+			text.WriteByte(c)
+			text.WriteByte(block[i+1])
+			i++
+		case c == '=' || c == '~':
+			if problem := finish(); problem != "" {
+				return nil, problem
+			}
+			current = &giftAnswer{op: c}
+		case current == nil:
+			if c != ' ' && c != '\t' && c != '\n' {
+				return nil, "answers must start with = or ~"
+			}
+		default:
+			text.WriteByte(c)
+		}
+	}
+	if problem := finish(); problem != "" {
+		return nil, problem
+	}
+	return answers, ""
+}
+
+// WriteGIFT writes quiz in GIFT format. Question IDs become titles and the
+// quiz title becomes the $CATEGORY. Quiz settings such as negative marking
+// and per-question marks have no GIFT equivalent and are not written.
+// Short-text rules that match by regular expression or edit distance cannot
+// be expressed and are reported as an error.
+func WriteGIFT(w io.Writer, quiz *models.Quiz) error {
+	bw := bufio.NewWriter(w)
+	if quiz.Title != "" {
+		fmt.Fprintf(bw, "$CATEGORY: %s\n\n", strings.ReplaceAll(quiz.Title, "\n", " "))
+	}
+	for i := range quiz.Questions {
+		q := &quiz.Questions[i]
+		answers, err := giftAnswerBlock(q)
+		if err != nil {
+			return fmt.Errorf("question %s: %w", q.ID, err)
+		}
+		fmt.Fprintf(bw, "::%s:: %s {%s}\n\n", escapeGIFT(q.ID), escapeGIFT(q.Text), answers)
+	}
+	return bw.Flush()
+}
+
+func giftAnswerBlock(q *models.Question) (string, error) {
+	var b strings.Builder
+	switch q.EffectiveKind() {
+	case models.KindNumeric:
+		if q.Numeric == nil {
+			return "", fmt.Errorf("missing numeric answer key")
+		}
+		tolerance := q.Numeric.Tolerance
+		if q.Numeric.Relative {
+			tolerance *= q.Numeric.Value
+			if tolerance < 0 {
+				tolerance = -tolerance
+			}
+		}
+		b.WriteString("#" + formatFloat(q.Numeric.Value))
+		if tolerance != 0 {
+			b.WriteString(":" + formatFloat(tolerance))
+		}
+	case models.KindShortText:
+		for _, rule := range q.TextRules {
+			if rule.Match == models.MatchRegex || rule.Match == models.MatchEditDistance {
+				return "", fmt.Errorf("%s text rules cannot be written as GIFT", rule.Match)
+			}
+			b.WriteString("\n\t=")
+			if rule.Score != 0 && rule.Score != 1 {
+				b.WriteString("%" + formatFloat(rule.Score*100) + "%")
+			}
+			b.WriteString(escapeGIFT(rule.Pattern))
+		}
+		b.WriteString("\n")
+	case models.KindMultipleSelect:
+		correct := make(map[int]bool)
+		for _, option := range q.CorrectOptions {
+			correct[option] = true
+		}
+		if len(correct) == 0 {
+			return "", fmt.Errorf("no correct options")
+		}
+		right := formatFloat(100 / float64(len(correct)))
+		wrong := "-100"
+		if n := len(q.Options) - len(correct); n > 0 {
+			wrong = formatFloat(-100 / float64(n))
+		}
+		for i, option := range q.Options {
+			weight := wrong
+			if correct[i] {
+				weight = right
+			}
+			b.WriteString("\n\t~%" + weight + "%" + escapeGIFT(option))
+		}
+		b.WriteString("\n")
+	default:
+		if len(q.Options) == 2 && q.Options[0] == "True" && q.Options[1] == "False" {
+			if q.CorrectOption == 0 {
+				return "T", nil
+			}
+			return "F", nil
+		}
+		for i, option := range q.Options {
+			op := "~"
+			if i == q.CorrectOption {
+				op = "="
+			}
+			b.WriteString("\n\t" + op + escapeGIFT(option))
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 5, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+const giftSpecial = `~=#{}:\`
+
+func escapeGIFT(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ReplaceAll(s, "\n", " ") {
+		if strings.ContainsRune(giftSpecial, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func unescapeGIFT(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// cleanGIFTText unescapes question text and drops a leading [format] marker
+func cleanGIFTText(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") {
+		if end := strings.Index(s, "]"); end > 0 && !strings.ContainsAny(s[1:end], " \n") {
+			s = s[end+1:]
+		}
+	}
+	return strings.Join(strings.Fields(unescapeGIFT(s)), " ")
+}
+
+// indexUnescaped returns the index of the first occurrence of sep in s that
+// is not preceded by a backslash, or -1
+func indexUnescaped(s, sep string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitUnescaped splits s at the first unescaped c
+func splitUnescaped(s string, c byte) (before, after string) {
+	if i := indexUnescaped(s, string(c)); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
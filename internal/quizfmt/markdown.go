@@ -0,0 +1,435 @@
+package quizfmt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"quiz-app/internal/models"
+)
+
+// The Markdown dialect keeps quiz settings in YAML front matter and writes
+// each question as a level-two heading followed by its answers:
+//
+//	---
+//	title: Capitals
+//	is_negative_marking: true
+//	penalty: 0.5
+//	---
+//
+//	## What is the capital of France? {#capital marks=2}
+//
+//	- [x] Paris
+//	- [ ] Lyon
+//
+// A heading may end in an attribute block giving the question ID, marks,
+// kind and multiple select scoring. Lines after the heading and before the
+// answers continue the question text. Ticking more than one box makes a
+// multiple select question. Numeric and short text questions list accepted
+// answers on "=" lines instead of options: "= 3.14 ± 0.01" or "= 5 ± 2%"
+// for numbers, "= Paris" or "= %50%Pariss" for text, matched ignoring case.
+
+// ParseMarkdown reads a quiz written in the Markdown dialect. Every problem
+// found is reported in an ErrorList with its line, so the same call serves
+// to validate a document.
+func ParseMarkdown(r io.Reader) (*models.Quiz, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &markdownParser{quiz: &models.Quiz{Questions: []models.Question{}}, ids: map[string]int{}}
+
+	body := 0
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		end := -1
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			p.errs.add(1, "unterminated front matter")
+			return nil, p.errs
+		}
+		p.frontMatter(strings.Join(lines[1:end], "\n"))
+		body = end + 1
+	}
+
+	for i := body; i < len(lines); i++ {
+		p.line(i+1, lines[i])
+	}
+	p.finish()
+
+	if err := p.errs.err(); err != nil {
+		return nil, err
+	}
+	return p.quiz, nil
+}
+
+type markdownParser struct {
+	quiz *models.Quiz
+	errs ErrorList
+	ids  map[string]int // question ID to the line it was declared on
+
+	// the question being read
+	current  *models.Question
+	heading  int
+	text     []string
+	options  int // line of the first option or answer, 0 before any
+	ticked   []int
+	answers  []string
+	answerAt []int
+}
+
+// frontMatter applies the quiz settings. Unknown keys are reported so that
+// typos do not silently fall back to defaults.
+func (p *markdownParser) frontMatter(src string) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		p.errs.add(2, "invalid front matter: %v", strings.TrimPrefix(err.Error(), "yaml: "))
+		return
+	}
+	if len(doc.Content) == 0 {
+		return
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		p.errs.add(root.Line+1, "front matter must be a mapping")
+		return
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		line := key.Line + 1 // the front matter starts on the second line
+		var err error
+		switch key.Value {
+		case "id":
+			err = value.Decode(&p.quiz.ID)
+		case "title":
+			err = value.Decode(&p.quiz.Title)
+		case "is_negative_marking":
+			err = value.Decode(&p.quiz.IsNegativeMarking)
+		case "penalty":
+			err = value.Decode(&p.quiz.Penalty)
+		default:
+			p.errs.add(line, "unknown setting %q", key.Value)
+			continue
+		}
+		if err != nil {
+			p.errs.add(line, "invalid %s: %s", key.Value, value.Value)
+		}
+	}
+}
+
+func (p *markdownParser) line(n int, line string) {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(trimmed, "## "):
+		p.finish()
+		p.startQuestion(n, strings.TrimSpace(trimmed[3:]))
+	case p.current == nil:
+		// Prose and a "# Title" heading may come before the first question
+	case trimmed == "":
+	case isCheckbox(trimmed):
+		if p.options == 0 {
+			p.options = n
+		}
+		if len(p.answers) > 0 {
+			p.errs.add(n, "options cannot be mixed with = answers")
+			return
+		}
+		box, text := trimmed[2:5], strings.TrimSpace(trimmed[5:])
+		if text == "" {
+			p.errs.add(n, "empty option")
+		}
+		if box != "[ ]" {
+			p.ticked = append(p.ticked, len(p.current.Options))
+		}
+		p.current.Options = append(p.current.Options, text)
+	case strings.HasPrefix(trimmed, "="):
+		if p.options == 0 {
+			p.options = n
+		}
+		if len(p.current.Options) > 0 {
+			p.errs.add(n, "options cannot be mixed with = answers")
+			return
+		}
+		p.answers = append(p.answers, strings.TrimSpace(trimmed[1:]))
+		p.answerAt = append(p.answerAt, n)
+	case p.options != 0:
+		p.errs.add(n, "unexpected text after the answers")
+	default:
+		p.text = append(p.text, trimmed)
+	}
+}
+
+func (p *markdownParser) startQuestion(n int, heading string) {
+	p.current = &models.Question{Marks: 1}
+	p.heading, p.options, p.text, p.ticked, p.answers, p.answerAt = n, 0, nil, nil, nil, nil
+
+	if open := strings.LastIndex(heading, "{"); open >= 0 && strings.HasSuffix(heading, "}") {
+		p.attributes(n, heading[open+1:len(heading)-1])
+		heading = strings.TrimSpace(heading[:open])
+	}
+	p.text = append(p.text, heading)
+}
+
+// attributes applies a heading's {#id marks=2 kind=numeric scoring=...} block
+func (p *markdownParser) attributes(n int, block string) {
+	q := p.current
+	for _, field := range strings.Fields(block) {
+		if id, ok := strings.CutPrefix(field, "#"); ok {
+			q.ID = id
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			p.errs.add(n, "invalid attribute %q", field)
+			continue
+		}
+		switch key {
+		case "marks":
+			marks, err := strconv.Atoi(value)
+			if err != nil || marks < 1 {
+				p.errs.add(n, "marks must be a positive whole number")
+			}
+			q.Marks = marks
+		case "kind":
+			q.Kind = models.QuestionKind(value)
+			switch q.Kind {
+			case models.KindSingleChoice, models.KindMultipleSelect, models.KindNumeric, models.KindShortText:
+			default:
+				p.errs.add(n, "unknown kind %q", value)
+			}
+		case "scoring":
+			q.Scoring = models.ScoringMode(value)
+			switch q.Scoring {
+			case models.ScoringAllOrNothing, models.ScoringProportional, models.ScoringRightMinusWrong:
+			default:
+				p.errs.add(n, "unknown scoring %q", value)
+			}
+		default:
+			p.errs.add(n, "unknown attribute %q", key)
+		}
+	}
+}
+
+// finish checks the question being read and adds it to the quiz
+func (p *markdownParser) finish() {
+	q := p.current
+	if q == nil {
+		return
+	}
+	p.current = nil
+	n := p.heading
+
+	q.Text = strings.Join(p.text, "\n")
+	if strings.TrimSpace(q.Text) == "" {
+		p.errs.add(n, "question has no text")
+	}
+	if q.ID == "" {
+		q.ID = "q" + strconv.Itoa(len(p.quiz.Questions)+1)
+	}
+	if first, dup := p.ids[q.ID]; dup {
+		p.errs.add(n, "question ID %q is already used on line %d", q.ID, first)
+	}
+	p.ids[q.ID] = n
+
+	switch q.Kind {
+	case models.KindNumeric:
+		if len(p.answers) != 1 {
+			p.errs.add(n, "numeric question needs exactly one = answer")
+			break
+		}
+		key, ok := parseMarkdownNumber(p.answers[0])
+		if !ok {
+			p.errs.add(p.answerAt[0], "invalid number %q", p.answers[0])
+		}
+		q.Numeric = key
+	case models.KindShortText:
+		if len(p.answers) == 0 {
+			p.errs.add(n, "short text question needs at least one = answer")
+		}
+		for i, answer := range p.answers {
+			rule := models.TextRule{Match: models.MatchCaseInsensitive, Pattern: answer}
+			if rest, ok := strings.CutPrefix(answer, "%"); ok {
+				weight, pattern, found := strings.Cut(rest, "%")
+				score, err := strconv.ParseFloat(weight, 64)
+				if !found || err != nil || score <= 0 || score > 100 {
+					p.errs.add(p.answerAt[i], "invalid answer weight")
+				}
+				rule.Pattern = strings.TrimSpace(pattern)
+				if score != 100 {
+					rule.Score = score / 100
+				}
+			}
+			q.TextRules = append(q.TextRules, rule)
+		}
+	default:
+		if len(p.answers) > 0 {
+			p.errs.add(p.answerAt[0], "= answers need kind=numeric or kind=short_text")
+		}
+		if len(q.Options) < 2 {
+			p.errs.add(n, "question needs at least two options")
+		}
+		switch {
+		case len(p.ticked) == 0:
+			p.errs.add(n, "no option is ticked as correct")
+		case q.Kind == models.KindSingleChoice && len(p.ticked) > 1:
+			p.errs.add(n, "single choice question has more than one option ticked")
+		case q.Kind == models.KindMultipleSelect || len(p.ticked) > 1:
+			q.Kind = models.KindMultipleSelect
+			q.CorrectOptions = p.ticked
+		default:
+			q.Kind = models.KindSingleChoice
+			q.CorrectOption = p.ticked[0]
+		}
+	}
+	if q.Scoring != "" && q.Kind != models.KindMultipleSelect {
+		p.errs.add(n, "scoring only applies to multiple select questions")
+	}
+	p.quiz.Questions = append(p.quiz.Questions, *q)
+}
+
+func isCheckbox(line string) bool {
+	if len(line) < 5 || (line[0] != '-' && line[0] != '*') || line[1] != ' ' {
+		return false
+	}
+	switch line[2:5] {
+	case "[ ]", "[x]", "[X]":
+		return true
+	}
+	return false
+}
+
+// parseMarkdownNumber reads "3.14", "3.14 ± 0.01" or "5 ± 2%". "+/-" may
+// be written for "±".
+func parseMarkdownNumber(s string) (*models.NumericKey, bool) {
+	s = strings.ReplaceAll(s, "+/-", "±")
+	value, tolerance, hasTolerance := strings.Cut(s, "±")
+	key := &models.NumericKey{}
+	var err error
+	if key.Value, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+		return nil, false
+	}
+	if !hasTolerance {
+		return key, true
+	}
+	tolerance = strings.TrimSpace(tolerance)
+	if t, ok := strings.CutSuffix(tolerance, "%"); ok {
+		tolerance, key.Relative = strings.TrimSpace(t), true
+	}
+	if key.Tolerance, err = strconv.ParseFloat(tolerance, 64); err != nil || key.Tolerance < 0 {
+		return nil, false
+	}
+	if key.Relative {
+		key.Tolerance /= 100
+	}
+	return key, true
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return lines, scanner.Err()
+}
+
+// markdownFrontMatter is the front matter WriteMarkdown writes
+type markdownFrontMatter struct {
+	ID                string  `yaml:"id,omitempty"`
+	Title             string  `yaml:"title"`
+	IsNegativeMarking bool    `yaml:"is_negative_marking"`
+	Penalty           float32 `yaml:"penalty"`
+}
+
+// WriteMarkdown writes quiz in the Markdown dialect. Settings other than the
+// title and negative marking have no place in the dialect and are not
+// written. Short-text rules that match by regular expression or edit
+// distance cannot be expressed and are reported as an error.
+func WriteMarkdown(w io.Writer, quiz *models.Quiz) error {
+	var b bytes.Buffer
+	front, err := yaml.Marshal(markdownFrontMatter{
+		ID: quiz.ID, Title: quiz.Title, IsNegativeMarking: quiz.IsNegativeMarking, Penalty: quiz.Penalty,
+	})
+	if err != nil {
+		return err
+	}
+	b.WriteString("---\n")
+	b.Write(front)
+	b.WriteString("---\n")
+
+	for i := range quiz.Questions {
+		if err := writeMarkdownQuestion(&b, &quiz.Questions[i]); err != nil {
+			return fmt.Errorf("question %s: %w", quiz.Questions[i].ID, err)
+		}
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+func writeMarkdownQuestion(b *bytes.Buffer, q *models.Question) error {
+	kind := q.EffectiveKind()
+	heading, rest, _ := strings.Cut(q.Text, "\n")
+	attrs := []string{"#" + q.ID}
+	if q.Marks != 1 {
+		attrs = append(attrs, "marks="+strconv.Itoa(q.Marks))
+	}
+	switch {
+	case kind == models.KindNumeric || kind == models.KindShortText:
+		attrs = append(attrs, "kind="+string(kind))
+	case kind == models.KindMultipleSelect && len(q.CorrectOptions) < 2:
+		// A single tick would read back as a single choice question
+		attrs = append(attrs, "kind="+string(kind))
+	}
+	if q.Scoring != "" && kind == models.KindMultipleSelect {
+		attrs = append(attrs, "scoring="+string(q.Scoring))
+	}
+	fmt.Fprintf(b, "\n## %s {%s}\n", heading, strings.Join(attrs, " "))
+	if rest != "" {
+		fmt.Fprintf(b, "\n%s\n", rest)
+	}
+	b.WriteString("\n")
+
+	switch kind {
+	case models.KindNumeric:
+		if q.Numeric == nil {
+			return fmt.Errorf("missing numeric answer key")
+		}
+		answer, _ := q.CorrectAnswer()
+		fmt.Fprintf(b, "= %s\n", answer)
+	case models.KindShortText:
+		for _, rule := range q.TextRules {
+			if rule.Match == models.MatchRegex || rule.Match == models.MatchEditDistance {
+				return fmt.Errorf("%s text rules cannot be written as Markdown", rule.Match)
+			}
+			weight := ""
+			if rule.Score != 0 && rule.Score != 1 {
+				weight = "%" + strconv.FormatFloat(rule.Score*100, 'g', -1, 64) + "%"
+			}
+			fmt.Fprintf(b, "= %s%s\n", weight, rule.Pattern)
+		}
+	default:
+		correct := map[int]bool{q.CorrectOption: kind == models.KindSingleChoice}
+		if kind == models.KindMultipleSelect {
+			for _, o := range q.CorrectOptions {
+				correct[o] = true
+			}
+		}
+		for i, option := range q.Options {
+			box := "[ ]"
+			if correct[i] {
+				box = "[x]"
+			}
+			fmt.Fprintf(b, "- %s %s\n", box, option)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,777 @@
+package quizfmt
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"quiz-app/internal/models"
+)
+
+// QTI 2.1 names used in packages
+const (
+	qtiNamespace      = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+	qtiCPNamespace    = "http://www.imsglobal.org/xsd/imscp_v1p1"
+	qtiTestType       = "imsqti_test_xmlv2p1"
+	qtiItemType       = "imsqti_item_xmlv2p1"
+	qtiMatchCorrect   = "http://www.imsglobal.org/question/qti_v2p1/rptemplates/match_correct"
+	qtiMapResponse    = "http://www.imsglobal.org/question/qti_v2p1/rptemplates/map_response"
+	qtiManifestFile   = "imsmanifest.xml"
+	qtiTestFile       = "assessment.xml"
+	qtiResponse       = "RESPONSE"
+	qtiChoicePrefix   = "choice_"
+	maxQTIPackageSize = 32 << 20
+	maxQTIFileSize    = 8 << 20  // decompressed size of one file
+	maxQTIUnpacked    = 64 << 20 // decompressed size of every file read
+)
+
+// errQTIUnpackedTooLarge stops a package whose files decompress to more
+// than maxQTIUnpacked bytes
+var errQTIUnpackedTooLarge = errors.New("package decompresses to too much data")
+
+// ItemError is a problem with one item of a package
+type ItemError struct {
+	Item string `json:"item"`
+	Msg  string `json:"message"`
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %s: %s", e.Item, e.Msg)
+}
+
+// ItemErrorList collects the problems with every item of a package
+type ItemErrorList []*ItemError
+
+func (l ItemErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ParseQTI reads an IMS QTI 2.1 content package: a zip holding an
+// imsmanifest.xml, an optional assessment test and its items. Items are
+// taken in the test's order, or the manifest's when there is no test.
+//
+// Choice interactions become single choice or multiple select questions and
+// text entry interactions become numeric or short text questions depending
+// on their base type. Items using any other interaction, or more than one
+// interaction, cannot be represented and are reported in an ItemErrorList
+// along with any other malformed item. Files may decompress to 8 MB each
+// and 64 MB together, so that a small package cannot exhaust memory.
+func ParseQTI(r io.Reader) (*models.Quiz, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxQTIPackageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxQTIPackageSize {
+		return nil, errors.New("package is too large")
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("read package: %w", err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[path.Clean(f.Name)] = f
+	}
+	unpacked := 0
+	read := func(name string) ([]byte, error) {
+		f, ok := files[path.Clean(name)]
+		if !ok {
+			return nil, fmt.Errorf("package has no file %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		// The sizes a zip declares cannot be trusted, so files are cut off
+		// once they pass the limit rather than checked up front
+		raw, err := io.ReadAll(io.LimitReader(rc, maxQTIFileSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) > maxQTIFileSize {
+			return nil, fmt.Errorf("file %s is too large", name)
+		}
+		if unpacked += len(raw); unpacked > maxQTIUnpacked {
+			return nil, errQTIUnpackedTooLarge
+		}
+		return raw, nil
+	}
+
+	raw, err := read(qtiManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var manifest qtiManifest
+	if err := xml.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+
+	quiz := &models.Quiz{Questions: []models.Question{}}
+	var hrefs []string
+	for _, res := range manifest.Resources {
+		if !strings.HasPrefix(res.Type, qtiTestType) {
+			continue
+		}
+		raw, err := read(res.Href)
+		if err != nil {
+			return nil, err
+		}
+		var title string
+		if title, hrefs, err = parseQTITest(raw); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", res.Href, err)
+		}
+		quiz.Title = title
+		for i := range hrefs {
+			hrefs[i] = path.Join(path.Dir(res.Href), hrefs[i])
+		}
+		break
+	}
+	if hrefs == nil {
+		for _, res := range manifest.Resources {
+			if strings.HasPrefix(res.Type, qtiItemType) {
+				hrefs = append(hrefs, res.Href)
+			}
+		}
+	}
+
+	var errs ItemErrorList
+	for _, href := range hrefs {
+		raw, err := read(href)
+		if errors.Is(err, errQTIUnpackedTooLarge) {
+			return nil, err
+		}
+		if err != nil {
+			errs = append(errs, &ItemError{Item: href, Msg: err.Error()})
+			continue
+		}
+		question, err := parseQTIItem(raw)
+		if err != nil {
+			var itemErr *ItemError
+			if !errors.As(err, &itemErr) {
+				itemErr = &ItemError{Item: href, Msg: err.Error()}
+			}
+			errs = append(errs, itemErr)
+			continue
+		}
+		quiz.Questions = append(quiz.Questions, question)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return quiz, nil
+}
+
+// parseQTITest returns the title of an assessment test and the hrefs of its
+// items in order
+func parseQTITest(raw []byte) (string, []string, error) {
+	d := xml.NewDecoder(bytes.NewReader(raw))
+	var title string
+	hrefs := []string{}
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return title, hrefs, nil
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "assessmentTest":
+			title = attr(start, "title")
+		case "assessmentItemRef":
+			hrefs = append(hrefs, attr(start, "href"))
+		}
+	}
+}
+
+func parseQTIItem(raw []byte) (models.Question, error) {
+	var item qtiItem
+	if err := xml.Unmarshal(raw, &item); err != nil {
+		return models.Question{}, err
+	}
+	id := item.Identifier
+	fail := func(format string, args ...any) (models.Question, error) {
+		return models.Question{}, &ItemError{Item: id, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	question := models.Question{ID: item.Identifier, Marks: 1}
+	if item.Label != "" {
+		question.ID = item.Label
+	}
+	for _, outcome := range item.Outcomes {
+		if outcome.Identifier == "SCORE" && outcome.NormalMaximum > 0 {
+			question.Marks = int(math.Round(outcome.NormalMaximum))
+		}
+	}
+
+	body, err := parseQTIBody(item.Body.Inner)
+	if err != nil {
+		return fail("%v", err)
+	}
+	switch {
+	case len(body.unsupported) > 0:
+		return fail("unsupported interaction %s", strings.Join(body.unsupported, ", "))
+	case len(body.interactions) == 0:
+		return fail("no interaction")
+	case len(body.interactions) > 1:
+		return fail("items with more than one interaction are not supported")
+	}
+	interaction := body.interactions[0]
+	question.Text = body.text
+
+	var response *qtiResponseDeclaration
+	for i := range item.Responses {
+		if item.Responses[i].Identifier == interaction.responseID {
+			response = &item.Responses[i]
+		}
+	}
+	if response == nil {
+		return fail("no response declaration for %s", interaction.responseID)
+	}
+	template := ""
+	if item.Processing != nil {
+		template = item.Processing.Template
+	}
+
+	switch interaction.name {
+	case "choiceInteraction":
+		index := make(map[string]int, len(interaction.choices))
+		for i, choice := range interaction.choices {
+			index[choice.id] = i
+			question.Options = append(question.Options, choice.text)
+		}
+		var correct []int
+		for _, value := range response.Correct {
+			i, ok := index[value]
+			if !ok {
+				return fail("correct response %q is not a choice", value)
+			}
+			correct = append(correct, i)
+		}
+		if response.Cardinality == "single" {
+			if len(correct) != 1 {
+				return fail("single choice needs exactly one correct response")
+			}
+			question.Kind = models.KindSingleChoice
+			question.CorrectOption = correct[0]
+			break
+		}
+		question.Kind = models.KindMultipleSelect
+		question.CorrectOptions = correct
+		question.Scoring = models.ScoringAllOrNothing
+		if response.Mapping != nil && template != qtiMatchCorrect {
+			question.Scoring = models.ScoringProportional
+			for _, entry := range response.Mapping.Entries {
+				if entry.Value < 0 {
+					question.Scoring = models.ScoringRightMinusWrong
+				}
+			}
+		}
+	case "textEntryInteraction":
+		switch response.BaseType {
+		case "float", "integer":
+			if len(response.Correct) == 0 {
+				return fail("numeric response has no correct value")
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(response.Correct[0]), 64)
+			if err != nil {
+				return fail("invalid numeric correct response %q", response.Correct[0])
+			}
+			question.Kind = models.KindNumeric
+			question.Numeric = &models.NumericKey{Value: value}
+			if item.Processing != nil {
+				question.Numeric.Tolerance, question.Numeric.Relative = qtiTolerance(item.Processing.Inner)
+			}
+		case "string":
+			question.Kind = models.KindShortText
+			question.TextRules = qtiTextRules(response, question.Marks)
+			if len(question.TextRules) == 0 {
+				return fail("text response has no correct value")
+			}
+		default:
+			return fail("unsupported base type %s", response.BaseType)
+		}
+	}
+	return question, nil
+}
+
+// qtiTextRules turns a string response's mapping, or failing that its
+// correct values, into text rules. Case-sensitive keys can only be matched
+// exactly, so they become anchored regular expressions.
+func qtiTextRules(response *qtiResponseDeclaration, marks int) []models.TextRule {
+	var rules []models.TextRule
+	if response.Mapping != nil && len(response.Mapping.Entries) > 0 {
+		for _, entry := range response.Mapping.Entries {
+			if entry.Value <= 0 {
+				continue
+			}
+			rule := models.TextRule{Match: models.MatchCaseInsensitive, Pattern: entry.Key}
+			if entry.CaseSensitive {
+				rule.Match, rule.Pattern = models.MatchRegex, regexp.QuoteMeta(entry.Key)
+			}
+			if marks > 0 && entry.Value < float64(marks) {
+				rule.Score = entry.Value / float64(marks)
+			}
+			rules = append(rules, rule)
+		}
+		return rules
+	}
+	for _, value := range response.Correct {
+		rules = append(rules, models.TextRule{Match: models.MatchRegex, Pattern: regexp.QuoteMeta(value)})
+	}
+	return rules
+}
+
+// qtiTolerance finds the tolerance of the first <equal> in custom response
+// processing. QTI gives relative tolerances as percentages.
+func qtiTolerance(processing string) (tolerance float64, relative bool) {
+	d := xml.NewDecoder(strings.NewReader("<rp>" + processing + "</rp>"))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return 0, false
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "equal" {
+			continue
+		}
+		fields := strings.Fields(attr(start, "tolerance"))
+		if len(fields) == 0 {
+			return 0, false
+		}
+		t, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, false
+		}
+		switch attr(start, "toleranceMode") {
+		case "absolute":
+			return t, false
+		case "relative":
+			return t / 100, true
+		}
+		return 0, false
+	}
+}
+
+// qtiBody is what an item body holds: its text outside interactions, the
+// interactions we understand and the names of those we do not
+type qtiBody struct {
+	text         string
+	interactions []qtiInteraction
+	unsupported  []string
+}
+
+type qtiInteraction struct {
+	name       string
+	responseID string
+	choices    []qtiChoice
+}
+
+type qtiChoice struct {
+	id, text string
+}
+
+func parseQTIBody(inner string) (qtiBody, error) {
+	var body qtiBody
+	var text strings.Builder
+	d := xml.NewDecoder(strings.NewReader("<itemBody>" + inner + "</itemBody>"))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return body, err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+			text.WriteByte(' ')
+		case xml.StartElement:
+			switch name := t.Name.Local; {
+			case name == "choiceInteraction":
+				var ci qtiChoiceInteraction
+				if err := d.DecodeElement(&ci, &t); err != nil {
+					return body, err
+				}
+				interaction := qtiInteraction{name: name, responseID: ci.ResponseIdentifier}
+				for _, c := range ci.Choices {
+					interaction.choices = append(interaction.choices, qtiChoice{id: c.Identifier, text: textContent(c.Inner)})
+				}
+				text.WriteString(textContent(ci.Prompt.Inner))
+				body.interactions = append(body.interactions, interaction)
+			case name == "textEntryInteraction":
+				body.interactions = append(body.interactions, qtiInteraction{name: name, responseID: attr(t, "responseIdentifier")})
+				if err := d.Skip(); err != nil {
+					return body, err
+				}
+			case strings.HasSuffix(name, "Interaction"):
+				body.unsupported = append(body.unsupported, name)
+				if err := d.Skip(); err != nil {
+					return body, err
+				}
+			}
+		}
+	}
+	body.text = strings.Join(strings.Fields(text.String()), " ")
+	return body, nil
+}
+
+// textContent returns the text of an XML fragment without its markup
+func textContent(inner string) string {
+	var text strings.Builder
+	d := xml.NewDecoder(strings.NewReader("<t>" + inner + "</t>"))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		if data, ok := tok.(xml.CharData); ok {
+			text.Write(data)
+			text.WriteByte(' ')
+		}
+	}
+	return strings.Join(strings.Fields(text.String()), " ")
+}
+
+func attr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// WriteQTI writes quiz as an IMS QTI 2.1 content package: a manifest, an
+// assessment test listing the questions in order and one item per question.
+// Question IDs that are not valid QTI identifiers are kept as item labels.
+//
+// Proportional multiple select scoring is written as a mapping that credits
+// each correct choice, which is the closest QTI equivalent. Short-text rules
+// that match by regular expression or edit distance cannot be expressed and
+// are reported as an error.
+func WriteQTI(w io.Writer, quiz *models.Quiz) error {
+	items := make([]qtiItem, len(quiz.Questions))
+	seen := make(map[string]bool)
+	for i := range quiz.Questions {
+		item, err := newQTIItem(&quiz.Questions[i], i)
+		if err != nil {
+			return fmt.Errorf("question %s: %w", quiz.Questions[i].ID, err)
+		}
+		for seen[item.Identifier] {
+			item.Identifier += "_"
+		}
+		seen[item.Identifier] = true
+		items[i] = item
+	}
+
+	testID := qtiIdentifier(quiz.ID, "test")
+	test := qtiTest{XMLNS: qtiNamespace, Identifier: testID, Title: quiz.Title}
+	section := qtiSection{Identifier: "section_1", Title: quiz.Title, Visible: true}
+	testResource := qtiResource{Identifier: "resource_" + testID, Type: qtiTestType, Href: qtiTestFile,
+		Files: []qtiFile{{Href: qtiTestFile}}}
+	manifest := qtiManifest{XMLNS: qtiCPNamespace, Identifier: "manifest_" + testID}
+
+	zw := zip.NewWriter(w)
+	for _, item := range items {
+		href := "items/" + item.Identifier + ".xml"
+		section.ItemRefs = append(section.ItemRefs, qtiItemRef{Identifier: item.Identifier, Href: href})
+		resourceID := "resource_" + item.Identifier
+		testResource.Dependencies = append(testResource.Dependencies, qtiDependency{IdentifierRef: resourceID})
+		manifest.Resources = append(manifest.Resources, qtiResource{Identifier: resourceID, Type: qtiItemType,
+			Href: href, Files: []qtiFile{{Href: href}}})
+		if err := writeXMLFile(zw, href, item); err != nil {
+			return err
+		}
+	}
+	test.TestParts = []qtiTestPart{{Identifier: "part_1", NavigationMode: "nonlinear",
+		SubmissionMode: "individual", Sections: []qtiSection{section}}}
+	manifest.Resources = append([]qtiResource{testResource}, manifest.Resources...)
+
+	if err := writeXMLFile(zw, qtiTestFile, test); err != nil {
+		return err
+	}
+	if err := writeXMLFile(zw, qtiManifestFile, manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeXMLFile(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	io.WriteString(f, xml.Header)
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	return enc.Encode(v)
+}
+
+func newQTIItem(q *models.Question, i int) (qtiItem, error) {
+	item := qtiItem{
+		XMLNS:      qtiNamespace,
+		Identifier: qtiIdentifier(q.ID, "item_"+strconv.Itoa(i+1)),
+		Title:      q.ID,
+		Outcomes: []qtiOutcomeDeclaration{{
+			Identifier: "SCORE", Cardinality: "single", BaseType: "float", NormalMaximum: float64(q.Marks),
+		}},
+	}
+	if item.Identifier != q.ID {
+		item.Label = q.ID
+	}
+	response := qtiResponseDeclaration{Identifier: qtiResponse, Cardinality: "single"}
+	marks := float64(q.Marks)
+
+	var body any
+	switch q.EffectiveKind() {
+	case models.KindSingleChoice, models.KindMultipleSelect:
+		response.BaseType = "identifier"
+		ci := qtiChoiceInteraction{ResponseIdentifier: qtiResponse, Prompt: qtiInner{escapeXML(q.Text)}}
+		for j, option := range q.Options {
+			ci.Choices = append(ci.Choices, qtiSimpleChoice{Identifier: qtiChoicePrefix + strconv.Itoa(j), Inner: escapeXML(option)})
+		}
+		item.Processing = &qtiResponseProcessing{Template: qtiMatchCorrect}
+		if q.EffectiveKind() == models.KindSingleChoice {
+			ci.MaxChoices = 1
+			response.Correct = []string{qtiChoicePrefix + strconv.Itoa(q.CorrectOption)}
+		} else {
+			response.Cardinality = "multiple"
+			correct := make(map[int]bool)
+			for _, o := range q.CorrectOptions {
+				correct[o] = true
+				response.Correct = append(response.Correct, qtiChoicePrefix+strconv.Itoa(o))
+			}
+			if q.Scoring == models.ScoringProportional || q.Scoring == models.ScoringRightMinusWrong {
+				share := 0.0
+				if len(correct) > 0 {
+					share = marks / float64(len(correct))
+				}
+				mapping := &qtiMapping{LowerBound: "0"}
+				for j := range q.Options {
+					value := share
+					if !correct[j] {
+						value = 0
+						if q.Scoring == models.ScoringRightMinusWrong {
+							value = -share
+						}
+					}
+					mapping.Entries = append(mapping.Entries, qtiMapEntry{Key: qtiChoicePrefix + strconv.Itoa(j), Value: value})
+				}
+				response.Mapping = mapping
+				item.Processing.Template = qtiMapResponse
+			}
+		}
+		body = ci
+	case models.KindNumeric:
+		if q.Numeric == nil {
+			return item, errors.New("missing numeric answer key")
+		}
+		response.BaseType = "float"
+		response.Correct = []string{strconv.FormatFloat(q.Numeric.Value, 'g', -1, 64)}
+		equal := `toleranceMode="exact"`
+		if t := q.Numeric.Tolerance; t != 0 {
+			mode := "absolute"
+			if q.Numeric.Relative {
+				mode, t = "relative", t*100
+			}
+			ts := strconv.FormatFloat(t, 'g', -1, 64)
+			equal = fmt.Sprintf(`toleranceMode="%s" tolerance="%s %s"`, mode, ts, ts)
+		}
+		item.Processing = &qtiResponseProcessing{Inner: fmt.Sprintf(`<responseCondition><responseIf>`+
+			`<equal %s><variable identifier="%s"/><correct identifier="%s"/></equal>`+
+			`<setOutcomeValue identifier="SCORE"><baseValue baseType="float">%s</baseValue></setOutcomeValue>`+
+			`</responseIf></responseCondition>`, equal, qtiResponse, qtiResponse, strconv.FormatFloat(marks, 'g', -1, 64))}
+		body = qtiTextEntryBody{Prompt: q.Text, Entry: qtiTextEntryAnswer{qtiTextEntry{ResponseIdentifier: qtiResponse}}}
+	case models.KindShortText:
+		response.BaseType = "string"
+		mapping := &qtiMapping{LowerBound: "0"}
+		for _, rule := range q.TextRules {
+			if rule.Match == models.MatchRegex || rule.Match == models.MatchEditDistance {
+				return item, fmt.Errorf("%s text rules cannot be written as QTI", rule.Match)
+			}
+			value := marks
+			if rule.Score != 0 && rule.Score != 1 {
+				value = rule.Score * marks
+			} else {
+				response.Correct = append(response.Correct, rule.Pattern)
+			}
+			mapping.Entries = append(mapping.Entries, qtiMapEntry{Key: rule.Pattern, Value: value})
+		}
+		response.Mapping = mapping
+		item.Processing = &qtiResponseProcessing{Template: qtiMapResponse}
+		body = qtiTextEntryBody{Prompt: q.Text, Entry: qtiTextEntryAnswer{qtiTextEntry{ResponseIdentifier: qtiResponse}}}
+	}
+	item.Responses = []qtiResponseDeclaration{response}
+
+	inner, err := xml.Marshal(body)
+	if err != nil {
+		return item, err
+	}
+	item.Body = qtiInner{string(inner)}
+	return item, nil
+}
+
+var ncName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// qtiIdentifier returns id if it is a valid QTI identifier, otherwise fallback
+func qtiIdentifier(id, fallback string) string {
+	if ncName.MatchString(id) {
+		return id
+	}
+	return fallback
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+type qtiManifest struct {
+	XMLName       xml.Name      `xml:"manifest"`
+	XMLNS         string        `xml:"xmlns,attr,omitempty"`
+	Identifier    string        `xml:"identifier,attr"`
+	Organizations struct{}      `xml:"organizations"`
+	Resources     []qtiResource `xml:"resources>resource"`
+}
+
+type qtiResource struct {
+	Identifier   string          `xml:"identifier,attr"`
+	Type         string          `xml:"type,attr"`
+	Href         string          `xml:"href,attr"`
+	Files        []qtiFile       `xml:"file"`
+	Dependencies []qtiDependency `xml:"dependency"`
+}
+
+type qtiFile struct {
+	Href string `xml:"href,attr"`
+}
+
+type qtiDependency struct {
+	IdentifierRef string `xml:"identifierref,attr"`
+}
+
+type qtiTest struct {
+	XMLName    xml.Name      `xml:"assessmentTest"`
+	XMLNS      string        `xml:"xmlns,attr,omitempty"`
+	Identifier string        `xml:"identifier,attr"`
+	Title      string        `xml:"title,attr"`
+	TestParts  []qtiTestPart `xml:"testPart"`
+}
+
+type qtiTestPart struct {
+	Identifier     string       `xml:"identifier,attr"`
+	NavigationMode string       `xml:"navigationMode,attr"`
+	SubmissionMode string       `xml:"submissionMode,attr"`
+	Sections       []qtiSection `xml:"assessmentSection"`
+}
+
+type qtiSection struct {
+	Identifier string       `xml:"identifier,attr"`
+	Title      string       `xml:"title,attr"`
+	Visible    bool         `xml:"visible,attr"`
+	ItemRefs   []qtiItemRef `xml:"assessmentItemRef"`
+}
+
+type qtiItemRef struct {
+	Identifier string `xml:"identifier,attr"`
+	Href       string `xml:"href,attr"`
+}
+
+type qtiItem struct {
+	XMLName       xml.Name                 `xml:"assessmentItem"`
+	XMLNS         string                   `xml:"xmlns,attr,omitempty"`
+	Identifier    string                   `xml:"identifier,attr"`
+	Title         string                   `xml:"title,attr"`
+	Label         string                   `xml:"label,attr,omitempty"`
+	Adaptive      bool                     `xml:"adaptive,attr"`
+	TimeDependent bool                     `xml:"timeDependent,attr"`
+	Responses     []qtiResponseDeclaration `xml:"responseDeclaration"`
+	Outcomes      []qtiOutcomeDeclaration  `xml:"outcomeDeclaration"`
+	Body          qtiInner                 `xml:"itemBody"`
+	Processing    *qtiResponseProcessing   `xml:"responseProcessing"`
+}
+
+type qtiInner struct {
+	Inner string `xml:",innerxml"`
+}
+
+type qtiResponseDeclaration struct {
+	Identifier  string      `xml:"identifier,attr"`
+	Cardinality string      `xml:"cardinality,attr"`
+	BaseType    string      `xml:"baseType,attr"`
+	Correct     []string    `xml:"correctResponse>value"`
+	Mapping     *qtiMapping `xml:"mapping"`
+}
+
+type qtiMapping struct {
+	LowerBound string        `xml:"lowerBound,attr,omitempty"`
+	Entries    []qtiMapEntry `xml:"mapEntry"`
+}
+
+type qtiMapEntry struct {
+	Key           string  `xml:"mapKey,attr"`
+	Value         float64 `xml:"mappedValue,attr"`
+	CaseSensitive bool    `xml:"caseSensitive,attr"`
+}
+
+type qtiOutcomeDeclaration struct {
+	Identifier    string  `xml:"identifier,attr"`
+	Cardinality   string  `xml:"cardinality,attr"`
+	BaseType      string  `xml:"baseType,attr"`
+	NormalMaximum float64 `xml:"normalMaximum,attr,omitempty"`
+}
+
+type qtiResponseProcessing struct {
+	Template string `xml:"template,attr,omitempty"`
+	Inner    string `xml:",innerxml"`
+}
+
+type qtiChoiceInteraction struct {
+	XMLName            xml.Name          `xml:"choiceInteraction"`
+	ResponseIdentifier string            `xml:"responseIdentifier,attr"`
+	Shuffle            bool              `xml:"shuffle,attr"`
+	MaxChoices         int               `xml:"maxChoices,attr"`
+	Prompt             qtiInner          `xml:"prompt"`
+	Choices            []qtiSimpleChoice `xml:"simpleChoice"`
+}
+
+type qtiSimpleChoice struct {
+	Identifier string `xml:"identifier,attr"`
+	Inner      string `xml:",innerxml"`
+}
+
+// qtiTextEntryBody is the body written for numeric and short text items
+type qtiTextEntryBody struct {
+	XMLName xml.Name           `xml:"div"`
+	Prompt  string             `xml:"p"`
+	Entry   qtiTextEntryAnswer `xml:"div"`
+}
+
+type qtiTextEntryAnswer struct {
+	Entry qtiTextEntry `xml:"textEntryInteraction"`
+}
+
+type qtiTextEntry struct {
+	ResponseIdentifier string `xml:"responseIdentifier,attr"`
+	ExpectedLength     int    `xml:"expectedLength,attr,omitempty"`
+}
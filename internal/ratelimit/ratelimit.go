@@ -0,0 +1,178 @@
+// Package ratelimit decides whether a client may make another request,
+// using token buckets. Each key has a bucket that holds up to a limit's
+// tokens and refills at the limit's rate; every request takes a token, and
+// a request that finds the bucket empty is refused until one refills.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Tokens requests per Per, in bursts of up to Tokens. The
+// zero Limit allows everything.
+type Limit struct {
+	Tokens int
+	Per    time.Duration
+}
+
+// IsZero reports whether the limit allows everything
+func (l Limit) IsZero() bool {
+	return l.Tokens == 0
+}
+
+// rate is how many tokens the limit refills per second
+func (l Limit) rate() float64 {
+	return float64(l.Tokens) / l.Per.Seconds()
+}
+
+// units are the shorthands ParseLimit accepts for a period
+var units = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+}
+
+// ParseLimit reads a limit written as tokens/period, such as "10/s" or
+// "100/15m". The period is s, m, h or d, or any Go duration.
+func ParseLimit(s string) (Limit, error) {
+	tokens, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("ratelimit: limit %q is not tokens/period", s)
+	}
+	n, err := strconv.Atoi(tokens)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("ratelimit: limit %q must allow a positive number of requests", s)
+	}
+	per, ok := units[period]
+	if !ok {
+		if per, err = time.ParseDuration(period); err != nil || per <= 0 {
+			return Limit{}, fmt.Errorf("ratelimit: limit %q has an invalid period", s)
+		}
+	}
+	return Limit{Tokens: n, Per: per}, nil
+}
+
+// ParseRules reads limits for named routes, written as comma-separated
+// name=limit entries such as
+//
+//	*=600/m, POST /quiz/{quizId}/answer=30/m
+//
+// The name is everything before the last "=".
+func ParseRules(s string) (map[string]Limit, error) {
+	rules := make(map[string]Limit)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("ratelimit: rule %q is not name=limit", entry)
+		}
+		name := strings.TrimSpace(entry[:i])
+		if _, dup := rules[name]; dup {
+			return nil, fmt.Errorf("ratelimit: %s is limited twice", name)
+		}
+		limit, err := ParseLimit(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		rules[name] = limit
+	}
+	return rules, nil
+}
+
+// Limiter keeps the buckets. Allow takes a token from key's bucket under
+// limit. When none is left it reports false and how long until one
+// refills. Every caller using a key must use the same limit with it.
+//
+// Memory keeps buckets in this process. A store shared between servers can
+// implement Limiter so that they enforce one limit together.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (ok bool, retryAfter time.Duration, err error)
+}
+
+// Memory is a Limiter that keeps buckets in memory
+type Memory struct {
+	now       func() time.Time
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled completely
+}
+
+// NewMemory creates an empty in-memory limiter
+func NewMemory() *Memory {
+	return &Memory{now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// SetClock replaces the clock buckets refill by, for tests
+func (m *Memory) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+// Allow takes a token from key's bucket
+func (m *Memory) Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	if limit.IsZero() {
+		return true, 0, nil
+	}
+	if limit.Per <= 0 {
+		return false, 0, errors.New("ratelimit: limit has no period")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.prune(now)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Tokens), updated: now}
+		m.buckets[key] = b
+	}
+	rate := limit.rate()
+	b.tokens = math.Min(float64(limit.Tokens), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(seconds((float64(limit.Tokens) - b.tokens) / rate))
+	if !allowed {
+		return false, seconds((1 - b.tokens) / rate), nil
+	}
+	return true, 0, nil
+}
+
+// prune drops buckets that have refilled completely, at most once a
+// minute. A full bucket behaves exactly like a new one.
+func (m *Memory) prune(now time.Time) {
+	if now.Sub(m.lastPrune) < time.Minute {
+		return
+	}
+	m.lastPrune = now
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -1,21 +1,86 @@
 package routes
 
 import (
+	"net/http"
+	"time"
+
+	"quiz-app/internal/audit"
+	"quiz-app/internal/auth"
 	"quiz-app/internal/controllers"
+	"quiz-app/internal/middleware"
 	"quiz-app/internal/storage"
+	"quiz-app/internal/tenant"
 
 	"github.com/gorilla/mux"
 )
 
-// SetupRoutes configures and returns the application router
-func SetupRoutes(store storage.Storage) *mux.Router {
+// SetupRoutes configures and returns the application router. Each route is
+// guarded by the roles that may call it; with a nil authn every route is
+// open and users are taken from the request. When authn signs users in
+// with OIDC, the /auth routes run the login flow.
+//
+// Every quiz route is also served under /orgs/{org} to act within one of
+// orgs. Without the prefix a request acts within the caller's own
+// organization, or the default one. A nil orgs knows only the default
+// organization.
+//
+// throttle rate-limits each route under its method and unprefixed path,
+// such as "POST /quiz/{quizId}/answer", and caps answers per question. A
+// nil throttle limits nothing.
+//
+// Quiz changes and answers are recorded in events, which admins read
+// through /audit. A nil events records nothing.
+func SetupRoutes(store storage.Storage, authn *auth.Authenticator, orgs *tenant.Directory, throttle *middleware.Throttle,
+	events audit.Log) *mux.Router {
 	r := mux.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(orgs.Middleware)
+	if events != nil {
+		store = audit.Wrap(store, events)
+	}
 	c := controllers.NewQuizController(store)
+	idempotency := middleware.NewIdempotencyCache(24*time.Hour, 0)
+
+	anyone := authn.Require(auth.RoleAuthor, auth.RoleTaker)
+	authors := authn.Require(auth.RoleAuthor)
+	takers := authn.Require(auth.RoleTaker)
+	admins := authn.Require(auth.RoleAdmin)
+	handle := func(path, method string, guard func(http.Handler) http.Handler, h http.HandlerFunc) {
+		limited := guard(throttle.Route(method + " " + path)(h))
+		r.Handle(path, limited).Methods(method)
+		r.Handle("/orgs/{"+tenant.URLVar+"}"+path, limited).Methods(method)
+	}
+	answer := idempotency.Middleware(throttle.Answers(http.HandlerFunc(c.SubmitAnswer)))
+
+	if authn.OIDCEnabled() {
+		r.HandleFunc("/auth/login", authn.Login).Methods("GET")
+		r.HandleFunc("/auth/callback", authn.Callback).Methods("GET")
+		r.HandleFunc("/auth/logout", authn.Logout).Methods("POST")
+	}
+
+	handle("/quiz", "POST", authors, c.CreateQuiz)
+	handle("/quiz", "GET", anyone, c.ListQuizzes)
+	handle("/quiz/import", "POST", authors, c.ImportQuiz)
+	handle("/quiz/import/csv", "POST", authors, c.ImportCSV)
+	handle("/quiz/{id}", "GET", anyone, c.GetQuiz)
+	handle("/quiz/{id}", "PUT", authors, c.UpdateQuiz)
+	handle("/quiz/{id}", "PATCH", authors, c.PatchQuiz)
+	handle("/quiz/{id}", "DELETE", authors, c.DeleteQuiz)
+	handle("/quiz/{id}/export", "GET", authors, c.ExportQuiz)
+	handle("/quiz/{quizId}/attempts", "POST", takers, c.StartAttempt)
+	handle("/quiz/{quizId}/attempts/finish", "POST", takers, c.FinishAttempt)
+	handle("/quiz/{quizId}/attempts/{userId}/finish", "POST", takers, c.FinishAttempt)
+	handle("/quiz/{quizId}/answer", "POST", takers, answer.ServeHTTP)
+	handle("/quiz/{quizId}/answer/{userId}", "POST", takers, answer.ServeHTTP)
+	handle("/quiz/{quizId}/results", "GET", anyone, c.GetResults)
+	handle("/quiz/{quizId}/results/{userId}", "GET", anyone, c.GetResults)
+	handle("/quiz/{quizId}/leaderboard", "GET", anyone, c.Leaderboard)
+	handle("/quiz/{quizId}/analytics/items", "GET", authors, c.ItemAnalysis)
+	handle("/quiz/{quizId}/analytics/stats", "GET", authors, c.QuizStats)
 
-	r.HandleFunc("/quiz", c.CreateQuiz).Methods("POST")
-	r.HandleFunc("/quiz/{id}", c.GetQuiz).Methods("GET")
-	r.HandleFunc("/quiz/{quizId}/answer/{userId}", c.SubmitAnswer).Methods("POST")
-	r.HandleFunc("/quiz/{quizId}/results/{userId}", c.GetResults).Methods("GET")
+	if events != nil {
+		handle("/audit", "GET", admins, controllers.NewAuditController(events).ListEvents)
+	}
 
 	return r
 }
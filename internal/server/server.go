@@ -0,0 +1,224 @@
+// Package server runs the quiz API over HTTP with the settings from config
+// and shuts it down gracefully.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"quiz-app/internal/audit"
+	"quiz-app/internal/auth"
+	"quiz-app/internal/config"
+	"quiz-app/internal/middleware"
+	"quiz-app/internal/oidc"
+	"quiz-app/internal/ratelimit"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+	"quiz-app/internal/tenant"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Server is the HTTP server and the storage and audit log it serves
+type Server struct {
+	cfg    *config.Config
+	store  storage.Storage
+	events audit.Log
+	http   *http.Server
+}
+
+// OpenStorage opens the storage backend named by cfg
+func OpenStorage(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.Storage {
+	case "memory":
+		return storage.NewMemoryStorage(), nil
+	case "sqlite":
+		store, err := storage.NewSQLiteStorage(cfg.DBPath)
+		if err != nil {
+			return nil, fmt.Errorf("open SQLite storage: %w", err)
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage)
+}
+
+// OpenAuditLog opens the audit log file named by cfg, or an in-memory log
+// if none is named
+func OpenAuditLog(cfg *config.Config) (audit.Log, error) {
+	if cfg.AuditLog == "" {
+		return audit.NewMemory(), nil
+	}
+	return audit.OpenFile(cfg.AuditLog)
+}
+
+// NewDirectory builds the directory of the organizations configured by cfg
+func NewDirectory(cfg *config.Config) (*tenant.Directory, error) {
+	orgs, err := tenant.ParseOrgs(cfg.Orgs)
+	if err != nil {
+		return nil, err
+	}
+	return tenant.NewDirectory(orgs)
+}
+
+// NewThrottle builds the rate limits configured by cfg, or returns nil if
+// none are configured
+func NewThrottle(cfg *config.Config) (*middleware.Throttle, error) {
+	if cfg.RateLimits == "" && cfg.AnswerLimit == "" {
+		return nil, nil
+	}
+	opts := middleware.ThrottleOptions{KeyBy: cfg.RateLimitKey}
+	var err error
+	if opts.Routes, err = ratelimit.ParseRules(cfg.RateLimits); err != nil {
+		return nil, err
+	}
+	if cfg.AnswerLimit != "" {
+		if opts.Answers, err = ratelimit.ParseLimit(cfg.AnswerLimit); err != nil {
+			return nil, err
+		}
+	}
+	return middleware.NewThrottle(opts)
+}
+
+// NewAuthenticator builds the authenticator configured by cfg for callers
+// from orgs, or returns nil if authentication is not configured. When OIDC
+// is configured the provider is discovered, so it must be reachable.
+func NewAuthenticator(ctx context.Context, cfg *config.Config, orgs *tenant.Directory) (*auth.Authenticator, error) {
+	if !cfg.AuthEnabled() {
+		return nil, nil
+	}
+	keys, err := auth.ParseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		return nil, err
+	}
+	opts := auth.Options{
+		APIKeys:    keys,
+		HMACSecret: []byte(cfg.JWTSecret),
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+		Orgs:       orgs,
+	}
+	if cfg.JWTPublicKeyFile != "" {
+		pem, err := os.ReadFile(cfg.JWTPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if opts.RSAPublicKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.JWTPublicKeyFile, err)
+		}
+	}
+	if cfg.OIDCIssuer != "" {
+		provider, err := oidc.Discover(ctx, cfg.OIDCIssuer, &http.Client{Timeout: 10 * time.Second})
+		if err != nil {
+			return nil, err
+		}
+		opts.OIDC = oidc.New(provider, oidc.Config{
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       strings.Fields(cfg.OIDCScopes),
+			UserClaim:    cfg.OIDCUserClaim,
+			RoleClaim:    cfg.OIDCRoleClaim,
+			OrgClaim:     cfg.OIDCOrgClaim,
+		})
+		opts.OIDCDefaultRole = auth.Role(cfg.OIDCDefaultRole)
+	}
+	return auth.New(opts)
+}
+
+// New creates a server for store, authenticating requests with authn
+// unless it is nil, keeping the organizations in orgs apart and limiting
+// clients with throttle and recording changes in events unless they are
+// nil. The server owns store and events from then on and closes them when
+// it shuts down.
+func New(cfg *config.Config, store storage.Storage, authn *auth.Authenticator, orgs *tenant.Directory,
+	throttle *middleware.Throttle, events audit.Log) *Server {
+	var handler http.Handler = routes.SetupRoutes(store, authn, orgs, throttle, events)
+	for _, name := range throttle.UnknownRoutes() {
+		log.Printf("Warning: rate limit for %q matches no route", name)
+	}
+	handler = middleware.Timeout(cfg.RequestTimeout)(handler)
+	handler = middleware.MaxBody(cfg.MaxBodyBytes)(handler)
+
+	return &Server{
+		cfg:    cfg,
+		store:  store,
+		events: events,
+		http: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.ReadTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		},
+	}
+}
+
+// ListenAndServe listens on the configured address and serves until ctx is
+// done, as Serve does.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		s.store.Close()
+		if s.events != nil {
+			s.events.Close()
+		}
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln, over TLS when a certificate is
+// configured, until ctx is done. It then stops accepting connections,
+// waits up to the shutdown timeout for in-flight requests to finish and
+// closes the storage and audit log. It returns nil after a clean shutdown.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	scheme := "http"
+	if s.cfg.TLS() {
+		scheme = "https"
+	}
+	log.Printf("Server is listening on %s://%s", scheme, ln.Addr())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if s.cfg.TLS() {
+			serveErr <- s.http.ServeTLS(ln, s.cfg.TLSCertFile, s.cfg.TLSKeyFile)
+		} else {
+			serveErr <- s.http.Serve(ln)
+		}
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		// The server failed on its own; nothing is left to drain
+	case <-ctx.Done():
+		log.Printf("Shutting down, waiting up to %s for in-flight requests", s.cfg.ShutdownTimeout)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+		defer cancel()
+		if shutdownErr := s.http.Shutdown(shutdownCtx); shutdownErr != nil {
+			err = fmt.Errorf("shutdown: %w", shutdownErr)
+			s.http.Close()
+		}
+		<-serveErr
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	if closeErr := s.store.Close(); closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("close storage: %w", closeErr))
+	}
+	if s.events != nil {
+		if closeErr := s.events.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("close audit log: %w", closeErr))
+		}
+	}
+	return err
+}
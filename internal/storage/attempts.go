@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"time"
+
+	"quiz-app/internal/models"
+)
+
+// beginAttempt starts the clock on result, failing if the quiz is outside
+// its opening window. The deadline is the earlier of the quiz duration and
+// the quiz closing time.
+func beginAttempt(quiz *models.Quiz, result *models.Result, now time.Time) error {
+	if quiz.OpensAt != nil && now.Before(*quiz.OpensAt) {
+		return ErrQuizNotOpen
+	}
+	if quiz.ClosesAt != nil && !now.Before(*quiz.ClosesAt) {
+		return ErrQuizNotOpen
+	}
+
+	started := now.UTC()
+	result.StartedAt = &started
+
+	var deadline *time.Time
+	if quiz.DurationSeconds > 0 {
+		d := started.Add(time.Duration(quiz.DurationSeconds) * time.Second)
+		deadline = &d
+	}
+	if quiz.ClosesAt != nil && (deadline == nil || quiz.ClosesAt.Before(*deadline)) {
+		d := quiz.ClosesAt.UTC()
+		deadline = &d
+	}
+	result.Deadline = deadline
+	return nil
+}
+
+// checkAttemptOpen returns ErrAttemptClosed if result can no longer take
+// answers. An attempt whose deadline has passed is finished as of the
+// deadline, and expired is set so the caller knows to persist that.
+func checkAttemptOpen(result *models.Result, now time.Time) (expired bool, err error) {
+	if result.IsFinished() {
+		return false, ErrAttemptClosed
+	}
+	if result.Deadline != nil && now.After(*result.Deadline) {
+		finishAttempt(result, *result.Deadline)
+		return true, ErrAttemptClosed
+	}
+	return false, nil
+}
+
+// finishAttempt locks result as of at and records the elapsed time
+func finishAttempt(result *models.Result, at time.Time) {
+	if result.Deadline != nil && at.After(*result.Deadline) {
+		at = *result.Deadline
+	}
+	finished := at.UTC()
+	result.FinishedAt = &finished
+	if result.StartedAt != nil {
+		result.ElapsedSeconds = finished.Sub(*result.StartedAt).Seconds()
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import "errors"
+
+var (
+	// ErrNotFound is matched by every error reporting that something does
+	// not exist, so callers can handle them alike with errors.Is.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is matched by every error reporting that a request
+	// conflicts with the stored state.
+	ErrConflict = errors.New("conflict")
+)
+
+var (
+	// ErrQuizNotFound is returned when no quiz exists with the requested ID.
+	ErrQuizNotFound = notFound("quiz not found")
+	// ErrQuestionNotFound is returned when an answer names a question that
+	// is not in the quiz, or not on the user's paper.
+	ErrQuestionNotFound = notFound("question not found")
+	// ErrResultsNotFound is returned when no results are stored for the
+	// quiz or user.
+	ErrResultsNotFound = notFound("no results found")
+	// ErrAttemptNotFound is returned when the user has no attempt to act on.
+	ErrAttemptNotFound = notFound("attempt not found")
+	// ErrQuizExists is returned by CreateQuiz when the ID is already taken.
+	ErrQuizExists = conflict("quiz already exists")
+	// ErrVersionConflict is returned by UpdateQuiz when the stored quiz has
+	// changed since the caller read it.
+	ErrVersionConflict = conflict("quiz version conflict")
+	// ErrAttemptExists is returned by StartAttempt when the user has already
+	// started the quiz.
+	ErrAttemptExists = conflict("attempt already started")
+	// ErrAttemptClosed is returned when answering or finishing an attempt
+	// that has been submitted or whose time has run out.
+	ErrAttemptClosed = conflict("attempt is closed")
+	// ErrAlreadyAnswered is returned when a question is answered again under
+	// the first-answer-is-final policy.
+	ErrAlreadyAnswered = conflict("question already answered")
+	// ErrQuizNotOpen is returned when an attempt is made outside the quiz's
+	// opening window.
+	ErrQuizNotOpen = errors.New("quiz is not open")
+	// ErrQuotaExceeded is returned when storing a quiz or starting an
+	// attempt would take the organization past one of its quotas.
+	ErrQuotaExceeded = errors.New("organization quota exceeded")
+)
+
+// kindError is a sentinel error that also matches the broader kind it
+// belongs to, such as ErrNotFound
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func notFound(msg string) error { return &kindError{msg: msg, kind: ErrNotFound} }
+func conflict(msg string) error { return &kindError{msg: msg, kind: ErrConflict} }
+
+func (e *kindError) Error() string { return e.msg }
+
+func (e *kindError) Is(target error) bool { return target == e.kind }
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"quiz-app/internal/models"
+)
+
+// findQuestion returns the question with the given ID, or nil if the quiz has none.
+func findQuestion(quiz *models.Quiz, questionID string) *models.Question {
+	for i := range quiz.Questions {
+		if quiz.Questions[i].ID == questionID {
+			return &quiz.Questions[i]
+		}
+	}
+	return nil
+}
+
+// questionFor returns the question answer refers to, or nil if it is not
+// on userID's paper. For randomized quizzes the answer's option indexes are
+// rewritten from the user's display order to the canonical order, so that
+// grading and stored answers always use canonical indexes.
+func questionFor(quiz *models.Quiz, userID string, answer *models.Answer) *models.Question {
+	if !quiz.IsRandomized() {
+		return findQuestion(quiz, answer.QuestionID)
+	}
+	paper := quiz.PaperFor(userID)
+	if paper.Question(answer.QuestionID) == nil {
+		return nil
+	}
+	paper.ToCanonical(answer)
+	return findQuestion(quiz, answer.QuestionID)
+}
+
+// newResult returns an empty result for a user's first answer to a quiz
+func newResult(quizID, userID string) models.Result {
+	return models.Result{
+		QuizID:  quizID,
+		UserID:  userID,
+		Score:   0,
+		Answers: make(map[string]models.Answer),
+	}
+}
+
+// checkReanswer enforces the quiz's answer policy before a question that
+// the user has already answered is graded again.
+func checkReanswer(quiz *models.Quiz, result *models.Result, questionID string) error {
+	if _, answered := result.Answers[questionID]; answered && quiz.AnswerPolicy == models.PolicyFirstFinal {
+		return ErrAlreadyAnswered
+	}
+	return nil
+}
+
+// scoreAnswer grades answer against question, records it in result according
+// to the quiz's answer policy and recomputes the score from the recorded
+// answers, so that re-answering can never count a question twice. It is
+// shared by every Storage backend so that grading behaves the same
+// regardless of where results are kept.
+//
+// Partially correct answers earn their share of the question's marks. An
+// answer that earns nothing costs the quiz penalty when negative marking is
+// on; partial credit is never penalised.
+func scoreAnswer(quiz *models.Quiz, question *models.Question, result *models.Result, answer *models.Answer) (bool, string, error) {
+	credit := creditFor(question, answer)
+	isCorrect := credit == 1
+	answer.IsCorrect = isCorrect
+
+	switch {
+	case credit > 0:
+		answer.Score = float32(credit * float64(question.Marks))
+	case quiz.IsNegativeMarking:
+		answer.Score = -quiz.Penalty
+	default:
+		answer.Score = 0
+	}
+
+	// Store the answer, unless a better one is kept under the best policy
+	prior, answered := result.Answers[answer.QuestionID]
+	if !answered || quiz.AnswerPolicy != models.PolicyBest || answer.Score > prior.Score {
+		result.Answers[answer.QuestionID] = answer.Clone()
+	}
+
+	// Update score
+	result.Score = 0
+	for _, a := range result.Answers {
+		result.Score += a.Score
+	}
+
+	if isCorrect {
+		return true, "", nil
+	}
+
+	// Return the correct answer
+	correctAnswer, err := question.CorrectAnswer()
+	return false, correctAnswer, err
+}
+
+// creditFor returns the fraction of the question's marks, between 0 and 1,
+// that answer has earned. Every question kind is graded through here.
+func creditFor(question *models.Question, answer *models.Answer) float64 {
+	switch question.EffectiveKind() {
+	case models.KindMultipleSelect:
+		return multipleSelectCredit(question, answer.SelectedOptions)
+	case models.KindNumeric:
+		return numericCredit(question.Numeric, answer.NumericValue)
+	case models.KindShortText:
+		return textCredit(question.TextRules, answer.Text)
+	default:
+		if answer.SelectedOption == question.CorrectOption {
+			return 1
+		}
+		return 0
+	}
+}
+
+// multipleSelectCredit scores a set of selected options according to the
+// question's scoring mode.
+func multipleSelectCredit(question *models.Question, selectedOptions []int) float64 {
+	correct := make(map[int]bool, len(question.CorrectOptions))
+	for _, o := range question.CorrectOptions {
+		correct[o] = true
+	}
+	selected := make(map[int]bool, len(selectedOptions))
+	for _, o := range selectedOptions {
+		selected[o] = true
+	}
+
+	// misses counts every wrong selection; wrongPicked only those that name
+	// a real option, which is what the proportional mode judges
+	var hits, misses, wrongPicked int
+	for o := range selected {
+		switch {
+		case correct[o]:
+			hits++
+		case o >= 0 && o < len(question.Options):
+			misses++
+			wrongPicked++
+		default:
+			misses++
+		}
+	}
+
+	switch question.Scoring {
+	case models.ScoringProportional:
+		// Every option the user left out that should be left out counts too
+		total := len(question.Options)
+		if total == 0 {
+			return 0
+		}
+		rejected := total - len(correct) - wrongPicked
+		return float64(hits+rejected) / float64(total)
+	case models.ScoringRightMinusWrong:
+		if len(correct) == 0 {
+			return 0
+		}
+		net := float64(hits-misses) / float64(len(correct))
+		if net < 0 {
+			return 0
+		}
+		return net
+	default:
+		if hits == len(correct) && misses == 0 {
+			return 1
+		}
+		return 0
+	}
+}
+
+// numericEpsilon absorbs floating-point error so that an answer exactly on
+// the tolerance boundary is accepted
+const numericEpsilon = 1e-9
+
+// numericCredit awards full credit when value is within the key's tolerance
+func numericCredit(key *models.NumericKey, value *float64) float64 {
+	if key == nil || value == nil {
+		return 0
+	}
+	tolerance := key.Tolerance
+	if key.Relative {
+		tolerance *= math.Abs(key.Value)
+	}
+	if math.Abs(*value-key.Value) <= tolerance+numericEpsilon {
+		return 1
+	}
+	return 0
+}
+
+// textCredit returns the best score among the rules that text matches
+func textCredit(rules []models.TextRule, text string) float64 {
+	var best float64
+	for _, rule := range rules {
+		if !matchText(rule, text) {
+			continue
+		}
+		score := rule.Score
+		if score == 0 {
+			score = 1
+		}
+		if score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// matchText reports whether text satisfies a single rule. A rule with an
+// invalid regular expression never matches.
+func matchText(rule models.TextRule, text string) bool {
+	switch rule.Match {
+	case models.MatchRegex:
+		re, err := regexp.Compile(`^(?:` + rule.Pattern + `)$`)
+		return err == nil && re.MatchString(strings.TrimSpace(text))
+	case models.MatchNormalized:
+		return normalizeText(text) == normalizeText(rule.Pattern)
+	case models.MatchEditDistance:
+		return editDistance(normalizeText(text), normalizeText(rule.Pattern)) <= rule.MaxDistance
+	default:
+		return strings.EqualFold(strings.TrimSpace(text), strings.TrimSpace(rule.Pattern))
+	}
+}
+
+// normalizeText lower-cases s and collapses all whitespace runs to one space
+func normalizeText(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// editDistance returns the Levenshtein distance between a and b in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
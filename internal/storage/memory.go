@@ -1,126 +1,366 @@
 package storage
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"quiz-app/internal/models"
+	"quiz-app/internal/tenant"
 )
 
+// Storage keeps quizzes and results. Every call takes the context of the
+// request it serves: a backend should give up and return the context's
+// error once it is cancelled or its deadline passes. The context also
+// names the organization the call acts within (see tenant.FromContext);
+// a backend only sees and changes that organization's quizzes and
+// results, and enforces its quotas. Close flushes anything
+// not yet durable and releases the backend; it is called once on shutdown.
 type Storage interface {
-	CreateQuiz(quiz *models.Quiz) error
-	GetQuiz(id string) (*models.Quiz, error)
-	SubmitAnswer(quizID, userID string, answer *models.Answer) (bool, string, error)
-	GetResults(quizID, userID string) (*models.Result, error)
+	CreateQuiz(ctx context.Context, quiz *models.Quiz) error
+	GetQuiz(ctx context.Context, id string) (*models.Quiz, error)
+	SubmitAnswer(ctx context.Context, quizID, userID string, answer *models.Answer) (bool, string, error)
+	GetResults(ctx context.Context, quizID, userID string) (*models.Result, error)
+	ListQuizzes(ctx context.Context, filter QuizFilter) ([]models.QuizSummary, int, error)
+	UpdateQuiz(ctx context.Context, quiz *models.Quiz) error
+	DeleteQuiz(ctx context.Context, id string, archiveResults bool) error
+	StartAttempt(ctx context.Context, quizID, userID string) (*models.Result, error)
+	FinishAttempt(ctx context.Context, quizID, userID string) (*models.Result, error)
+	ListResults(ctx context.Context, quizID string) ([]models.Result, error)
+	Close() error
+}
+
+// QuizFilter selects a page of quizzes for ListQuizzes
+type QuizFilter struct {
+	Title  string // case-insensitive substring match; empty matches all
+	Offset int
+	Limit  int // zero means no limit
+}
+
+// Matches reports whether quiz passes the filter's title condition
+func (f QuizFilter) Matches(quiz *models.Quiz) bool {
+	return f.Title == "" || strings.Contains(strings.ToLower(quiz.Title), strings.ToLower(f.Title))
 }
 
 type MemoryStorage struct {
-	quizzes map[string]models.Quiz
-	results map[string]map[string]models.Result // map[quizID]map[userID]Result
+	quizzes map[quizKey]models.Quiz
+	results map[quizKey]map[string]models.Result // map[quiz]map[userID]Result
+	now     func() time.Time
 	mu      sync.RWMutex
 }
 
+// quizKey identifies a quiz within its organization
+type quizKey struct {
+	org string
+	id  string
+}
+
+// keyFor returns the key of the quiz with the given ID in the
+// organization ctx acts within
+func keyFor(ctx context.Context, id string) quizKey {
+	return quizKey{org: tenant.FromContext(ctx).ID, id: id}
+}
+
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		quizzes: make(map[string]models.Quiz),
-		results: make(map[string]map[string]models.Result),
+		quizzes: make(map[quizKey]models.Quiz),
+		results: make(map[quizKey]map[string]models.Result),
+		now:     time.Now,
 	}
 }
 
-func (m *MemoryStorage) CreateQuiz(quiz *models.Quiz) error {
+// Close does nothing: memory storage has nothing to flush
+func (m *MemoryStorage) Close() error {
+	return nil
+}
+
+// SetClock replaces the clock used to time attempts, for tests
+func (m *MemoryStorage) SetClock(now func() time.Time) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.quizzes[quiz.ID] = *quiz
+	m.now = now
+}
+
+func (m *MemoryStorage) CreateQuiz(ctx context.Context, quiz *models.Quiz) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key := keyFor(ctx, quiz.ID)
+	if _, exists := m.quizzes[key]; exists {
+		return ErrQuizExists
+	}
+	org := tenant.FromContext(ctx)
+	if err := checkQuota(org.Quotas.Quizzes, m.countQuizzes(org.ID), "quizzes"); err != nil {
+		return err
+	}
+	quiz.Version = 1
+	m.quizzes[key] = *quiz.Clone()
 	return nil
 }
 
-func (m *MemoryStorage) GetQuiz(id string) (*models.Quiz, error) {
+// ListQuizzes returns the quizzes matching filter ordered by ID, along with
+// the total number of matches before paging.
+func (m *MemoryStorage) ListQuizzes(ctx context.Context, filter QuizFilter) ([]models.QuizSummary, int, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	quiz, exists := m.quizzes[id]
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	org := tenant.FromContext(ctx).ID
+	matched := make([]models.QuizSummary, 0, len(m.quizzes))
+	for key, quiz := range m.quizzes {
+		if key.org == org && filter.Matches(&quiz) {
+			matched = append(matched, quiz.Summary())
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+	return paginate(matched, filter), len(matched), nil
+}
+
+// UpdateQuiz replaces a stored quiz. If quiz.Version is non-zero it must match
+// the stored version, otherwise ErrVersionConflict is returned. On success
+// quiz.Version is set to the new version.
+func (m *MemoryStorage) UpdateQuiz(ctx context.Context, quiz *models.Quiz) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key := keyFor(ctx, quiz.ID)
+	existing, exists := m.quizzes[key]
 	if !exists {
-		return nil, errors.New("quiz not found")
+		return ErrQuizNotFound
+	}
+	if quiz.Version != 0 && quiz.Version != existing.Version {
+		return ErrVersionConflict
 	}
-	return &quiz, nil
+	quiz.Version = existing.Version + 1
+	m.quizzes[key] = *quiz.Clone()
+	return nil
 }
 
-func (m *MemoryStorage) SubmitAnswer(quizID, userID string, answer *models.Answer) (bool, string, error) {
+// DeleteQuiz removes a quiz together with its results, so that a new quiz
+// reusing the ID starts with a clean slate. Deletes are final: there is
+// nowhere in memory to archive results to, so archiveResults is ignored.
+func (m *MemoryStorage) DeleteQuiz(ctx context.Context, id string, archiveResults bool) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key := keyFor(ctx, id)
+	if _, exists := m.quizzes[key]; !exists {
+		return ErrQuizNotFound
+	}
+	delete(m.results, key)
+	delete(m.quizzes, key)
+	return nil
+}
 
-	quiz, exists := m.quizzes[quizID]
+func (m *MemoryStorage) GetQuiz(ctx context.Context, id string) (*models.Quiz, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	quiz, exists := m.quizzes[keyFor(ctx, id)]
 	if !exists {
-		return false, "", errors.New("quiz not found")
+		return nil, ErrQuizNotFound
 	}
+	return quiz.Clone(), nil
+}
 
-	var question models.Question
-	for _, q := range quiz.Questions {
-		if q.ID == answer.QuestionID {
-			question = q
-			break
-		}
+func (m *MemoryStorage) SubmitAnswer(ctx context.Context, quizID, userID string, answer *models.Answer) (bool, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return false, "", err
 	}
 
-	if question.ID == "" {
-		return false, "", errors.New("question not found")
+	key := keyFor(ctx, quizID)
+	quiz, exists := m.quizzes[key]
+	if !exists {
+		return false, "", ErrQuizNotFound
 	}
 
-	isCorrect := answer.SelectedOption == question.CorrectOption
-	answer.IsCorrect = isCorrect
+	question := questionFor(&quiz, userID, answer)
+	if question == nil {
+		return false, "", ErrQuestionNotFound
+	}
 
 	// Initialize results for this quiz if not exist
-	if m.results[quizID] == nil {
-		m.results[quizID] = make(map[string]models.Result)
+	if m.results[key] == nil {
+		m.results[key] = make(map[string]models.Result)
 	}
 
-	// Get or initialize user's result
-	result, exists := m.results[quizID][userID]
+	// Get or initialize user's result; answering without starting an
+	// attempt starts one implicitly
+	now := m.now()
+	result, exists := m.results[key][userID]
 	if !exists {
-		result = models.Result{
-			QuizID:  quizID,
-			UserID:  userID,
-			Score:   0,
-			Answers: make(map[string]models.Answer),
+		if err := m.checkAttemptQuota(ctx); err != nil {
+			return false, "", err
+		}
+		result = newResult(quizID, userID)
+		if err := beginAttempt(&quiz, &result, now); err != nil {
+			return false, "", err
+		}
+	} else if expired, err := checkAttemptOpen(&result, now); err != nil {
+		if expired {
+			m.results[key][userID] = result
 		}
+		return false, "", err
 	}
 
-	// Update score
-	if isCorrect {
-		result.Score += float32(question.Marks)
-	} else if quiz.IsNegativeMarking {
-		result.Score -= quiz.Penalty
+	if err := checkReanswer(&quiz, &result, answer.QuestionID); err != nil {
+		return false, "", err
 	}
 
-	// Store the answer
-	result.Answers[answer.QuestionID] = *answer
+	isCorrect, correctAnswer, err := scoreAnswer(&quiz, question, &result, answer)
 
 	// Update the result in storage
-	m.results[quizID][userID] = result
+	m.results[key][userID] = result
 
-	if isCorrect {
-		return true, "", nil
+	return isCorrect, correctAnswer, err
+}
+
+// StartAttempt opens a timed attempt at a quiz for a user
+func (m *MemoryStorage) StartAttempt(ctx context.Context, quizID, userID string) (*models.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key := keyFor(ctx, quizID)
+	quiz, exists := m.quizzes[key]
+	if !exists {
+		return nil, ErrQuizNotFound
+	}
+	if _, exists := m.results[key][userID]; exists {
+		return nil, ErrAttemptExists
+	}
+	if err := m.checkAttemptQuota(ctx); err != nil {
+		return nil, err
+	}
+
+	result := newResult(quizID, userID)
+	if err := beginAttempt(&quiz, &result, m.now()); err != nil {
+		return nil, err
+	}
+	if m.results[key] == nil {
+		m.results[key] = make(map[string]models.Result)
+	}
+	m.results[key][userID] = result
+	return result.Clone(), nil
+}
+
+// FinishAttempt submits a user's attempt, locking it against further answers
+func (m *MemoryStorage) FinishAttempt(ctx context.Context, quizID, userID string) (*models.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	// Return the correct answer option
-	if question.CorrectOption >= 0 && question.CorrectOption < len(question.Options) {
-		return false, question.Options[question.CorrectOption], nil
+	key := keyFor(ctx, quizID)
+	if _, exists := m.quizzes[key]; !exists {
+		return nil, ErrQuizNotFound
 	}
-	return false, "", errors.New("invalid correct option")
+	result, exists := m.results[key][userID]
+	if !exists {
+		return nil, ErrAttemptNotFound
+	}
+	if result.IsFinished() {
+		return nil, ErrAttemptClosed
+	}
+
+	finishAttempt(&result, m.now())
+	m.results[key][userID] = result
+	return result.Clone(), nil
 }
 
-func (m *MemoryStorage) GetResults(quizID, userID string) (*models.Result, error) {
+func (m *MemoryStorage) GetResults(ctx context.Context, quizID, userID string) (*models.Result, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	quizResults, exists := m.results[quizID]
+	quizResults, exists := m.results[keyFor(ctx, quizID)]
 	if !exists {
-		return nil, errors.New("no results found for this quiz")
+		return nil, fmt.Errorf("%w for this quiz", ErrResultsNotFound)
 	}
 
 	result, exists := quizResults[userID]
 	if !exists {
-		return nil, errors.New("no results found for this user")
+		return nil, fmt.Errorf("%w for this user", ErrResultsNotFound)
 	}
 
-	return &result, nil
+	return result.Clone(), nil
+}
+
+// ListResults returns every user's result for a quiz, ordered by user ID
+func (m *MemoryStorage) ListResults(ctx context.Context, quizID string) ([]models.Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key := keyFor(ctx, quizID)
+	if _, exists := m.quizzes[key]; !exists {
+		return nil, ErrQuizNotFound
+	}
+	results := make([]models.Result, 0, len(m.results[key]))
+	for _, result := range m.results[key] {
+		results = append(results, *result.Clone())
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].UserID < results[j].UserID })
+	return results, nil
+}
+
+// countQuizzes returns the number of quizzes an organization keeps
+func (m *MemoryStorage) countQuizzes(org string) int {
+	n := 0
+	for key := range m.quizzes {
+		if key.org == org {
+			n++
+		}
+	}
+	return n
+}
+
+// checkAttemptQuota returns ErrQuotaExceeded if the organization ctx acts
+// within may not start another attempt
+func (m *MemoryStorage) checkAttemptQuota(ctx context.Context) error {
+	org := tenant.FromContext(ctx)
+	if org.Quotas.Attempts == 0 {
+		return nil
+	}
+	n := 0
+	for key, results := range m.results {
+		if key.org == org.ID {
+			n += len(results)
+		}
+	}
+	return checkQuota(org.Quotas.Attempts, n, "attempts")
+}
+
+// paginate returns the page of quizzes selected by filter's offset and limit
+func paginate(quizzes []models.QuizSummary, filter QuizFilter) []models.QuizSummary {
+	if filter.Offset >= len(quizzes) {
+		return []models.QuizSummary{}
+	}
+	quizzes = quizzes[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(quizzes) {
+		quizzes = quizzes[:filter.Limit]
+	}
+	return quizzes
 }
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations holds the SQLite schema history. Each entry is applied once, in
+// order, and its index+1 is recorded in schema_migrations. Never edit an entry
+// that has shipped; append a new one instead.
+var migrations = []string{
+	// 1: initial schema
+	`CREATE TABLE quizzes (
+		id                  TEXT PRIMARY KEY,
+		title               TEXT NOT NULL,
+		is_negative_marking INTEGER NOT NULL DEFAULT 0,
+		penalty             REAL NOT NULL DEFAULT 0
+	);
+	CREATE TABLE questions (
+		quiz_id        TEXT NOT NULL REFERENCES quizzes(id) ON DELETE CASCADE,
+		position       INTEGER NOT NULL,
+		id             TEXT NOT NULL,
+		text           TEXT NOT NULL,
+		options        TEXT NOT NULL,
+		correct_option INTEGER NOT NULL,
+		marks          INTEGER NOT NULL,
+		PRIMARY KEY (quiz_id, position)
+	);
+	CREATE TABLE results (
+		quiz_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		score   REAL NOT NULL DEFAULT 0,
+		PRIMARY KEY (quiz_id, user_id)
+	);
+	CREATE TABLE answers (
+		quiz_id         TEXT NOT NULL,
+		user_id         TEXT NOT NULL,
+		question_id     TEXT NOT NULL,
+		selected_option INTEGER NOT NULL,
+		is_correct      INTEGER NOT NULL,
+		PRIMARY KEY (quiz_id, user_id, question_id),
+		FOREIGN KEY (quiz_id, user_id) REFERENCES results(quiz_id, user_id) ON DELETE CASCADE
+	);`,
+	// 2: optimistic locking and archived results of deleted quizzes
+	`ALTER TABLE quizzes ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+	CREATE TABLE archived_results (
+		quiz_id     TEXT NOT NULL,
+		user_id     TEXT NOT NULL,
+		score       REAL NOT NULL,
+		answers     TEXT NOT NULL,
+		archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`,
+	// 3: multiple-select questions with partial credit
+	`ALTER TABLE questions ADD COLUMN correct_options TEXT NOT NULL DEFAULT 'null';
+	ALTER TABLE questions ADD COLUMN scoring TEXT NOT NULL DEFAULT '';
+	ALTER TABLE answers ADD COLUMN selected_options TEXT NOT NULL DEFAULT 'null';
+	ALTER TABLE answers ADD COLUMN score REAL NOT NULL DEFAULT 0;`,
+	// 4: question kinds with numeric and short-text answers
+	`ALTER TABLE questions ADD COLUMN kind TEXT NOT NULL DEFAULT '';
+	ALTER TABLE questions ADD COLUMN numeric TEXT NOT NULL DEFAULT 'null';
+	ALTER TABLE questions ADD COLUMN text_rules TEXT NOT NULL DEFAULT 'null';
+	ALTER TABLE answers ADD COLUMN numeric_value REAL;
+	ALTER TABLE answers ADD COLUMN text TEXT NOT NULL DEFAULT '';`,
+	// 5: timed attempts and quiz opening windows
+	`ALTER TABLE quizzes ADD COLUMN duration_seconds INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE quizzes ADD COLUMN opens_at TIMESTAMP;
+	ALTER TABLE quizzes ADD COLUMN closes_at TIMESTAMP;
+	ALTER TABLE results ADD COLUMN started_at TIMESTAMP;
+	ALTER TABLE results ADD COLUMN deadline TIMESTAMP;
+	ALTER TABLE results ADD COLUMN finished_at TIMESTAMP;
+	ALTER TABLE results ADD COLUMN elapsed_seconds REAL NOT NULL DEFAULT 0;`,
+	// 6: re-answer policy
+	`ALTER TABLE quizzes ADD COLUMN answer_policy TEXT NOT NULL DEFAULT '';`,
+	// 7: feedback disclosure
+	`ALTER TABLE quizzes ADD COLUMN feedback_mode TEXT NOT NULL DEFAULT '';`,
+	// 8: question pools, per-user shuffling and showing marks to takers
+	`ALTER TABLE quizzes ADD COLUMN show_marks BOOLEAN NOT NULL DEFAULT 0;
+	ALTER TABLE quizzes ADD COLUMN draws TEXT NOT NULL DEFAULT 'null';
+	ALTER TABLE quizzes ADD COLUMN shuffle_questions BOOLEAN NOT NULL DEFAULT 0;
+	ALTER TABLE quizzes ADD COLUMN shuffle_options BOOLEAN NOT NULL DEFAULT 0;
+	ALTER TABLE quizzes ADD COLUMN seed INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE questions ADD COLUMN pool TEXT NOT NULL DEFAULT '';`,
+	// 9: per-question time limits
+	`ALTER TABLE questions ADD COLUMN time_limit_seconds INTEGER NOT NULL DEFAULT 0;`,
+	// 10: organizations. Quiz IDs are only unique within an organization,
+	// so the keyed tables are rebuilt with org_id leading their keys and
+	// existing rows move to the default organization.
+	`CREATE TABLE quizzes_by_org (
+		org_id              TEXT NOT NULL,
+		id                  TEXT NOT NULL,
+		title               TEXT NOT NULL,
+		is_negative_marking INTEGER NOT NULL DEFAULT 0,
+		penalty             REAL NOT NULL DEFAULT 0,
+		version             INTEGER NOT NULL DEFAULT 1,
+		duration_seconds    INTEGER NOT NULL DEFAULT 0,
+		opens_at            TIMESTAMP,
+		closes_at           TIMESTAMP,
+		answer_policy       TEXT NOT NULL DEFAULT '',
+		feedback_mode       TEXT NOT NULL DEFAULT '',
+		show_marks          BOOLEAN NOT NULL DEFAULT 0,
+		draws               TEXT NOT NULL DEFAULT 'null',
+		shuffle_questions   BOOLEAN NOT NULL DEFAULT 0,
+		shuffle_options     BOOLEAN NOT NULL DEFAULT 0,
+		seed                INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (org_id, id)
+	);
+	INSERT INTO quizzes_by_org (org_id, id, title, is_negative_marking, penalty, version, duration_seconds, opens_at,
+			closes_at, answer_policy, feedback_mode, show_marks, draws, shuffle_questions, shuffle_options, seed)
+		SELECT 'default', id, title, is_negative_marking, penalty, version, duration_seconds, opens_at,
+			closes_at, answer_policy, feedback_mode, show_marks, draws, shuffle_questions, shuffle_options, seed
+		FROM quizzes;
+	CREATE TABLE questions_by_org (
+		org_id             TEXT NOT NULL,
+		quiz_id            TEXT NOT NULL,
+		position           INTEGER NOT NULL,
+		id                 TEXT NOT NULL,
+		kind               TEXT NOT NULL DEFAULT '',
+		text               TEXT NOT NULL,
+		options            TEXT NOT NULL,
+		correct_option     INTEGER NOT NULL,
+		correct_options    TEXT NOT NULL DEFAULT 'null',
+		scoring            TEXT NOT NULL DEFAULT '',
+		numeric            TEXT NOT NULL DEFAULT 'null',
+		text_rules         TEXT NOT NULL DEFAULT 'null',
+		marks              INTEGER NOT NULL,
+		pool               TEXT NOT NULL DEFAULT '',
+		time_limit_seconds INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (org_id, quiz_id, position),
+		FOREIGN KEY (org_id, quiz_id) REFERENCES quizzes_by_org(org_id, id) ON DELETE CASCADE
+	);
+	INSERT INTO questions_by_org (org_id, quiz_id, position, id, kind, text, options, correct_option, correct_options,
+			scoring, numeric, text_rules, marks, pool, time_limit_seconds)
+		SELECT 'default', quiz_id, position, id, kind, text, options, correct_option, correct_options,
+			scoring, numeric, text_rules, marks, pool, time_limit_seconds
+		FROM questions;
+	CREATE TABLE results_by_org (
+		org_id          TEXT NOT NULL,
+		quiz_id         TEXT NOT NULL,
+		user_id         TEXT NOT NULL,
+		score           REAL NOT NULL DEFAULT 0,
+		started_at      TIMESTAMP,
+		deadline        TIMESTAMP,
+		finished_at     TIMESTAMP,
+		elapsed_seconds REAL NOT NULL DEFAULT 0,
+		PRIMARY KEY (org_id, quiz_id, user_id)
+	);
+	INSERT INTO results_by_org (org_id, quiz_id, user_id, score, started_at, deadline, finished_at, elapsed_seconds)
+		SELECT 'default', quiz_id, user_id, score, started_at, deadline, finished_at, elapsed_seconds
+		FROM results;
+	CREATE TABLE answers_by_org (
+		org_id           TEXT NOT NULL,
+		quiz_id          TEXT NOT NULL,
+		user_id          TEXT NOT NULL,
+		question_id      TEXT NOT NULL,
+		selected_option  INTEGER NOT NULL,
+		selected_options TEXT NOT NULL DEFAULT 'null',
+		numeric_value    REAL,
+		text             TEXT NOT NULL DEFAULT '',
+		is_correct       INTEGER NOT NULL,
+		score            REAL NOT NULL DEFAULT 0,
+		PRIMARY KEY (org_id, quiz_id, user_id, question_id),
+		FOREIGN KEY (org_id, quiz_id, user_id) REFERENCES results_by_org(org_id, quiz_id, user_id) ON DELETE CASCADE
+	);
+	INSERT INTO answers_by_org (org_id, quiz_id, user_id, question_id, selected_option, selected_options,
+			numeric_value, text, is_correct, score)
+		SELECT 'default', quiz_id, user_id, question_id, selected_option, selected_options,
+			numeric_value, text, is_correct, score
+		FROM answers;
+	DROP TABLE answers;
+	DROP TABLE results;
+	DROP TABLE questions;
+	DROP TABLE quizzes;
+	ALTER TABLE quizzes_by_org RENAME TO quizzes;
+	ALTER TABLE questions_by_org RENAME TO questions;
+	ALTER TABLE results_by_org RENAME TO results;
+	ALTER TABLE answers_by_org RENAME TO answers;
+	ALTER TABLE archived_results ADD COLUMN org_id TEXT NOT NULL DEFAULT 'default';`,
+}
+
+// migrate brings the database schema up to date, applying each pending
+// migration in its own transaction.
+func migrate(db *sql.DB) error {
+	return migrateTo(db, len(migrations))
+}
+
+// migrateTo applies the pending migrations up to and including version
+func migrateTo(db *sql.DB, version int) error {
+	if version > len(migrations) {
+		return fmt.Errorf("no migration %d", version)
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for i := current; i < version; i++ {
+		applied := i + 1
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %d: %w", applied, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, applied); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %d: %w", applied, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d: %w", applied, err)
+		}
+	}
+	return nil
+}
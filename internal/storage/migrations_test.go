@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"quiz-app/internal/models"
+	"quiz-app/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations_UpgradeMovesDataToDefaultOrg(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quiz.db")
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on")
+	require.NoError(t, err)
+	// Migration 10 introduced organizations
+	require.NoError(t, migrateTo(db, 9))
+	for _, stmt := range []string{
+		`INSERT INTO quizzes (id, title, is_negative_marking, penalty) VALUES ('1', 'Test Quiz', 1, 0.5)`,
+		`INSERT INTO questions (quiz_id, position, id, text, options, correct_option, marks)
+			VALUES ('1', 0, 'q1', 'What is 1+1?', '["1","2","3","4"]', 1, 2),
+			       ('1', 1, 'q2', 'What is 2+2?', '["2","3","4","5"]', 2, 3)`,
+		`INSERT INTO results (quiz_id, user_id, score) VALUES ('1', 'tom', 2)`,
+		`INSERT INTO answers (quiz_id, user_id, question_id, selected_option, is_correct, score)
+			VALUES ('1', 'tom', 'q1', 1, 1, 2)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.Close())
+
+	store, err := NewSQLiteStorage(path)
+	require.NoError(t, err)
+	defer store.Close()
+	ctx := context.Background()
+
+	quiz, err := store.GetQuiz(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, &models.Quiz{
+		ID:                "1",
+		Title:             "Test Quiz",
+		IsNegativeMarking: true,
+		Penalty:           0.5,
+		Version:           1,
+		Questions: []models.Question{
+			{ID: "q1", Text: "What is 1+1?", Options: []string{"1", "2", "3", "4"}, CorrectOption: 1, Marks: 2},
+			{ID: "q2", Text: "What is 2+2?", Options: []string{"2", "3", "4", "5"}, CorrectOption: 2, Marks: 3},
+		},
+	}, quiz)
+
+	result, err := store.GetResults(ctx, "1", "tom")
+	require.NoError(t, err)
+	assert.Equal(t, float32(2), result.Score)
+	assert.Equal(t, models.Answer{QuestionID: "q1", SelectedOption: 1, IsCorrect: true, Score: 2}, result.Answers["q1"])
+
+	physics := tenant.WithOrg(ctx, &tenant.Org{ID: "physics"})
+	_, err = store.GetQuiz(physics, "1")
+	assert.ErrorIs(t, err, ErrQuizNotFound, "upgraded rows belong to the default organization only")
+
+	// The rebuilt tables keep their cascades
+	require.NoError(t, store.DeleteQuiz(ctx, "1", false))
+	for _, table := range []string{"quizzes", "questions", "results", "answers"} {
+		var rows int
+		require.NoError(t, store.db.QueryRow(`SELECT COUNT(*) FROM `+table).Scan(&rows))
+		assert.Zero(t, rows, table)
+	}
+}
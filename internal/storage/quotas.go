@@ -0,0 +1,13 @@
+package storage
+
+import "fmt"
+
+// checkQuota returns ErrQuotaExceeded if an organization that already has
+// used of something may not add another under limit. A zero limit means
+// no limit.
+func checkQuota(limit, used int, what string) error {
+	if limit > 0 && used >= limit {
+		return fmt.Errorf("%w: at most %d %s", ErrQuotaExceeded, limit, what)
+	}
+	return nil
+}
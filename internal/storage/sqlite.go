@@ -0,0 +1,705 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"quiz-app/internal/models"
+	"quiz-app/internal/tenant"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is go-sqlite3 with a fold() SQL function that lower-cases text
+// like strings.ToLower, so that title filters fold case exactly as
+// MemoryStorage does rather than with SQLite's ASCII-only LOWER().
+const driverName = "sqlite3_quiz"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("fold", strings.ToLower, true)
+		},
+	})
+}
+
+// SQLiteStorage is a durable Storage backed by a SQLite database file.
+type SQLiteStorage struct {
+	db    *sql.DB
+	now   func() time.Time
+	clock sync.RWMutex // guards now
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx so that loaders can run
+// inside or outside a transaction.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// NewSQLiteStorage opens (or creates) the database at path and migrates its
+// schema to the latest version.
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	db, err := sql.Open(driverName, path+"?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serialising connections avoids
+	// "database is locked" errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStorage{db: db, now: time.Now}, nil
+}
+
+// Close checkpoints the write-ahead log into the database file and
+// releases the underlying database handle.
+func (s *SQLiteStorage) Close() error {
+	_, checkpointErr := s.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	return errors.Join(checkpointErr, s.db.Close())
+}
+
+// SetClock replaces the clock used to time attempts, for tests
+func (s *SQLiteStorage) SetClock(now func() time.Time) {
+	s.clock.Lock()
+	defer s.clock.Unlock()
+	s.now = now
+}
+
+// currentTime reads the storage's clock
+func (s *SQLiteStorage) currentTime() time.Time {
+	s.clock.RLock()
+	defer s.clock.RUnlock()
+	return s.now()
+}
+
+func (s *SQLiteStorage) CreateQuiz(ctx context.Context, quiz *models.Quiz) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	org := tenant.FromContext(ctx)
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM quizzes WHERE org_id = ? AND id = ?)`,
+		org.ID, quiz.ID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrQuizExists
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM quizzes WHERE org_id = ?`, org.ID).Scan(&n); err != nil {
+		return err
+	}
+	if err := checkQuota(org.Quotas.Quizzes, n, "quizzes"); err != nil {
+		return err
+	}
+
+	draws, err := marshalColumns(quiz.Draws)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO quizzes (org_id, id, title, is_negative_marking, penalty, answer_policy, feedback_mode,
+			show_marks, draws, shuffle_questions, shuffle_options, seed, duration_seconds, opens_at, closes_at, version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`,
+		org.ID, quiz.ID, quiz.Title, quiz.IsNegativeMarking, quiz.Penalty, quiz.AnswerPolicy, quiz.FeedbackMode,
+		quiz.ShowMarks, draws[0], quiz.ShuffleQuestions, quiz.ShuffleOptions, quiz.Seed, quiz.DurationSeconds,
+		nullTime(quiz.OpensAt), nullTime(quiz.ClosesAt)); err != nil {
+		return err
+	}
+	if err := insertQuestions(ctx, tx, quiz); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	quiz.Version = 1
+	return nil
+}
+
+func (s *SQLiteStorage) ListQuizzes(ctx context.Context, filter QuizFilter) ([]models.QuizSummary, int, error) {
+	const where = `WHERE org_id = ? AND instr(fold(title), fold(?)) > 0`
+	org := orgID(ctx)
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM quizzes `+where, org, filter.Title).
+		Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// A negative LIMIT is no limit in SQLite
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT id, title, version,
+			(SELECT COUNT(*) FROM questions WHERE questions.org_id = quizzes.org_id AND questions.quiz_id = quizzes.id)
+		FROM quizzes `+where+` ORDER BY id LIMIT ? OFFSET ?`, org, filter.Title, limit, filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	quizzes := []models.QuizSummary{}
+	for rows.Next() {
+		var quiz models.QuizSummary
+		if err := rows.Scan(&quiz.ID, &quiz.Title, &quiz.Version, &quiz.QuestionCount); err != nil {
+			return nil, 0, err
+		}
+		quizzes = append(quizzes, quiz)
+	}
+	return quizzes, total, rows.Err()
+}
+
+func (s *SQLiteStorage) UpdateQuiz(ctx context.Context, quiz *models.Quiz) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	org := orgID(ctx)
+	var version int
+	err = tx.QueryRowContext(ctx, `SELECT version FROM quizzes WHERE org_id = ? AND id = ?`, org, quiz.ID).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrQuizNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if quiz.Version != 0 && quiz.Version != version {
+		return ErrVersionConflict
+	}
+
+	draws, err := marshalColumns(quiz.Draws)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE quizzes SET title = ?, is_negative_marking = ?, penalty = ?, answer_policy = ?,
+			feedback_mode = ?, show_marks = ?, draws = ?, shuffle_questions = ?, shuffle_options = ?, seed = ?,
+			duration_seconds = ?, opens_at = ?, closes_at = ?, version = ?
+		WHERE org_id = ? AND id = ?`,
+		quiz.Title, quiz.IsNegativeMarking, quiz.Penalty, quiz.AnswerPolicy, quiz.FeedbackMode,
+		quiz.ShowMarks, draws[0], quiz.ShuffleQuestions, quiz.ShuffleOptions, quiz.Seed, quiz.DurationSeconds,
+		nullTime(quiz.OpensAt), nullTime(quiz.ClosesAt), version+1, org, quiz.ID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM questions WHERE org_id = ? AND quiz_id = ?`, org, quiz.ID); err != nil {
+		return err
+	}
+	if err := insertQuestions(ctx, tx, quiz); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	quiz.Version = version + 1
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteQuiz(ctx context.Context, id string, archiveResults bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	org := orgID(ctx)
+	res, err := tx.ExecContext(ctx, `DELETE FROM quizzes WHERE org_id = ? AND id = ?`, org, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrQuizNotFound
+	}
+
+	if archiveResults {
+		if err := archiveQuizResults(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM results WHERE org_id = ? AND quiz_id = ?`, org, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) GetQuiz(ctx context.Context, id string) (*models.Quiz, error) {
+	return loadQuiz(ctx, s.db, id)
+}
+
+func (s *SQLiteStorage) SubmitAnswer(ctx context.Context, quizID, userID string, answer *models.Answer) (bool, string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, "", err
+	}
+	defer tx.Rollback()
+
+	quiz, err := loadQuiz(ctx, tx, quizID)
+	if err != nil {
+		return false, "", err
+	}
+
+	question := questionFor(quiz, userID, answer)
+	if question == nil {
+		return false, "", ErrQuestionNotFound
+	}
+
+	result, err := loadResult(ctx, tx, quizID, userID)
+	if err != nil {
+		return false, "", err
+	}
+
+	// Answering without starting an attempt starts one implicitly
+	now := s.currentTime()
+	if result == nil {
+		if err := checkAttemptQuota(ctx, tx); err != nil {
+			return false, "", err
+		}
+		r := newResult(quizID, userID)
+		result = &r
+		if err := beginAttempt(quiz, result, now); err != nil {
+			return false, "", err
+		}
+	} else if expired, err := checkAttemptOpen(result, now); err != nil {
+		if expired {
+			if saveErr := saveResult(ctx, tx, result); saveErr != nil {
+				return false, "", saveErr
+			}
+			if commitErr := tx.Commit(); commitErr != nil {
+				return false, "", commitErr
+			}
+		}
+		return false, "", err
+	}
+
+	if err := checkReanswer(quiz, result, answer.QuestionID); err != nil {
+		return false, "", err
+	}
+
+	isCorrect, correctAnswer, scoreErr := scoreAnswer(quiz, question, result, answer)
+
+	// Under the best-answer policy the kept answer may be an earlier one
+	kept := result.Answers[answer.QuestionID]
+	if err := saveResult(ctx, tx, result); err != nil {
+		return false, "", err
+	}
+	if err := saveAnswer(ctx, tx, result, &kept); err != nil {
+		return false, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, "", err
+	}
+	return isCorrect, correctAnswer, scoreErr
+}
+
+func (s *SQLiteStorage) StartAttempt(ctx context.Context, quizID, userID string) (*models.Result, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	quiz, err := loadQuiz(ctx, tx, quizID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := loadResult(ctx, tx, quizID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrAttemptExists
+	}
+	if err := checkAttemptQuota(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	result := newResult(quizID, userID)
+	if err := beginAttempt(quiz, &result, s.currentTime()); err != nil {
+		return nil, err
+	}
+	if err := saveResult(ctx, tx, &result); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *SQLiteStorage) FinishAttempt(ctx context.Context, quizID, userID string) (*models.Result, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := loadQuiz(ctx, tx, quizID); err != nil {
+		return nil, err
+	}
+	result, err := loadResult(ctx, tx, quizID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, ErrAttemptNotFound
+	}
+	if result.IsFinished() {
+		return nil, ErrAttemptClosed
+	}
+
+	finishAttempt(result, s.currentTime())
+	if err := saveResult(ctx, tx, result); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *SQLiteStorage) GetResults(ctx context.Context, quizID, userID string) (*models.Result, error) {
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM results WHERE org_id = ? AND quiz_id = ?`,
+		orgID(ctx), quizID).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%w for this quiz", ErrResultsNotFound)
+	}
+
+	result, err := loadResult(ctx, s.db, quizID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, fmt.Errorf("%w for this user", ErrResultsNotFound)
+	}
+	return result, nil
+}
+
+func (s *SQLiteStorage) ListResults(ctx context.Context, quizID string) ([]models.Result, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM quizzes WHERE org_id = ? AND id = ?)`,
+		orgID(ctx), quizID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrQuizNotFound
+	}
+
+	return loadResults(ctx, tx, quizID)
+}
+
+// loadQuiz reads a quiz and its questions in their original order.
+func loadQuiz(ctx context.Context, q queryer, id string) (*models.Quiz, error) {
+	quiz := &models.Quiz{}
+	var opensAt, closesAt sql.NullTime
+	var draws string
+	err := q.QueryRowContext(ctx, `SELECT id, title, is_negative_marking, penalty, answer_policy, feedback_mode, show_marks, draws,
+			shuffle_questions, shuffle_options, seed, duration_seconds, opens_at, closes_at, version
+		FROM quizzes WHERE org_id = ? AND id = ?`, orgID(ctx), id).
+		Scan(&quiz.ID, &quiz.Title, &quiz.IsNegativeMarking, &quiz.Penalty, &quiz.AnswerPolicy, &quiz.FeedbackMode,
+			&quiz.ShowMarks, &draws, &quiz.ShuffleQuestions, &quiz.ShuffleOptions, &quiz.Seed,
+			&quiz.DurationSeconds, &opensAt, &closesAt, &quiz.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrQuizNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	quiz.OpensAt, quiz.ClosesAt = timePtr(opensAt), timePtr(closesAt)
+	if err := unmarshalColumns(quiz.ID, map[string]columnJSON{"draws": {draws, &quiz.Draws}}); err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT id, kind, text, options, correct_option, correct_options, scoring, numeric, text_rules, marks, pool,
+			time_limit_seconds
+		FROM questions WHERE org_id = ? AND quiz_id = ? ORDER BY position`, orgID(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quiz.Questions = []models.Question{}
+	for rows.Next() {
+		var question models.Question
+		var options, correctOptions, numeric, textRules string
+		if err := rows.Scan(&question.ID, &question.Kind, &question.Text, &options, &question.CorrectOption,
+			&correctOptions, &question.Scoring, &numeric, &textRules, &question.Marks, &question.Pool,
+			&question.TimeLimitSeconds); err != nil {
+			return nil, err
+		}
+		if err := unmarshalColumns(question.ID, map[string]columnJSON{
+			"options":         {options, &question.Options},
+			"correct options": {correctOptions, &question.CorrectOptions},
+			"numeric key":     {numeric, &question.Numeric},
+			"text rules":      {textRules, &question.TextRules},
+		}); err != nil {
+			return nil, err
+		}
+		quiz.Questions = append(quiz.Questions, question)
+	}
+	return quiz, rows.Err()
+}
+
+// loadResult reads a user's result for a quiz, or returns nil if the user has
+// not answered anything yet.
+func loadResult(ctx context.Context, q queryer, quizID, userID string) (*models.Result, error) {
+	result := newResult(quizID, userID)
+	var startedAt, deadline, finishedAt sql.NullTime
+	err := q.QueryRowContext(ctx, `SELECT score, started_at, deadline, finished_at, elapsed_seconds
+		FROM results WHERE org_id = ? AND quiz_id = ? AND user_id = ?`, orgID(ctx), quizID, userID).
+		Scan(&result.Score, &startedAt, &deadline, &finishedAt, &result.ElapsedSeconds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.StartedAt, result.Deadline, result.FinishedAt = timePtr(startedAt), timePtr(deadline), timePtr(finishedAt)
+
+	rows, err := q.QueryContext(ctx, `SELECT question_id, selected_option, selected_options, numeric_value, text, is_correct, score
+		FROM answers WHERE org_id = ? AND quiz_id = ? AND user_id = ?`, orgID(ctx), quizID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAnswer(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Answers[a.QuestionID] = a
+	}
+	return &result, rows.Err()
+}
+
+// loadResults reads every result of a quiz, ordered by user ID, in one query
+// for the results and one for their answers.
+func loadResults(ctx context.Context, q queryer, quizID string) ([]models.Result, error) {
+	rows, err := q.QueryContext(ctx, `SELECT user_id, score, started_at, deadline, finished_at, elapsed_seconds
+		FROM results WHERE org_id = ? AND quiz_id = ? ORDER BY user_id`, orgID(ctx), quizID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.Result{}
+	index := map[string]int{}
+	for rows.Next() {
+		result := newResult(quizID, "")
+		var startedAt, deadline, finishedAt sql.NullTime
+		if err := rows.Scan(&result.UserID, &result.Score, &startedAt, &deadline, &finishedAt,
+			&result.ElapsedSeconds); err != nil {
+			return nil, err
+		}
+		result.StartedAt, result.Deadline, result.FinishedAt = timePtr(startedAt), timePtr(deadline), timePtr(finishedAt)
+		index[result.UserID] = len(results)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	rows, err = q.QueryContext(ctx, `SELECT user_id, question_id, selected_option, selected_options, numeric_value, text,
+			is_correct, score
+		FROM answers WHERE org_id = ? AND quiz_id = ?`, orgID(ctx), quizID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+		a, err := scanAnswer(rows, &userID)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[userID]; ok {
+			results[i].Answers[a.QuestionID] = a
+		}
+	}
+	return results, rows.Err()
+}
+
+// scanAnswer reads an answer row, after any leading columns into dest.
+func scanAnswer(rows *sql.Rows, dest ...any) (models.Answer, error) {
+	var a models.Answer
+	var selectedOptions string
+	var numericValue sql.NullFloat64
+	dest = append(dest, &a.QuestionID, &a.SelectedOption, &selectedOptions, &numericValue, &a.Text,
+		&a.IsCorrect, &a.Score)
+	if err := rows.Scan(dest...); err != nil {
+		return a, err
+	}
+	if err := json.Unmarshal([]byte(selectedOptions), &a.SelectedOptions); err != nil {
+		return a, fmt.Errorf("decode selected options of answer %s: %w", a.QuestionID, err)
+	}
+	if numericValue.Valid {
+		a.NumericValue = &numericValue.Float64
+	}
+	return a, nil
+}
+
+// saveResult writes the result's score and attempt timing.
+func saveResult(ctx context.Context, tx *sql.Tx, result *models.Result) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO results (org_id, quiz_id, user_id, score, started_at, deadline, finished_at,
+			elapsed_seconds)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(org_id, quiz_id, user_id) DO UPDATE SET
+			score = excluded.score,
+			started_at = excluded.started_at,
+			deadline = excluded.deadline,
+			finished_at = excluded.finished_at,
+			elapsed_seconds = excluded.elapsed_seconds`,
+		orgID(ctx), result.QuizID, result.UserID, result.Score, nullTime(result.StartedAt), nullTime(result.Deadline),
+		nullTime(result.FinishedAt), result.ElapsedSeconds)
+	return err
+}
+
+// saveAnswer writes one of the result's answers.
+func saveAnswer(ctx context.Context, tx *sql.Tx, result *models.Result, answer *models.Answer) error {
+	selectedOptions, err := json.Marshal(answer.SelectedOptions)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO answers (org_id, quiz_id, user_id, question_id, selected_option, selected_options,
+			numeric_value, text, is_correct, score)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(org_id, quiz_id, user_id, question_id) DO UPDATE SET
+			selected_option = excluded.selected_option,
+			selected_options = excluded.selected_options,
+			numeric_value = excluded.numeric_value,
+			text = excluded.text,
+			is_correct = excluded.is_correct,
+			score = excluded.score`,
+		orgID(ctx), result.QuizID, result.UserID, answer.QuestionID, answer.SelectedOption, string(selectedOptions),
+		answer.NumericValue, answer.Text, answer.IsCorrect, answer.Score)
+	return err
+}
+
+// insertQuestions writes quiz's questions in order.
+func insertQuestions(ctx context.Context, tx *sql.Tx, quiz *models.Quiz) error {
+	for i, q := range quiz.Questions {
+		columns, err := marshalColumns(q.Options, q.CorrectOptions, q.Numeric, q.TextRules)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO questions (org_id, quiz_id, position, id, kind, text, options, correct_option,
+				correct_options, scoring, numeric, text_rules, marks, pool, time_limit_seconds)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			orgID(ctx), quiz.ID, i, q.ID, q.Kind, q.Text, columns[0], q.CorrectOption,
+			columns[1], q.Scoring, columns[2], columns[3], q.Marks, q.Pool, q.TimeLimitSeconds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveQuizResults copies every result of a quiz into archived_results.
+func archiveQuizResults(ctx context.Context, tx *sql.Tx, quizID string) error {
+	results, err := loadResults(ctx, tx, quizID)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		answers, err := json.Marshal(result.Answers)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO archived_results (org_id, quiz_id, user_id, score, answers)
+			VALUES (?, ?, ?, ?, ?)`,
+			orgID(ctx), quizID, result.UserID, result.Score, string(answers)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orgID returns the ID of the organization ctx acts within
+func orgID(ctx context.Context) string {
+	return tenant.FromContext(ctx).ID
+}
+
+// checkAttemptQuota returns ErrQuotaExceeded if the organization ctx acts
+// within may not start another attempt
+func checkAttemptQuota(ctx context.Context, q queryer) error {
+	org := tenant.FromContext(ctx)
+	if org.Quotas.Attempts == 0 {
+		return nil
+	}
+	var n int
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM results WHERE org_id = ?`, org.ID).Scan(&n); err != nil {
+		return err
+	}
+	return checkQuota(org.Quotas.Attempts, n, "attempts")
+}
+
+// columnJSON pairs a JSON-encoded column value with its destination
+type columnJSON struct {
+	raw  string
+	dest any
+}
+
+// unmarshalColumns decodes the JSON columns of the row identified by id
+func unmarshalColumns(id string, columns map[string]columnJSON) error {
+	for name, c := range columns {
+		if err := json.Unmarshal([]byte(c.raw), c.dest); err != nil {
+			return fmt.Errorf("decode %s of %s: %w", name, id, err)
+		}
+	}
+	return nil
+}
+
+// marshalColumns JSON-encodes values for storage in TEXT columns
+func marshalColumns(values ...any) ([]string, error) {
+	columns := make([]string, len(values))
+	for i, v := range values {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = string(raw)
+	}
+	return columns, nil
+}
+
+// nullTime converts an optional time for storage
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// timePtr converts a stored optional time back, in UTC
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
@@ -0,0 +1,182 @@
+// Package tenant keeps the organizations that share a server apart. Every
+// request acts within one organization, which travels in its context;
+// storage keys quizzes and results by it and enforces its quotas, so two
+// organizations may both have a quiz "1" without seeing each other's.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"quiz-app/internal/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultID is the organization of requests and callers that name none.
+// It always exists, so a server without organizations configured works as
+// a single tenant.
+const DefaultID = "default"
+
+// URLVar is the route variable that names the organization in paths under
+// /orgs/{org}
+const URLVar = "org"
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ErrUnknownOrg is returned when credentials name an organization the
+// server does not know
+var ErrUnknownOrg = errors.New("unknown organization")
+
+// Quotas limit what an organization may store. Zero means no limit.
+type Quotas struct {
+	Quizzes  int // quizzes kept at once
+	Attempts int // results across all its quizzes, one per user and quiz
+}
+
+// Org is an organization: a namespace of quizzes and results with its own
+// quotas. Admins lists the users who administer it; they may do anything
+// within it, but nothing in other organizations.
+type Org struct {
+	ID     string
+	Quotas Quotas
+	Admins []string
+}
+
+// IsAdmin reports whether subject administers the organization
+func (o *Org) IsAdmin(subject string) bool {
+	for _, admin := range o.Admins {
+		if admin == subject {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultOrg is the organization of contexts that carry none
+var defaultOrg = &Org{ID: DefaultID}
+
+// ParseOrgs reads organizations written as comma-separated entries of an
+// ID followed by colon-separated settings, for example
+//
+//	physics:quizzes=50:attempts=2000:admin=ada:admin=grace,chemistry
+//
+// The settings are quizzes and attempts, the quotas, and admin, which may
+// be repeated.
+func ParseOrgs(s string) ([]Org, error) {
+	var orgs []Org
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		org := Org{ID: fields[0]}
+		for _, field := range fields[1:] {
+			name, value, _ := strings.Cut(field, "=")
+			switch name {
+			case "quizzes", "attempts":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("tenant: %s: %s must be a non-negative integer", org.ID, name)
+				}
+				if name == "quizzes" {
+					org.Quotas.Quizzes = n
+				} else {
+					org.Quotas.Attempts = n
+				}
+			case "admin":
+				if value == "" {
+					return nil, fmt.Errorf("tenant: %s: empty admin", org.ID)
+				}
+				org.Admins = append(org.Admins, value)
+			default:
+				return nil, fmt.Errorf("tenant: %s: unknown setting %q", org.ID, name)
+			}
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, nil
+}
+
+// Directory holds the organizations a server knows. A nil Directory knows
+// only the default organization, without quotas.
+type Directory struct {
+	orgs map[string]*Org
+}
+
+// NewDirectory creates a directory of orgs. The default organization is
+// added unless orgs configures it.
+func NewDirectory(orgs []Org) (*Directory, error) {
+	d := &Directory{orgs: map[string]*Org{DefaultID: defaultOrg}}
+	seen := make(map[string]bool)
+	for _, org := range orgs {
+		if !validID.MatchString(org.ID) {
+			return nil, fmt.Errorf("tenant: invalid organization ID %q", org.ID)
+		}
+		if seen[org.ID] {
+			return nil, fmt.Errorf("tenant: organization %q is configured twice", org.ID)
+		}
+		seen[org.ID] = true
+		org := org
+		d.orgs[org.ID] = &org
+	}
+	return d, nil
+}
+
+// Lookup returns the organization with the given ID
+func (d *Directory) Lookup(id string) (*Org, bool) {
+	if d == nil {
+		if id == DefaultID {
+			return defaultOrg, true
+		}
+		return nil, false
+	}
+	org, ok := d.orgs[id]
+	return org, ok
+}
+
+// Requested returns the organization named in r's path, or "" if the path
+// names none
+func Requested(r *http.Request) string {
+	return mux.Vars(r)[URLVar]
+}
+
+// Middleware puts the organization named in the request path, or the
+// default organization, in the request's context. An unknown organization
+// is answered with 404.
+func (d *Directory) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := Requested(r)
+		if id == "" {
+			id = DefaultID
+		}
+		org, ok := d.Lookup(id)
+		if !ok {
+			apierror.Write(w, http.StatusNotFound, "Organization not found")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithOrg(r.Context(), org)))
+	})
+}
+
+type orgKey struct{}
+
+// WithOrg returns a context that acts within org
+func WithOrg(ctx context.Context, org *Org) context.Context {
+	return context.WithValue(ctx, orgKey{}, org)
+}
+
+// FromContext returns the organization ctx acts within, or the default
+// organization if it carries none
+func FromContext(ctx context.Context) *Org {
+	if org, ok := ctx.Value(orgKey{}).(*Org); ok {
+		return org
+	}
+	return defaultOrg
+}
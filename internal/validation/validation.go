@@ -0,0 +1,205 @@
+// Package validation checks quiz definitions before they are stored, so that
+// mistakes are reported to the author rather than surfacing while grading.
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"quiz-app/internal/models"
+)
+
+// FieldError is a problem with one field of a quiz. Field is the JSON path
+// of the field, such as "questions[2].correct_option".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors lists every problem found in a quiz
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *Errors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateQuiz checks a quiz definition and returns every problem found, or
+// nil if there are none. A quiz may have no questions yet, so that it can be
+// drafted and filled in later.
+func ValidateQuiz(quiz *models.Quiz) Errors {
+	var errs Errors
+
+	switch {
+	case strings.TrimSpace(quiz.ID) == "":
+		errs.add("id", "is required")
+	case strings.ContainsAny(quiz.ID, "/?#"):
+		errs.add("id", "must not contain '/', '?' or '#'")
+	}
+	if quiz.Penalty < 0 {
+		errs.add("penalty", "must not be negative")
+	}
+	if quiz.Penalty != 0 && !quiz.IsNegativeMarking {
+		errs.add("penalty", "requires is_negative_marking")
+	}
+	switch quiz.AnswerPolicy {
+	case "", models.PolicyLastWins, models.PolicyFirstFinal, models.PolicyBest:
+	default:
+		errs.add("answer_policy", "unknown policy %q", quiz.AnswerPolicy)
+	}
+	switch quiz.FeedbackMode {
+	case "", models.FeedbackImmediate, models.FeedbackAfterAttempt, models.FeedbackAfterClose, models.FeedbackNever:
+	default:
+		errs.add("feedback_mode", "unknown mode %q", quiz.FeedbackMode)
+	}
+	if quiz.FeedbackMode == models.FeedbackAfterClose && quiz.ClosesAt == nil {
+		errs.add("feedback_mode", "after_close requires closes_at")
+	}
+	if quiz.DurationSeconds < 0 {
+		errs.add("duration_seconds", "must not be negative")
+	}
+	if quiz.OpensAt != nil && quiz.ClosesAt != nil && !quiz.OpensAt.Before(*quiz.ClosesAt) {
+		errs.add("closes_at", "must be after opens_at")
+	}
+
+	ids := make(map[string]int)
+	pools := make(map[string]int)
+	for i := range quiz.Questions {
+		q := &quiz.Questions[i]
+		path := fmt.Sprintf("questions[%d]", i)
+		if first, dup := ids[q.ID]; dup && q.ID != "" {
+			errs.add(path+".id", "duplicates questions[%d].id", first)
+		} else {
+			ids[q.ID] = i
+		}
+		if q.Pool != "" {
+			pools[q.Pool]++
+		}
+		validateQuestion(&errs, path, q)
+	}
+
+	drawn := make(map[string]bool)
+	for i, draw := range quiz.Draws {
+		path := fmt.Sprintf("draws[%d]", i)
+		switch {
+		case draw.Pool == "":
+			errs.add(path+".pool", "is required")
+		case pools[draw.Pool] == 0:
+			errs.add(path+".pool", "no question is in pool %q", draw.Pool)
+		case drawn[draw.Pool]:
+			errs.add(path+".pool", "pool %q is drawn from more than once", draw.Pool)
+		case draw.Count > pools[draw.Pool]:
+			errs.add(path+".count", "pool %q only has %d questions", draw.Pool, pools[draw.Pool])
+		}
+		drawn[draw.Pool] = true
+		if draw.Count < 1 {
+			errs.add(path+".count", "must be at least 1")
+		}
+	}
+	return errs
+}
+
+func validateQuestion(errs *Errors, path string, q *models.Question) {
+	if strings.TrimSpace(q.ID) == "" {
+		errs.add(path+".id", "is required")
+	}
+	if strings.TrimSpace(q.Text) == "" {
+		errs.add(path+".text", "is required")
+	}
+	if q.Marks < 0 {
+		errs.add(path+".marks", "must not be negative")
+	}
+	if q.TimeLimitSeconds < 0 {
+		errs.add(path+".time_limit_seconds", "must not be negative")
+	}
+
+	switch q.EffectiveKind() {
+	case models.KindSingleChoice:
+		validateOptions(errs, path, q)
+		if q.CorrectOption < 0 || q.CorrectOption >= len(q.Options) {
+			errs.add(path+".correct_option", "must be the index of an option (0 to %d)", len(q.Options)-1)
+		}
+	case models.KindMultipleSelect:
+		validateOptions(errs, path, q)
+		if len(q.CorrectOptions) == 0 {
+			errs.add(path+".correct_options", "must name at least one option")
+		}
+		seen := make(map[int]bool)
+		for j, option := range q.CorrectOptions {
+			field := fmt.Sprintf("%s.correct_options[%d]", path, j)
+			switch {
+			case option < 0 || option >= len(q.Options):
+				errs.add(field, "must be the index of an option (0 to %d)", len(q.Options)-1)
+			case seen[option]:
+				errs.add(field, "repeats option %d", option)
+			}
+			seen[option] = true
+		}
+		switch q.Scoring {
+		case "", models.ScoringAllOrNothing, models.ScoringProportional, models.ScoringRightMinusWrong:
+		default:
+			errs.add(path+".scoring", "unknown scoring %q", q.Scoring)
+		}
+	case models.KindNumeric:
+		switch {
+		case q.Numeric == nil:
+			errs.add(path+".numeric", "is required for numeric questions")
+		case q.Numeric.Tolerance < 0:
+			errs.add(path+".numeric.tolerance", "must not be negative")
+		}
+	case models.KindShortText:
+		if len(q.TextRules) == 0 {
+			errs.add(path+".text_rules", "must have at least one rule")
+		}
+		for j, rule := range q.TextRules {
+			validateTextRule(errs, fmt.Sprintf("%s.text_rules[%d]", path, j), rule)
+		}
+	default:
+		errs.add(path+".kind", "unknown kind %q", q.Kind)
+	}
+}
+
+func validateOptions(errs *Errors, path string, q *models.Question) {
+	if len(q.Options) < 2 {
+		errs.add(path+".options", "must have at least two options")
+	}
+	for j, option := range q.Options {
+		if strings.TrimSpace(option) == "" {
+			errs.add(fmt.Sprintf("%s.options[%d]", path, j), "must not be empty")
+		}
+	}
+}
+
+func validateTextRule(errs *Errors, path string, rule models.TextRule) {
+	if rule.Pattern == "" {
+		errs.add(path+".pattern", "is required")
+	}
+	switch rule.Match {
+	case models.MatchCaseInsensitive, models.MatchNormalized:
+	case models.MatchRegex:
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			errs.add(path+".pattern", "is not a valid regular expression: %v", err)
+		}
+	case models.MatchEditDistance:
+		if rule.MaxDistance < 0 {
+			errs.add(path+".max_distance", "must not be negative")
+		}
+	default:
+		errs.add(path+".match", "unknown match %q", rule.Match)
+	}
+	if rule.Score < 0 || rule.Score > 1 {
+		errs.add(path+".score", "must be between 0 and 1")
+	}
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"quiz-app/internal/analytics"
+	"quiz-app/internal/models"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func analysedQuiz(t *testing.T) (*storage.MemoryStorage, *models.Quiz) {
+	t.Helper()
+	quiz := &models.Quiz{
+		ID: "1",
+		Questions: []models.Question{
+			{ID: "q1", Options: []string{"a", "b", "c", "d"}, CorrectOption: 1, Marks: 1},
+			{ID: "q2", Options: []string{"w", "x", "y"}, CorrectOption: 0, Marks: 1},
+			{ID: "q3", Kind: models.KindNumeric, Numeric: &models.NumericKey{Value: 3}, Marks: 1},
+		},
+	}
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+	answers := map[string][2]int{"u1": {1, 0}, "u2": {1, 1}, "u3": {0, 1}, "u4": {2, 2}}
+	for user, picks := range answers {
+		for i, pick := range picks {
+			_, _, err := store.SubmitAnswer(context.Background(), "1", user, &models.Answer{QuestionID: quiz.Questions[i].ID, SelectedOption: pick})
+			require.NoError(t, err)
+		}
+	}
+	return store, quiz
+}
+
+func TestAnalytics_Items(t *testing.T) {
+	store, quiz := analysedQuiz(t)
+	results, err := store.ListResults(context.Background(), "1")
+	require.NoError(t, err)
+
+	items := analytics.Items(quiz, results)
+	require.Len(t, items, 3)
+	want := 0.5 / math.Sqrt(0.75)
+
+	q1 := items[0]
+	assert.Equal(t, 4, q1.Presented)
+	assert.Equal(t, 4, q1.Answered)
+	assert.InDelta(t, 0.5, *q1.Difficulty, 1e-9)
+	assert.InDelta(t, want, *q1.Discrimination, 1e-9)
+	var counts []int
+	var never []bool
+	for _, o := range q1.Options {
+		counts = append(counts, o.Count)
+		never = append(never, o.NeverChosen)
+	}
+	assert.Equal(t, []int{1, 2, 1, 0}, counts)
+	assert.Equal(t, []bool{false, false, false, true}, never)
+	assert.True(t, q1.Options[1].IsCorrect)
+	assert.InDelta(t, 0.5, q1.Options[1].Rate, 1e-9)
+
+	q2 := items[1]
+	assert.InDelta(t, 0.25, *q2.Difficulty, 1e-9)
+	assert.InDelta(t, want, *q2.Discrimination, 1e-9)
+
+	q3 := items[2]
+	assert.Equal(t, 0, q3.Answered)
+	assert.Equal(t, 0.0, *q3.Difficulty)
+	assert.Nil(t, q3.Discrimination)
+	assert.Nil(t, q3.Options)
+}
+
+func TestAnalytics_ItemsCountOnlyPresentedQuestions(t *testing.T) {
+	quiz := pooledQuiz()
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.CreateQuiz(context.Background(), quiz))
+	_, _, err := store.SubmitAnswer(context.Background(), "pooled", "alice", &models.Answer{QuestionID: "intro"})
+	require.NoError(t, err)
+	results, _ := store.ListResults(context.Background(), "pooled")
+
+	paper := quiz.PaperFor("alice")
+	for _, item := range analytics.Items(quiz, results) {
+		if paper.Question(item.QuestionID) != nil {
+			assert.Equal(t, 1, item.Presented, item.QuestionID)
+		} else {
+			assert.Equal(t, 0, item.Presented, item.QuestionID)
+			assert.Nil(t, item.Difficulty)
+		}
+	}
+}
+
+func TestItemAnalysisEndpoint(t *testing.T) {
+	store, _ := analysedQuiz(t)
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+
+	req, _ := http.NewRequest("GET", "/quiz/1/analytics/items", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var body struct {
+		Respondents int                   `json:"respondents"`
+		Items       []analytics.ItemStats `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, 4, body.Respondents)
+	require.Len(t, body.Items, 3)
+	assert.Equal(t, "q1", body.Items[0].QuestionID)
+
+	req, _ = http.NewRequest("GET", "/quiz/missing/analytics/items", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
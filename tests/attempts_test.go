@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"quiz-app/internal/controllers"
+	"quiz-app/internal/models"
+	"quiz-app/internal/storage"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// clockedStorage is a Storage whose clock can be set by tests
+type clockedStorage interface {
+	storage.Storage
+	SetClock(now func() time.Time)
+}
+
+// fakeClock is a manually advanced clock
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newClockedBackends() map[string]func(t *testing.T) clockedStorage {
+	return map[string]func(t *testing.T) clockedStorage{
+		"memory": func(t *testing.T) clockedStorage { return storage.NewMemoryStorage() },
+		"sqlite": func(t *testing.T) clockedStorage { s, _ := newSQLiteStorage(t); return s },
+	}
+}
+
+func TestStorage_TimedAttempts(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	for name, newStore := range newClockedBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			clock := &fakeClock{now: start}
+			store.SetClock(clock.Now)
+
+			quiz := sampleQuiz()
+			quiz.DurationSeconds = 600
+			assert.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+			result, err := store.StartAttempt(context.Background(), "1", "user1")
+			assert.NoError(t, err)
+			assert.True(t, start.Equal(*result.StartedAt))
+			assert.True(t, start.Add(10*time.Minute).Equal(*result.Deadline))
+
+			_, err = store.StartAttempt(context.Background(), "1", "user1")
+			assert.ErrorIs(t, err, storage.ErrAttemptExists)
+
+			clock.Advance(5 * time.Minute)
+			_, _, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+			assert.NoError(t, err)
+
+			// Answers after the deadline are rejected and the attempt is closed at the deadline
+			clock.Advance(6 * time.Minute)
+			_, _, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q2", SelectedOption: 2})
+			assert.ErrorIs(t, err, storage.ErrAttemptClosed)
+
+			result, err = store.GetResults(context.Background(), "1", "user1")
+			assert.NoError(t, err)
+			assert.Equal(t, float32(2), result.Score)
+			assert.Len(t, result.Answers, 1)
+			assert.True(t, start.Add(10*time.Minute).Equal(*result.FinishedAt))
+			assert.Equal(t, float64(600), result.ElapsedSeconds)
+
+			_, err = store.FinishAttempt(context.Background(), "1", "user1")
+			assert.ErrorIs(t, err, storage.ErrAttemptClosed)
+		})
+	}
+}
+
+func TestStorage_FinishAttemptLocksAnswers(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	for name, newStore := range newClockedBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			clock := &fakeClock{now: start}
+			store.SetClock(clock.Now)
+			assert.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+
+			_, err := store.FinishAttempt(context.Background(), "1", "user1")
+			assert.ErrorIs(t, err, storage.ErrAttemptNotFound)
+
+			// Answering without an explicit start opens the attempt
+			_, _, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+			assert.NoError(t, err)
+
+			clock.Advance(90 * time.Second)
+			result, err := store.FinishAttempt(context.Background(), "1", "user1")
+			assert.NoError(t, err)
+			assert.Equal(t, float64(90), result.ElapsedSeconds)
+			assert.Nil(t, result.Deadline)
+
+			_, _, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q2", SelectedOption: 2})
+			assert.ErrorIs(t, err, storage.ErrAttemptClosed)
+		})
+	}
+}
+
+func TestStorage_QuizWindow(t *testing.T) {
+	opens := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	closes := opens.Add(time.Hour)
+
+	for name, newStore := range newClockedBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			clock := &fakeClock{now: opens.Add(-time.Minute)}
+			store.SetClock(clock.Now)
+
+			quiz := sampleQuiz()
+			quiz.OpensAt, quiz.ClosesAt = &opens, &closes
+			quiz.DurationSeconds = 3600
+			assert.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+			_, err := store.StartAttempt(context.Background(), "1", "user1")
+			assert.ErrorIs(t, err, storage.ErrQuizNotOpen)
+
+			// An attempt started late is cut short by the closing time
+			clock.Advance(31 * time.Minute)
+			result, err := store.StartAttempt(context.Background(), "1", "user1")
+			assert.NoError(t, err)
+			assert.True(t, closes.Equal(*result.Deadline))
+
+			clock.now = closes
+			_, _, err = store.SubmitAnswer(context.Background(), "1", "user2", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+			assert.ErrorIs(t, err, storage.ErrQuizNotOpen)
+		})
+	}
+}
+
+func TestAttemptEndpoints(t *testing.T) {
+	mockStorage := new(MockStorage)
+	controller := controllers.NewQuizController(mockStorage)
+
+	started := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	mockStorage.On("StartAttempt", "1", "user1").Return(&models.Result{QuizID: "1", UserID: "user1", StartedAt: &started}, nil)
+	mockStorage.On("StartAttempt", "1", "user2").Return((*models.Result)(nil), storage.ErrAttemptExists)
+	mockStorage.On("FinishAttempt", "1", "user3").Return((*models.Result)(nil), storage.ErrAttemptNotFound)
+	answer := models.Answer{QuestionID: "q1", SelectedOption: 1}
+	mockStorage.On("SubmitAnswer", "1", "user1", &answer).Return(false, "", storage.ErrAttemptClosed)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/quiz/{quizId}/attempts", controller.StartAttempt)
+	router.HandleFunc("/quiz/{quizId}/attempts/{userId}/finish", controller.FinishAttempt)
+	router.HandleFunc("/quiz/{quizId}/answer/{userId}", controller.SubmitAnswer)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"start", "/quiz/1/attempts", `{"user_id": "user1"}`, http.StatusCreated},
+		{"start twice", "/quiz/1/attempts", `{"user_id": "user2"}`, http.StatusConflict},
+		{"start without user", "/quiz/1/attempts", `{}`, http.StatusBadRequest},
+		{"finish without attempt", "/quiz/1/attempts/user3/finish", ``, http.StatusNotFound},
+		{"late answer", "/quiz/1/answer/user1", `{"question_id": "q1", "selected_option": 1}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tt.path, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.status, rr.Code)
+		})
+	}
+	mockStorage.AssertExpectations(t)
+}
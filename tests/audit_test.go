@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"quiz-app/internal/audit"
+	"quiz-app/internal/auth"
+	"quiz-app/internal/middleware"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+	"quiz-app/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAuditHarness(t *testing.T, events audit.Log) *authHarness {
+	t.Helper()
+	keys, err := auth.ParseAPIKeys("ann:author:ann-key, tom:taker:tom-key, root:admin:root-key")
+	require.NoError(t, err)
+	authn, err := auth.New(auth.Options{APIKeys: keys})
+	require.NoError(t, err)
+	store := storage.NewMemoryStorage()
+	return &authHarness{store: store, router: routes.SetupRoutes(store, authn, nil, nil, events)}
+}
+
+type auditPage struct {
+	Events []audit.Event `json:"events"`
+	Total  int           `json:"total"`
+}
+
+func TestAudit_RecordsChangesAndAnswers(t *testing.T) {
+	h := newAuditHarness(t, audit.NewMemory())
+	ann := http.Header{auth.APIKeyHeader: {"ann-key"}}
+	tom := http.Header{auth.APIKeyHeader: {"tom-key"}, middleware.RequestIDHeader: {"req-42"}}
+	root := http.Header{auth.APIKeyHeader: {"root-key"}}
+
+	quiz, err := json.Marshal(sampleQuiz())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, h.do("POST", "/quiz", ann, string(quiz)).Code)
+	require.Equal(t, http.StatusOK, h.do("PUT", "/quiz/1", ann,
+		strings.Replace(string(quiz), "Test Quiz", "Arithmetic", 1)).Code)
+	rr := h.do("POST", "/quiz/1/answer", tom, `{"question_id": "q1", "selected_option": 1}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "req-42", rr.Header().Get(middleware.RequestIDHeader))
+	require.Equal(t, http.StatusOK, h.do("POST", "/quiz/1/answer", tom, `{"question_id": "q2", "selected_option": 0}`).Code)
+	require.Equal(t, http.StatusNotFound, h.do("POST", "/quiz/1/answer", tom, `{"question_id": "q9"}`).Code)
+	require.Equal(t, http.StatusNoContent, h.do("DELETE", "/quiz/1", ann, "").Code)
+
+	assert.Equal(t, http.StatusForbidden, h.do("GET", "/audit", ann, "").Code, "only admins read the audit log")
+
+	rr = h.do("GET", "/audit", root, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var page auditPage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Equal(t, 6, page.Total)
+	created, updated, right, wrong, missing, deleted := page.Events[0], page.Events[1], page.Events[2],
+		page.Events[3], page.Events[4], page.Events[5]
+
+	assert.Equal(t, audit.ActionQuizCreate, created.Action)
+	assert.Equal(t, "ann", created.Actor)
+	assert.Equal(t, "author", created.ActorRole)
+	assert.Equal(t, "default", created.Org)
+	assert.NotEmpty(t, created.RequestID, "requests without an ID are given one")
+	assert.Empty(t, created.BeforeHash)
+	assert.Equal(t, created.AfterHash, updated.BeforeHash, "hashes chain from one change to the next")
+	assert.NotEqual(t, updated.BeforeHash, updated.AfterHash)
+	assert.Equal(t, updated.AfterHash, deleted.BeforeHash)
+	assert.Empty(t, deleted.AfterHash)
+
+	assert.Equal(t, audit.ActionAnswerSubmit, right.Action)
+	assert.Equal(t, "req-42", right.RequestID)
+	assert.Equal(t, "tom", right.UserID)
+	assert.Equal(t, "q1", right.QuestionID)
+	assert.Equal(t, 1, right.Submission.SelectedOption)
+	assert.Equal(t, &audit.Outcome{Correct: true, QuestionScore: 2, Score: 2}, right.Outcome)
+	assert.Empty(t, right.BeforeHash, "the first answer starts the result")
+	assert.Equal(t, right.AfterHash, wrong.BeforeHash)
+	assert.Equal(t, &audit.Outcome{Correct: false, CorrectAnswer: "4", QuestionScore: -0.5, Score: 1.5}, wrong.Outcome)
+
+	assert.Equal(t, "question not found", missing.Error)
+	assert.Nil(t, missing.Outcome)
+	assert.Equal(t, wrong.AfterHash, missing.AfterHash, "a refused answer changes nothing")
+
+	for i, event := range page.Events {
+		assert.Equal(t, int64(i+1), event.Seq)
+	}
+}
+
+func TestAudit_FiltersAndExport(t *testing.T) {
+	h := newAuditHarness(t, audit.NewMemory())
+	ann := http.Header{auth.APIKeyHeader: {"ann-key"}}
+	tom := http.Header{auth.APIKeyHeader: {"tom-key"}}
+	root := http.Header{auth.APIKeyHeader: {"root-key"}}
+
+	quiz, err := json.Marshal(sampleQuiz())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, h.do("POST", "/quiz", ann, string(quiz)).Code)
+	for _, q := range []string{"q1", "q2"} {
+		h.do("POST", "/quiz/1/answer", tom, `{"question_id": "`+q+`", "selected_option": 0}`)
+		h.do("POST", "/quiz/1/answer/ann", root, `{"question_id": "`+q+`", "selected_option": 0}`)
+	}
+
+	var page auditPage
+	rr := h.do("GET", "/audit?action=answer.submit&user_id=tom&limit=1", root, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Equal(t, 2, page.Total)
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, "q1", page.Events[0].QuestionID)
+
+	rr = h.do("GET", "/audit?actor=root&format=ndjson", root, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=audit-default.ndjson", rr.Header().Get("Content-Disposition"))
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	require.Len(t, lines, 2, "exports are not paged")
+	var last audit.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &last))
+	assert.Equal(t, "ann", last.UserID, "admins may answer for other users")
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	rr = h.do("GET", "/audit?since="+future, root, "")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Zero(t, page.Total)
+
+	assert.Equal(t, http.StatusBadRequest, h.do("GET", "/audit?since=yesterday", root, "").Code)
+	assert.Equal(t, http.StatusBadRequest, h.do("GET", "/audit?format=csv", root, "").Code)
+}
+
+func TestAuditFile_AppendOnlyAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+	ctx := context.Background()
+
+	events, err := audit.OpenFile(path)
+	require.NoError(t, err)
+	events.SetClock(clock.Now)
+	require.NoError(t, events.Append(ctx, &audit.Event{Org: "default", Action: audit.ActionQuizCreate, QuizID: "1"}))
+	clock.Advance(time.Hour)
+	require.NoError(t, events.Append(ctx, &audit.Event{Org: "physics", Action: audit.ActionQuizCreate, QuizID: "1"}))
+	require.NoError(t, events.Close())
+
+	events, err = audit.OpenFile(path)
+	require.NoError(t, err)
+	defer events.Close()
+	events.SetClock(clock.Now)
+	clock.Advance(time.Hour)
+	event := &audit.Event{Org: "default", Action: audit.ActionQuizDelete, QuizID: "1"}
+	require.NoError(t, events.Append(ctx, event))
+	assert.Equal(t, int64(3), event.Seq, "numbering continues after reopening")
+
+	found, total, err := events.Query(ctx, audit.Filter{Org: "default"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, audit.ActionQuizDelete, found[1].Action)
+
+	found, _, err = events.Query(ctx, audit.Filter{
+		Since: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Until: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "physics", found[0].Org)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+	}
+	assert.Equal(t, 3, lines)
+}
+
+func TestAuditFile_QueryStopsAtLastAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	ctx := context.Background()
+	events, err := audit.OpenFile(path)
+	require.NoError(t, err)
+	defer events.Close()
+	require.NoError(t, events.Append(ctx, &audit.Event{Org: "default", Action: audit.ActionQuizCreate, QuizID: "1"}))
+
+	// An event still being written is not read
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq": 2, "org": "def`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	found, total, err := events.Query(ctx, audit.Filter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, int64(1), found[0].Seq)
+}
+
+func TestAudit_KeptPerOrganization(t *testing.T) {
+	orgs, err := tenant.NewDirectory([]tenant.Org{{ID: "physics"}})
+	require.NoError(t, err)
+	store := storage.NewMemoryStorage()
+	h := &authHarness{store: store, router: routes.SetupRoutes(store, nil, orgs, nil, audit.NewMemory())}
+	body, _ := json.Marshal(sampleQuiz())
+
+	require.Equal(t, http.StatusCreated, h.do("POST", "/orgs/physics/quiz", nil, string(body)).Code)
+	require.Equal(t, http.StatusCreated, h.do("POST", "/quiz", nil, string(body)).Code)
+
+	var page auditPage
+	require.NoError(t, json.Unmarshal(h.do("GET", "/orgs/physics/audit", nil, "").Body.Bytes(), &page))
+	require.Equal(t, 1, page.Total)
+	assert.Equal(t, "physics", page.Events[0].Org)
+}
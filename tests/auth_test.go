@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"quiz-app/internal/auth"
+	"quiz-app/internal/models"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = []byte("test-secret")
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, subject string, role auth.Role, ttl time.Duration) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, auth.Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+	}).SignedString(key)
+	require.NoError(t, err)
+	return token
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+type authHarness struct {
+	store  *storage.MemoryStorage
+	router *mux.Router
+}
+
+func newAuthHarness(t *testing.T, opts auth.Options) *authHarness {
+	t.Helper()
+	authn, err := auth.New(opts)
+	require.NoError(t, err)
+	store := storage.NewMemoryStorage()
+	return &authHarness{store: store, router: routes.SetupRoutes(store, authn, opts.Orgs, nil, nil)}
+}
+
+func (h *authHarness) do(method, path string, header http.Header, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	rr := httptest.NewRecorder()
+	h.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAuthAPIKeys(t *testing.T) {
+	keys, err := auth.ParseAPIKeys("ada:author:author-key, tom:taker:taker-key:with:colons")
+	require.NoError(t, err)
+	h := newAuthHarness(t, auth.Options{APIKeys: keys})
+	quiz, _ := json.Marshal(sampleQuiz())
+
+	rr := h.do("POST", "/quiz", nil, string(quiz))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, `Bearer realm="quiz"`, rr.Header().Get("WWW-Authenticate"))
+
+	rr = h.do("POST", "/quiz", http.Header{auth.APIKeyHeader: {"wrong-key"}}, string(quiz))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = h.do("POST", "/quiz", http.Header{auth.APIKeyHeader: {"taker-key:with:colons"}}, string(quiz))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = h.do("POST", "/quiz", http.Header{auth.APIKeyHeader: {"author-key"}}, string(quiz))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = h.do("GET", "/quiz/1?view=author", http.Header{auth.APIKeyHeader: {"taker-key:with:colons"}}, "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = h.do("GET", "/quiz/1", http.Header{auth.APIKeyHeader: {"taker-key:with:colons"}}, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestAuthDisabledHidesAnswerKeys(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	h := &authHarness{store: store, router: routes.SetupRoutes(store, nil, nil, nil, nil)}
+	require.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+
+	rr := h.do("GET", "/quiz/1?view=author", nil, "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "correct_option")
+	assert.Equal(t, http.StatusOK, h.do("GET", "/quiz/1", nil, "").Code)
+}
+
+func TestAuthTakerActsForThemselves(t *testing.T) {
+	h := newAuthHarness(t, auth.Options{HMACSecret: testSecret})
+	require.NoError(t, h.store.CreateQuiz(context.Background(), sampleQuiz()))
+	tom := bearer(signToken(t, jwt.SigningMethodHS256, testSecret, "tom", auth.RoleTaker, time.Hour))
+	ada := bearer(signToken(t, jwt.SigningMethodHS256, testSecret, "ada", auth.RoleAuthor, time.Hour))
+
+	rr := h.do("POST", "/quiz/1/answer", tom, `{"question_id": "q1", "selected_option": 1}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	result, err := h.store.GetResults(context.Background(), "1", "tom")
+	require.NoError(t, err)
+	assert.Equal(t, float32(2), result.Score)
+
+	rr = h.do("POST", "/quiz/1/answer/eve", tom, `{"question_id": "q2", "selected_option": 2}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = h.do("POST", "/quiz/1/answer/tom", tom, `{"question_id": "q2", "selected_option": 2}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = h.do("POST", "/quiz/1/answer", ada, `{"question_id": "q1", "selected_option": 1}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "authors do not answer quizzes")
+
+	rr = h.do("GET", "/quiz/1/results", tom, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = h.do("GET", "/quiz/1/results/eve", tom, "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = h.do("GET", "/quiz/1/results/tom", ada, "")
+	assert.Equal(t, http.StatusOK, rr.Code, "authors see everyone's results")
+}
+
+func TestAuthTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	h := newAuthHarness(t, auth.Options{HMACSecret: testSecret, RSAPublicKey: &rsaKey.PublicKey, Issuer: "idp"})
+	require.NoError(t, h.store.CreateQuiz(context.Background(), sampleQuiz()))
+
+	withIssuer := func(method jwt.SigningMethod, key interface{}, claims auth.Claims) http.Header {
+		claims.Issuer = "idp"
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+		return bearer(token)
+	}
+	valid := auth.Claims{Role: auth.RoleAdmin, RegisteredClaims: jwt.RegisteredClaims{
+		Subject: "root", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := valid
+	noExpiry.ExpiresAt = nil
+	badRole := valid
+	badRole.Role = "owner"
+
+	tests := []struct {
+		name   string
+		header http.Header
+		status int
+	}{
+		{"HS256", withIssuer(jwt.SigningMethodHS256, testSecret, valid), http.StatusOK},
+		{"RS256", withIssuer(jwt.SigningMethodRS256, rsaKey, valid), http.StatusOK},
+		{"wrong issuer", bearer(signToken(t, jwt.SigningMethodHS256, testSecret, "root", auth.RoleAdmin, time.Hour)), http.StatusUnauthorized},
+		{"expired", withIssuer(jwt.SigningMethodHS256, testSecret, expired), http.StatusUnauthorized},
+		{"no expiry", withIssuer(jwt.SigningMethodHS256, testSecret, noExpiry), http.StatusUnauthorized},
+		{"unknown role", withIssuer(jwt.SigningMethodHS256, testSecret, badRole), http.StatusUnauthorized},
+		{"wrong secret", withIssuer(jwt.SigningMethodHS256, []byte("guess"), valid), http.StatusUnauthorized},
+		{"unsigned", withIssuer(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid), http.StatusUnauthorized},
+		{"malformed", bearer("not-a-token"), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := h.do("GET", "/quiz/1/analytics/stats", tt.header, "")
+			assert.Equal(t, tt.status, rr.Code)
+		})
+	}
+}
+
+func TestAuthRejectsRSAKeyUsedAsHMACSecret(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	h := newAuthHarness(t, auth.Options{RSAPublicKey: &rsaKey.PublicKey})
+
+	// Only RS256 is accepted, so an HS256 token is refused whatever its key
+	token := signToken(t, jwt.SigningMethodHS256, []byte("public key bytes"), "root", auth.RoleAdmin, time.Hour)
+	rr := h.do("GET", "/quiz", bearer(token), "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAuthRandomizedQuizUsesTokenSubject(t *testing.T) {
+	h := newAuthHarness(t, auth.Options{HMACSecret: testSecret})
+	require.NoError(t, h.store.CreateQuiz(context.Background(), pooledQuiz()))
+	tom := bearer(signToken(t, jwt.SigningMethodHS256, testSecret, "tom", auth.RoleTaker, time.Hour))
+
+	rr := h.do("GET", "/quiz/pooled", tom, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var view models.TakerQuiz
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &view))
+	assert.Len(t, view.Questions, len(pooledQuiz().PaperFor("tom").Quiz.Questions))
+}
+
+func TestNewAuthenticatorNeedsCredentials(t *testing.T) {
+	_, err := auth.New(auth.Options{})
+	assert.Error(t, err)
+	_, err = auth.New(auth.Options{APIKeys: []auth.APIKey{{Key: "k", Principal: auth.Principal{Subject: "a", Role: "owner"}}}})
+	assert.ErrorContains(t, err, `unknown role "owner"`)
+	_, err = auth.ParseAPIKeys("ada-author-key")
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"quiz-app/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg, err := config.Load(nil, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, config.Default(), cfg)
+	assert.False(t, cfg.TLS())
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, "quiz.yaml", `
+addr: ":9000"
+storage: sqlite
+db_path: /tmp/file.db
+read_timeout: 5s
+max_body_bytes: 2048
+`)
+
+	cfg, err := config.Load(
+		[]string{"-config", path, "-db", "/tmp/flag.db"},
+		env(map[string]string{"QUIZ_ADDR": ":9100", "QUIZ_DB": "/tmp/env.db", "QUIZ_IDLE_TIMEOUT": "1m"}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, ":9100", cfg.Addr, "environment overrides the file")
+	assert.Equal(t, "/tmp/flag.db", cfg.DBPath, "flags override the environment")
+	assert.Equal(t, "sqlite", cfg.Storage)
+	assert.Equal(t, 5*time.Second, cfg.ReadTimeout)
+	assert.Equal(t, time.Minute, cfg.IdleTimeout)
+	assert.Equal(t, int64(2048), cfg.MaxBodyBytes)
+	assert.Equal(t, config.Default().WriteTimeout, cfg.WriteTimeout)
+}
+
+func TestConfigTOMLFile(t *testing.T) {
+	path := writeConfig(t, "quiz.toml", `
+# served over TLS
+addr = ":8443"   # public port
+tls_cert_file = "/etc/quiz/cert.pem"
+tls_key_file = "/etc/quiz/key.pem"
+max_body_bytes = 1_048_576
+shutdown_timeout = "10s"
+`)
+
+	cfg, err := config.Load(nil, env(map[string]string{"QUIZ_CONFIG": path}))
+	require.NoError(t, err)
+	assert.Equal(t, ":8443", cfg.Addr)
+	assert.True(t, cfg.TLS())
+	assert.Equal(t, "/etc/quiz/key.pem", cfg.TLSKeyFile)
+	assert.Equal(t, int64(1<<20), cfg.MaxBodyBytes)
+	assert.Equal(t, 10*time.Second, cfg.ShutdownTimeout)
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		err  string
+	}{
+		{"unknown backend", []string{"-storage", "postgres"}, nil, `unknown storage backend "postgres"`},
+		{"bad duration", nil, map[string]string{"QUIZ_WRITE_TIMEOUT": "soon"}, "QUIZ_WRITE_TIMEOUT"},
+		{"half of TLS", []string{"-tls-cert", "cert.pem"}, nil, "tls_cert_file and tls_key_file must be set together"},
+		{"body limit", []string{"-max-body-bytes", "0"}, nil, "max_body_bytes must be positive"},
+		{"unknown flag", []string{"-port", "80"}, nil, "flag provided but not defined"},
+		{"missing file", []string{"-config", "/nonexistent/quiz.yaml"}, nil, "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Load(tt.args, env(tt.env))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	t.Run("unknown file setting", func(t *testing.T) {
+		path := writeConfig(t, "quiz.yml", "addr: \":80\"\nport: 80\n")
+		_, err := config.Load([]string{"-config", path}, env(nil))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown settings port")
+	})
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"quiz-app/internal/controllers"
+	"quiz-app/internal/middleware"
+	"quiz-app/internal/models"
+	"quiz-app/internal/storage"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageHonoursCancelledContext(t *testing.T) {
+	sqliteStore, _ := newSQLiteStorage(t)
+	backends := map[string]storage.Storage{
+		"memory": storage.NewMemoryStorage(),
+		"sqlite": sqliteStore,
+	}
+	for name, store := range backends {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := store.GetQuiz(ctx, "1")
+			assert.ErrorIs(t, err, context.Canceled)
+			_, _, err = store.SubmitAnswer(ctx, "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+			assert.ErrorIs(t, err, context.Canceled)
+
+			// Nothing was recorded by the cancelled call
+			_, err = store.GetResults(context.Background(), "1", "user1")
+			assert.ErrorIs(t, err, storage.ErrResultsNotFound)
+		})
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	mockStorage := new(MockStorage)
+	controller := controllers.NewQuizController(mockStorage)
+	mockStorage.On("GetQuiz", "1").Return(&models.Quiz{}, context.DeadlineExceeded)
+
+	var deadline time.Time
+	router := mux.NewRouter()
+	router.Use(middleware.Timeout(time.Minute))
+	router.HandleFunc("/quiz/{id}", func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+		controller.GetQuiz(w, r)
+	})
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/quiz/1?view=author", nil))
+
+	assert.WithinDuration(t, start.Add(time.Minute), deadline, 5*time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.JSONEq(t, `{"error": {"code": "service_unavailable", "message": "Request timed out"}}`, rr.Body.String())
+}
+
+func TestTimeoutMiddleware_ZeroSetsNoDeadline(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		hasDeadline := true
+		handler := middleware.Timeout(d)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, hasDeadline = r.Context().Deadline()
+			assert.NoError(t, r.Context().Err())
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/quiz/1", nil))
+		assert.False(t, hasDeadline, d)
+	}
+}
@@ -2,18 +2,23 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"quiz-app/internal/apierror"
 	"quiz-app/internal/controllers"
 	"quiz-app/internal/models"
+	"quiz-app/internal/storage"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockStorage is a mock implementation of the Storage interface
@@ -21,26 +26,61 @@ type MockStorage struct {
 	mock.Mock
 }
 
-func (m *MockStorage) CreateQuiz(quiz *models.Quiz) error {
+func (m *MockStorage) CreateQuiz(ctx context.Context, quiz *models.Quiz) error {
 	args := m.Called(quiz)
 	return args.Error(0)
 }
 
-func (m *MockStorage) GetQuiz(id string) (*models.Quiz, error) {
+func (m *MockStorage) GetQuiz(ctx context.Context, id string) (*models.Quiz, error) {
 	args := m.Called(id)
 	return args.Get(0).(*models.Quiz), args.Error(1)
 }
 
-func (m *MockStorage) SubmitAnswer(quizID, userID string, answer *models.Answer) (bool, string, error) {
+func (m *MockStorage) SubmitAnswer(ctx context.Context, quizID, userID string, answer *models.Answer) (bool, string, error) {
 	args := m.Called(quizID, userID, answer)
 	return args.Bool(0), args.String(1), args.Error(2)
 }
 
-func (m *MockStorage) GetResults(quizID, userID string) (*models.Result, error) {
+func (m *MockStorage) GetResults(ctx context.Context, quizID, userID string) (*models.Result, error) {
 	args := m.Called(quizID, userID)
 	return args.Get(0).(*models.Result), args.Error(1)
 }
 
+func (m *MockStorage) ListQuizzes(ctx context.Context, filter storage.QuizFilter) ([]models.QuizSummary, int, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.QuizSummary), args.Int(1), args.Error(2)
+}
+
+func (m *MockStorage) UpdateQuiz(ctx context.Context, quiz *models.Quiz) error {
+	args := m.Called(quiz)
+	return args.Error(0)
+}
+
+func (m *MockStorage) DeleteQuiz(ctx context.Context, id string, archiveResults bool) error {
+	args := m.Called(id, archiveResults)
+	return args.Error(0)
+}
+
+func (m *MockStorage) StartAttempt(ctx context.Context, quizID, userID string) (*models.Result, error) {
+	args := m.Called(quizID, userID)
+	return args.Get(0).(*models.Result), args.Error(1)
+}
+
+func (m *MockStorage) FinishAttempt(ctx context.Context, quizID, userID string) (*models.Result, error) {
+	args := m.Called(quizID, userID)
+	return args.Get(0).(*models.Result), args.Error(1)
+}
+
+func (m *MockStorage) ListResults(ctx context.Context, quizID string) ([]models.Result, error) {
+	args := m.Called(quizID)
+	return args.Get(0).([]models.Result), args.Error(1)
+}
+
+func (m *MockStorage) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 func TestCreateQuiz(t *testing.T) {
 	t.Run("Successful quiz creation", func(t *testing.T) {
 		mockStorage := new(MockStorage)
@@ -86,7 +126,7 @@ func TestCreateQuiz(t *testing.T) {
 		controller.CreateQuiz(rr, req)
 
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
-		assert.Equal(t, "Invalid request body\n", rr.Body.String())
+		assert.JSONEq(t, `{"error": {"code": "bad_request", "message": "Invalid request body"}}`, rr.Body.String())
 	})
 
 	t.Run("Storage error", func(t *testing.T) {
@@ -103,7 +143,7 @@ func TestCreateQuiz(t *testing.T) {
 		controller.CreateQuiz(rr, req)
 
 		assert.Equal(t, http.StatusInternalServerError, rr.Code)
-		assert.Equal(t, "Failed to create quiz\n", rr.Body.String())
+		assert.JSONEq(t, `{"error": {"code": "internal_server_error", "message": "Failed to create quiz"}}`, rr.Body.String())
 		mockStorage.AssertExpectations(t)
 	})
 }
@@ -156,7 +196,7 @@ func TestGetQuiz(t *testing.T) {
 		mockStorage := new(MockStorage)
 		controller := controllers.NewQuizController(mockStorage)
 
-		mockStorage.On("GetQuiz", "2").Return(&models.Quiz{}, errors.New("quiz not found"))
+		mockStorage.On("GetQuiz", "2").Return(&models.Quiz{}, storage.ErrQuizNotFound)
 
 		req, _ := http.NewRequest("GET", "/quiz/2", nil)
 		rr := httptest.NewRecorder()
@@ -166,7 +206,7 @@ func TestGetQuiz(t *testing.T) {
 		router.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusNotFound, rr.Code)
-		assert.Equal(t, "Quiz not found\n", rr.Body.String())
+		assert.JSONEq(t, `{"error": {"code": "not_found", "message": "Quiz not found"}}`, rr.Body.String())
 		mockStorage.AssertExpectations(t)
 	})
 }
@@ -178,6 +218,7 @@ func TestSubmitAnswer(t *testing.T) {
 
 		answer := models.Answer{QuestionID: "q1", SelectedOption: 1}
 		mockStorage.On("SubmitAnswer", "1", "user1", &answer).Return(true, "", nil)
+		mockStorage.On("GetQuiz", "1").Return(&models.Quiz{ID: "1"}, nil)
 
 		body, _ := json.Marshal(answer)
 		req, _ := http.NewRequest("POST", "/quiz/1/answer/user1", bytes.NewBuffer(body))
@@ -201,6 +242,7 @@ func TestSubmitAnswer(t *testing.T) {
 
 		answer := models.Answer{QuestionID: "q1", SelectedOption: 0}
 		mockStorage.On("SubmitAnswer", "1", "user1", &answer).Return(false, "2", nil)
+		mockStorage.On("GetQuiz", "1").Return(&models.Quiz{ID: "1"}, nil)
 
 		body, _ := json.Marshal(answer)
 		req, _ := http.NewRequest("POST", "/quiz/1/answer/user1", bytes.NewBuffer(body))
@@ -230,7 +272,7 @@ func TestSubmitAnswer(t *testing.T) {
 		router.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
-		assert.Equal(t, "Invalid request body\n", rr.Body.String())
+		assert.JSONEq(t, `{"error": {"code": "bad_request", "message": "Invalid request body"}}`, rr.Body.String())
 	})
 
 	t.Run("Storage error", func(t *testing.T) {
@@ -249,9 +291,43 @@ func TestSubmitAnswer(t *testing.T) {
 		router.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusInternalServerError, rr.Code)
-		assert.Equal(t, "Failed to submit answer\n", rr.Body.String())
+		assert.JSONEq(t, `{"error": {"code": "internal_server_error", "message": "Failed to submit answer"}}`, rr.Body.String())
 		mockStorage.AssertExpectations(t)
 	})
+
+	t.Run("Typed storage errors", func(t *testing.T) {
+		tests := []struct {
+			err     error
+			status  int
+			message string
+		}{
+			{storage.ErrQuizNotFound, http.StatusNotFound, "Quiz not found"},
+			{storage.ErrQuestionNotFound, http.StatusNotFound, "Question not found"},
+			{storage.ErrAttemptClosed, http.StatusConflict, "Attempt is closed"},
+			{fmt.Errorf("save answer: %w", storage.ErrAlreadyAnswered), http.StatusConflict, "Question already answered"},
+		}
+		for _, tt := range tests {
+			mockStorage := new(MockStorage)
+			controller := controllers.NewQuizController(mockStorage)
+
+			answer := models.Answer{QuestionID: "q9", SelectedOption: 1}
+			mockStorage.On("SubmitAnswer", "1", "user1", &answer).Return(false, "", tt.err)
+
+			body, _ := json.Marshal(answer)
+			req, _ := http.NewRequest("POST", "/quiz/1/answer/user1", bytes.NewBuffer(body))
+			rr := httptest.NewRecorder()
+
+			router := mux.NewRouter()
+			router.HandleFunc("/quiz/{quizId}/answer/{userId}", controller.SubmitAnswer)
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code, tt.message)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			var envelope apierror.Envelope
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &envelope))
+			assert.Equal(t, tt.message, envelope.Error.Message)
+		}
+	})
 }
 
 func TestGetResults(t *testing.T) {
@@ -271,6 +347,7 @@ func TestGetResults(t *testing.T) {
 		}
 
 		mockStorage.On("GetResults", "1", "user1").Return(result, nil)
+		mockStorage.On("GetQuiz", "1").Return(&models.Quiz{ID: "1"}, nil)
 
 		req, _ := http.NewRequest("GET", "/quiz/1/results/user1", nil)
 		rr := httptest.NewRecorder()
@@ -294,7 +371,7 @@ func TestGetResults(t *testing.T) {
 		mockStorage := new(MockStorage)
 		controller := controllers.NewQuizController(mockStorage)
 
-		mockStorage.On("GetResults", "1", "user2").Return(&models.Result{}, errors.New("results not found"))
+		mockStorage.On("GetResults", "1", "user2").Return(&models.Result{}, storage.ErrResultsNotFound)
 
 		req, _ := http.NewRequest("GET", "/quiz/1/results/user2", nil)
 		rr := httptest.NewRecorder()
@@ -304,7 +381,140 @@ func TestGetResults(t *testing.T) {
 		router.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusNotFound, rr.Code)
-		assert.Equal(t, "Results not found\n", rr.Body.String())
+		assert.JSONEq(t, `{"error": {"code": "not_found", "message": "Results not found"}}`, rr.Body.String())
 		mockStorage.AssertExpectations(t)
 	})
 }
+
+func TestListQuizzes(t *testing.T) {
+	t.Run("Filtered page", func(t *testing.T) {
+		mockStorage := new(MockStorage)
+		controller := controllers.NewQuizController(mockStorage)
+
+		quizzes := []models.QuizSummary{{ID: "2", Title: "Maths", QuestionCount: 2, Version: 3}}
+		mockStorage.On("ListQuizzes", storage.QuizFilter{Title: "math", Offset: 1, Limit: 1}).Return(quizzes, 2, nil)
+
+		req, _ := http.NewRequest("GET", "/quiz?title=math&offset=1&limit=1", nil)
+		rr := httptest.NewRecorder()
+		controller.ListQuizzes(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response struct {
+			Quizzes []models.QuizSummary `json:"quizzes"`
+			Total   int                  `json:"total"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		assert.Equal(t, 2, response.Total)
+		assert.Equal(t, []models.QuizSummary{{ID: "2", Title: "Maths", QuestionCount: 2, Version: 3}}, response.Quizzes)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		controller := controllers.NewQuizController(new(MockStorage))
+
+		req, _ := http.NewRequest("GET", "/quiz?limit=0", nil)
+		rr := httptest.NewRecorder()
+		controller.ListQuizzes(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Timed out", func(t *testing.T) {
+		mockStorage := new(MockStorage)
+		controller := controllers.NewQuizController(mockStorage)
+		mockStorage.On("ListQuizzes", storage.QuizFilter{Limit: 20}).
+			Return([]models.QuizSummary(nil), 0, context.DeadlineExceeded)
+
+		req, _ := http.NewRequest("GET", "/quiz", nil)
+		rr := httptest.NewRecorder()
+		controller.ListQuizzes(rr, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	})
+}
+
+func TestUpdateQuiz(t *testing.T) {
+	t.Run("Version from If-Match", func(t *testing.T) {
+		mockStorage := new(MockStorage)
+		controller := controllers.NewQuizController(mockStorage)
+
+		mockStorage.On("UpdateQuiz", &models.Quiz{ID: "1", Title: "New", Version: 4}).
+			Run(func(args mock.Arguments) { args.Get(0).(*models.Quiz).Version = 5 }).
+			Return(nil)
+
+		req, _ := http.NewRequest("PUT", "/quiz/1", bytes.NewBufferString(`{"title": "New"}`))
+		req.Header.Set("If-Match", `"4"`)
+		rr := httptest.NewRecorder()
+
+		router := mux.NewRouter()
+		router.HandleFunc("/quiz/{id}", controller.UpdateQuiz)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"5"`, rr.Header().Get("ETag"))
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Stale version", func(t *testing.T) {
+		mockStorage := new(MockStorage)
+		controller := controllers.NewQuizController(mockStorage)
+
+		mockStorage.On("UpdateQuiz", &models.Quiz{ID: "1", Title: "New", Version: 1}).Return(storage.ErrVersionConflict)
+
+		req, _ := http.NewRequest("PUT", "/quiz/1", bytes.NewBufferString(`{"title": "New", "version": 1}`))
+		rr := httptest.NewRecorder()
+
+		router := mux.NewRouter()
+		router.HandleFunc("/quiz/{id}", controller.UpdateQuiz)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
+}
+
+func TestPatchQuiz(t *testing.T) {
+	mockStorage := new(MockStorage)
+	controller := controllers.NewQuizController(mockStorage)
+
+	existing := &models.Quiz{ID: "1", Title: "Old", IsNegativeMarking: true, Penalty: 0.5, Version: 2, Questions: []models.Question{
+		{ID: "q1", Text: "2 + 2?", Options: []string{"3", "4"}, CorrectOption: 1, Marks: 1},
+	}}
+	mockStorage.On("GetQuiz", "1").Return(existing, nil)
+	mockStorage.On("UpdateQuiz", mock.MatchedBy(func(q *models.Quiz) bool {
+		return q.Title == "New" && q.Penalty == 0.5 && q.Version == 2 && len(q.Questions) == 1
+	})).Return(nil)
+
+	req, _ := http.NewRequest("PATCH", "/quiz/1", bytes.NewBufferString(`{"title": "New"}`))
+	rr := httptest.NewRecorder()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/quiz/{id}", controller.PatchQuiz)
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestDeleteQuiz(t *testing.T) {
+	mockStorage := new(MockStorage)
+	controller := controllers.NewQuizController(mockStorage)
+
+	mockStorage.On("DeleteQuiz", "1", true).Return(nil)
+	mockStorage.On("DeleteQuiz", "2", false).Return(storage.ErrQuizNotFound)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/quiz/{id}", controller.DeleteQuiz)
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/quiz/1?archive=true", nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/quiz/2", nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	mockStorage.AssertExpectations(t)
+}
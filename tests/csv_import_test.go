@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"quiz-app/internal/models"
+	"quiz-app/internal/quizfmt"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kahootCSV mirrors the Kahoot spreadsheet template, including its
+// instructions above the header row
+const kahootCSV = `Quiz template,,,,,,
+Add questions below,,,,,,
+Question - max 120 characters,Answer 1 - max 75 characters,Answer 2 - max 75 characters,Answer 3 - max 75 characters,Answer 4 - max 75 characters,"Time limit (sec) – 5, 10, 20, 30, 60, 90, 120, or 240 secs",Correct answer(s) - choose at least one
+What is 2+2?,3,4,5,,20,2
+The sky is blue,True,False,,,10s,A
+Which are prime?,2,4,5,9,30,"1,3"
+,,,,,,
+Broken row,only one,,,,soon,5
+`
+
+func TestParseCSV_KahootTemplate(t *testing.T) {
+	quiz, rows, err := quizfmt.ParseCSV(strings.NewReader(kahootCSV), quizfmt.DefaultCSVMapping())
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+
+	assert.Equal(t, models.Question{ID: "q1", Kind: models.KindSingleChoice, Text: "What is 2+2?",
+		Options: []string{"3", "4", "5"}, CorrectOption: 1, Marks: 1, TimeLimitSeconds: 20}, quiz.Questions[0])
+	assert.Equal(t, []string{"True", "False"}, quiz.Questions[1].Options)
+	assert.Equal(t, 0, quiz.Questions[1].CorrectOption)
+	assert.Equal(t, 10, quiz.Questions[1].TimeLimitSeconds)
+	assert.Equal(t, models.KindMultipleSelect, quiz.Questions[2].Kind)
+	assert.Equal(t, []int{0, 2}, quiz.Questions[2].CorrectOptions)
+
+	assert.Equal(t, quizfmt.RowReport{Row: 4, QuestionID: "q1"}, rows[0])
+	assert.Equal(t, 8, rows[3].Row)
+	assert.Equal(t, []string{
+		"at least two options are required",
+		"correct answer 5 is not a filled-in option",
+		`time limit "soon" must be a number of seconds`,
+	}, rows[3].Errors)
+}
+
+func TestParseCSV_CustomMapping(t *testing.T) {
+	input := "Id,Prompt,A,B,C,Right,Points\nadd,1+1?,1,2,3,B,3\n"
+	mapping := quizfmt.CSVMapping{Text: "Prompt", Options: []string{"A", "B", "C"}, Correct: "Right", ID: "Id", Marks: "Points"}
+	quiz, rows, err := quizfmt.ParseCSV(strings.NewReader(input), mapping)
+	require.NoError(t, err)
+	assert.Empty(t, rows[0].Errors)
+	assert.Equal(t, models.Question{ID: "add", Kind: models.KindSingleChoice, Text: "1+1?",
+		Options: []string{"1", "2", "3"}, CorrectOption: 1, Marks: 3}, quiz.Questions[0])
+
+	// Columns may be given by number
+	mapping = quizfmt.CSVMapping{Text: "2", Options: []string{"3", "4"}, Correct: "6"}
+	quiz, _, err = quizfmt.ParseCSV(strings.NewReader(input), mapping)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, quiz.Questions[0].Options)
+
+	_, _, err = quizfmt.ParseCSV(strings.NewReader(input), quizfmt.CSVMapping{Text: "Prompt", Correct: "Answer"})
+	assert.EqualError(t, err, "missing column Answer, Answer 1")
+}
+
+func TestImportCSVEndpoint(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+	post := func(url, body string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var report map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &report)
+		return rr.Code, report
+	}
+
+	code, report := post("/quiz/import/csv?dry_run=true", kahootCSV)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, false, report["valid"])
+	assert.Len(t, report["rows"], 4)
+
+	code, report = post("/quiz/import/csv?id=kahoot", kahootCSV)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, false, report["valid"])
+	_, err := store.GetQuiz(context.Background(), "kahoot")
+	assert.ErrorIs(t, err, storage.ErrQuizNotFound)
+
+	valid := strings.Replace(kahootCSV, "Broken row,only one,,,,soon,5\n", "", 1)
+	code, report = post("/quiz/import/csv?id=kahoot&title=Kahoot&dry_run=true", valid)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, report["valid"])
+	_, err = store.GetQuiz(context.Background(), "kahoot")
+	assert.ErrorIs(t, err, storage.ErrQuizNotFound, "dry run creates nothing")
+
+	code, _ = post("/quiz/import/csv?id=kahoot&title=Kahoot", valid)
+	require.Equal(t, http.StatusCreated, code)
+	quiz, err := store.GetQuiz(context.Background(), "kahoot")
+	require.NoError(t, err)
+	assert.Equal(t, "Kahoot", quiz.Title)
+	assert.Len(t, quiz.Questions, 3)
+
+	code, _ = post("/quiz/import/csv?id=other&text=Nope", valid)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestSQLiteStorage_TimeLimit(t *testing.T) {
+	store, _ := newSQLiteStorage(t)
+	quiz := sampleQuiz()
+	quiz.Questions[0].TimeLimitSeconds = 30
+	require.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+	retrieved, err := store.GetQuiz(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, 30, retrieved.Questions[0].TimeLimitSeconds)
+	assert.Equal(t, 30, models.NewTakerQuiz(retrieved).Questions[0].TimeLimitSeconds)
+}
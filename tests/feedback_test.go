@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"quiz-app/internal/controllers"
+	"quiz-app/internal/models"
+	"quiz-app/internal/storage"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// feedbackHarness serves the answer and results endpoints over a memory
+// store whose clock is shared with the controller
+type feedbackHarness struct {
+	store  *storage.MemoryStorage
+	clock  *fakeClock
+	router *mux.Router
+}
+
+func newFeedbackHarness(t *testing.T, quiz *models.Quiz) *feedbackHarness {
+	h := &feedbackHarness{
+		store:  storage.NewMemoryStorage(),
+		clock:  &fakeClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)},
+		router: mux.NewRouter(),
+	}
+	h.store.SetClock(h.clock.Now)
+	require.NoError(t, h.store.CreateQuiz(context.Background(), quiz))
+
+	c := controllers.NewQuizController(h.store)
+	c.SetClock(h.clock.Now)
+	h.router.HandleFunc("/quiz/{quizId}/answer/{userId}", c.SubmitAnswer).Methods("POST")
+	h.router.HandleFunc("/quiz/{quizId}/results/{userId}", c.GetResults).Methods("GET")
+	return h
+}
+
+func (h *feedbackHarness) do(method, path, body string) map[string]interface{} {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	h.router.ServeHTTP(rr, req)
+	var response map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	return response
+}
+
+func TestFeedback_AfterAttempt(t *testing.T) {
+	quiz := sampleQuiz()
+	quiz.FeedbackMode = models.FeedbackAfterAttempt
+	h := newFeedbackHarness(t, quiz)
+
+	response := h.do("POST", "/quiz/1/answer/user1", `{"question_id": "q2", "selected_option": 0}`)
+	assert.Equal(t, "Answer recorded", response["message"])
+	assert.NotContains(t, response, "is_correct")
+	assert.NotContains(t, response, "correct_answer")
+
+	results := h.do("GET", "/quiz/1/results/user1", "")
+	assert.Equal(t, false, results["feedback_released"])
+	assert.NotContains(t, results, "score")
+	answer := results["answers"].(map[string]interface{})["q2"].(map[string]interface{})
+	assert.NotContains(t, answer, "is_correct")
+	assert.NotContains(t, answer, "correct_answer")
+
+	_, err := h.store.FinishAttempt(context.Background(), "1", "user1")
+	require.NoError(t, err)
+
+	results = h.do("GET", "/quiz/1/results/user1", "")
+	assert.Equal(t, true, results["feedback_released"])
+	assert.Equal(t, -0.5, results["score"])
+	answer = results["answers"].(map[string]interface{})["q2"].(map[string]interface{})
+	assert.Equal(t, false, answer["is_correct"])
+	assert.Equal(t, "4", answer["correct_answer"])
+}
+
+func TestFeedback_AfterClose(t *testing.T) {
+	quiz := sampleQuiz()
+	quiz.FeedbackMode = models.FeedbackAfterClose
+	closes := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	quiz.ClosesAt = &closes
+	h := newFeedbackHarness(t, quiz)
+
+	h.do("POST", "/quiz/1/answer/user1", `{"question_id": "q1", "selected_option": 1}`)
+	_, err := h.store.FinishAttempt(context.Background(), "1", "user1")
+	require.NoError(t, err)
+
+	results := h.do("GET", "/quiz/1/results/user1", "")
+	assert.Equal(t, false, results["feedback_released"])
+	assert.NotContains(t, results, "score")
+
+	h.clock.now = closes
+	results = h.do("GET", "/quiz/1/results/user1", "")
+	assert.Equal(t, true, results["feedback_released"])
+	assert.Equal(t, 2.0, results["score"])
+}
+
+func TestFeedback_Never(t *testing.T) {
+	quiz := sampleQuiz()
+	quiz.FeedbackMode = models.FeedbackNever
+	h := newFeedbackHarness(t, quiz)
+
+	response := h.do("POST", "/quiz/1/answer/user1", `{"question_id": "q2", "selected_option": 0}`)
+	assert.NotContains(t, response, "correct_answer")
+
+	results := h.do("GET", "/quiz/1/results/user1", "")
+	assert.NotContains(t, results, "score")
+
+	_, err := h.store.FinishAttempt(context.Background(), "1", "user1")
+	require.NoError(t, err)
+
+	// The final score is shown, but never which answers were right
+	results = h.do("GET", "/quiz/1/results/user1", "")
+	assert.Equal(t, false, results["feedback_released"])
+	assert.Equal(t, -0.5, results["score"])
+	answer := results["answers"].(map[string]interface{})["q2"].(map[string]interface{})
+	assert.NotContains(t, answer, "is_correct")
+	assert.NotContains(t, answer, "correct_answer")
+}
+
+func TestFeedback_ImmediateRevealsCorrectAnswerInResults(t *testing.T) {
+	h := newFeedbackHarness(t, sampleQuiz())
+
+	response := h.do("POST", "/quiz/1/answer/user1", `{"question_id": "q2", "selected_option": 0}`)
+	assert.Equal(t, false, response["is_correct"])
+	assert.Equal(t, "4", response["correct_answer"])
+
+	results := h.do("GET", "/quiz/1/results/user1", "")
+	answer := results["answers"].(map[string]interface{})["q2"].(map[string]interface{})
+	assert.Equal(t, "4", answer["correct_answer"])
+}
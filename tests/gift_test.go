@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"quiz-app/internal/models"
+	"quiz-app/internal/quizfmt"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const giftSample = `// Imported from Moodle
+$CATEGORY: Geography
+
+::capital:: What is the capital of France? {
+	=Paris#Correct!
+	~London
+	~Berlin
+}
+
+::flat:: The earth is flat. {F}
+
+Who wrote "Hamlet"? {=Shakespeare =%50%Shakspere}
+
+::pi:: What is pi to two places? {#3.14:0.005}
+
+::range:: Pick a number between 1 and 5 {#1..5}
+
+::primes:: Which are prime? {~%50%2 ~%50%3 ~%-100%4}
+
+::escaped:: What is 1 \= 1 \{really\}? {=yes ~no}
+`
+
+func TestParseGIFT(t *testing.T) {
+	quiz, err := quizfmt.ParseGIFT(strings.NewReader(giftSample))
+	require.NoError(t, err)
+	assert.Equal(t, "Geography", quiz.Title)
+	require.Len(t, quiz.Questions, 7)
+
+	q := quiz.Questions
+	assert.Equal(t, models.Question{
+		ID: "capital", Kind: models.KindSingleChoice, Text: "What is the capital of France?",
+		Options: []string{"Paris", "London", "Berlin"}, CorrectOption: 0, Marks: 1,
+	}, q[0])
+
+	assert.Equal(t, []string{"True", "False"}, q[1].Options)
+	assert.Equal(t, 1, q[1].CorrectOption)
+
+	assert.Equal(t, "q3", q[2].ID)
+	assert.Equal(t, models.KindShortText, q[2].Kind)
+	assert.Equal(t, []models.TextRule{
+		{Match: models.MatchCaseInsensitive, Pattern: "Shakespeare"},
+		{Match: models.MatchCaseInsensitive, Pattern: "Shakspere", Score: 0.5},
+	}, q[2].TextRules)
+
+	assert.Equal(t, &models.NumericKey{Value: 3.14, Tolerance: 0.005}, q[3].Numeric)
+	assert.Equal(t, &models.NumericKey{Value: 3, Tolerance: 2}, q[4].Numeric)
+
+	assert.Equal(t, models.KindMultipleSelect, q[5].Kind)
+	assert.Equal(t, []int{0, 1}, q[5].CorrectOptions)
+	assert.Equal(t, models.ScoringProportional, q[5].Scoring)
+
+	assert.Equal(t, "What is 1 = 1 {really}?", q[6].Text)
+}
+
+func TestParseGIFT_ReportsLines(t *testing.T) {
+	input := `::ok:: Fine {=a ~b}
+
+::no-answers:: Missing block
+
+::bad::
+Text {#abc}
+
+::none:: Nobody is right {~a ~b}
+`
+	_, err := quizfmt.ParseGIFT(strings.NewReader(input))
+	var problems quizfmt.ErrorList
+	require.True(t, errors.As(err, &problems))
+	require.Len(t, problems, 3)
+	assert.Equal(t, 3, problems[0].Line)
+	assert.Equal(t, "missing answer block", problems[0].Msg)
+	assert.Equal(t, 6, problems[1].Line)
+	assert.Contains(t, problems[1].Msg, "invalid numeric answer")
+	assert.Equal(t, 8, problems[2].Line)
+	assert.EqualError(t, problems[2], "line 8: multiple choice question has no correct answer")
+}
+
+func TestGIFT_RoundTrip(t *testing.T) {
+	quiz, err := quizfmt.ParseGIFT(strings.NewReader(giftSample))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, quizfmt.WriteGIFT(&out, quiz))
+	again, err := quizfmt.ParseGIFT(&out)
+	require.NoError(t, err, out.String())
+
+	// Unnamed questions get their generated IDs as titles
+	assert.Equal(t, quiz, again)
+}
+
+func TestWriteGIFT_Unsupported(t *testing.T) {
+	quiz := &models.Quiz{Questions: []models.Question{{
+		ID: "t", Kind: models.KindShortText, Text: "Colour?",
+		TextRules: []models.TextRule{{Match: models.MatchRegex, Pattern: "blue|azure"}},
+	}}}
+	err := quizfmt.WriteGIFT(&bytes.Buffer{}, quiz)
+	assert.EqualError(t, err, "question t: regex text rules cannot be written as GIFT")
+}
+
+func TestImportExportGIFTEndpoints(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+
+	req, _ := http.NewRequest("POST", "/quiz/import?format=gift&id=geo", strings.NewReader(giftSample))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	quiz, err := store.GetQuiz(context.Background(), "geo")
+	require.NoError(t, err)
+	assert.Equal(t, "Geography", quiz.Title)
+	assert.Len(t, quiz.Questions, 7)
+
+	req, _ = http.NewRequest("GET", "/quiz/geo/export?format=gift", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `attachment; filename=geo.gift`, rr.Header().Get("Content-Disposition"))
+	assert.Contains(t, rr.Body.String(), "::capital:: What is the capital of France? {")
+
+	// IDs that need quoting cannot break out of the header
+	odd := sampleQuiz()
+	odd.ID = `say "hi"; x=1`
+	require.NoError(t, store.CreateQuiz(context.Background(), odd))
+	req, _ = http.NewRequest("GET", "/quiz/"+url.PathEscape(odd.ID)+"/export?format=gift", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	disposition, params, err := mime.ParseMediaType(rr.Header().Get("Content-Disposition"))
+	require.NoError(t, err)
+	assert.Equal(t, "attachment", disposition)
+	assert.Equal(t, map[string]string{"filename": odd.ID + ".gift"}, params)
+
+	req, _ = http.NewRequest("POST", "/quiz/import?format=gift&id=bad", strings.NewReader("No answers here"))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.JSONEq(t, `{"error": {"code": "unprocessable_entity", "message": "Invalid document", "details": [{"line": 1, "message": "missing answer block"}]}}`, rr.Body.String())
+
+	for _, url := range []string{"/quiz/import?format=doc&id=x", "/quiz/import?format=gift"} {
+		req, _ = http.NewRequest("POST", url, strings.NewReader(giftSample))
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, url)
+	}
+
+	req, _ = http.NewRequest("POST", "/quiz/import?format=gift&id=geo", strings.NewReader(giftSample))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
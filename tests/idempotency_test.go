@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"quiz-app/internal/middleware"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentAnswerSubmission(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+
+	submit := func(key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/quiz/1/answer/user1", bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := submit("abc", `{"question_id": "q1", "selected_option": 1}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	retry := submit("abc", `{"question_id": "q1", "selected_option": 1}`)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+
+	reused := submit("abc", `{"question_id": "q1", "selected_option": 2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+
+	result, err := store.GetResults(context.Background(), "1", "user1")
+	require.NoError(t, err)
+	assert.Equal(t, float32(2), result.Score)
+	assert.True(t, result.Answers["q1"].IsCorrect)
+}
+
+func TestIdempotencyCache_RetriesAfterServerError(t *testing.T) {
+	var calls int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int32{"call": calls})
+	})
+	wrapped := middleware.NewIdempotencyCache(time.Hour, 0).Middleware(handler)
+
+	for i, want := range []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK} {
+		req, _ := http.NewRequest("POST", "/x", bytes.NewBufferString("{}"))
+		req.Header.Set(middleware.IdempotencyHeader, "k")
+		rr := httptest.NewRecorder()
+		wrapped.ServeHTTP(rr, req)
+		assert.Equal(t, want, rr.Code, "request %d", i+1)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotencyCache_RefusesNewKeysWhenFull(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	wrapped := middleware.NewIdempotencyCache(time.Hour, 1).Middleware(handler)
+	send := func(key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/x", bytes.NewBufferString("{}"))
+		req.Header.Set(middleware.IdempotencyHeader, key)
+		rr := httptest.NewRecorder()
+		wrapped.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusCreated, send("a").Code)
+	full := send("b")
+	assert.Equal(t, http.StatusServiceUnavailable, full.Code)
+	assert.Equal(t, "60", full.Header().Get("Retry-After"))
+	retry := send("a")
+	assert.Equal(t, http.StatusCreated, retry.Code, "keys already kept are still replayed")
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+}
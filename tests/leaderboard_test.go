@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"quiz-app/internal/leaderboard"
+	"quiz-app/internal/models"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderboard_Rank(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) *time.Time {
+		t := now.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+	results := []models.Result{
+		{UserID: "slow", Score: 5, FinishedAt: at(-10)},
+		{UserID: "fast", Score: 5, FinishedAt: at(-20)},
+		{UserID: "top", Score: 8, FinishedAt: at(-5)},
+		{UserID: "open", Score: 5},
+		{UserID: "expired", Score: 5, Deadline: at(-15)},
+		{UserID: "low", Score: 1, FinishedAt: at(-30)},
+		{UserID: "also-open", Score: 5},
+	}
+
+	entries := leaderboard.Rank(results, now)
+	var order []string
+	var ranks []int
+	for _, e := range entries {
+		order = append(order, e.UserID)
+		ranks = append(ranks, e.Rank)
+	}
+	assert.Equal(t, []string{"top", "fast", "expired", "slow", "also-open", "open", "low"}, order)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 5, 7}, ranks)
+
+	assert.InDelta(t, 13.0/14*100, entries[0].Percentile, 1e-9)
+	assert.InDelta(t, 7.0/14*100, entries[1].Percentile, 1e-9)
+	assert.InDelta(t, 1.0/14*100, entries[6].Percentile, 1e-9)
+	assert.Equal(t, at(-15), entries[2].CompletedAt)
+
+	me := leaderboard.Find(entries, "slow")
+	require.NotNil(t, me)
+	assert.Equal(t, 4, me.Rank)
+	assert.Nil(t, leaderboard.Find(entries, "nobody"))
+}
+
+func TestLeaderboard_Paginate(t *testing.T) {
+	var results []models.Result
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		results = append(results, models.Result{UserID: id, Score: float32(len(results))})
+	}
+	entries := leaderboard.Rank(results, time.Now())
+
+	var seen []string
+	cursor := ""
+	for {
+		page, err := leaderboard.Paginate(entries, cursor, 2)
+		require.NoError(t, err)
+		for _, e := range page.Entries {
+			seen = append(seen, e.UserID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"e", "d", "c", "b", "a"}, seen)
+
+	// A cursor still points at the same place after a new result lands
+	first, _ := leaderboard.Paginate(entries, "", 2)
+	results = append(results, models.Result{UserID: "f", Score: 10})
+	page, err := leaderboard.Paginate(leaderboard.Rank(results, time.Now()), first.NextCursor, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "c", page.Entries[0].UserID)
+
+	_, err = leaderboard.Paginate(entries, "not a cursor", 2)
+	assert.ErrorIs(t, err, leaderboard.ErrInvalidCursor)
+}
+
+func TestLeaderboardEndpoint(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+	store.SetClock(clock.Now)
+	require.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+
+	for _, user := range []string{"alice", "bob", "carol"} {
+		_, err := store.StartAttempt(context.Background(), "1", user)
+		require.NoError(t, err)
+	}
+	for _, user := range []string{"carol", "alice", "bob"} {
+		_, _, err := store.SubmitAnswer(context.Background(), "1", user, &models.Answer{QuestionID: "q1", SelectedOption: 1})
+		require.NoError(t, err)
+		clock.Advance(time.Minute)
+		_, err = store.FinishAttempt(context.Background(), "1", user)
+		require.NoError(t, err)
+	}
+	_, _, err := store.SubmitAnswer(context.Background(), "1", "dave", &models.Answer{QuestionID: "q1", SelectedOption: 0})
+	require.NoError(t, err)
+
+	get := func(url string) (int, map[string]any) {
+		req, _ := http.NewRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var body map[string]any
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body
+	}
+
+	code, body := get("/quiz/1/leaderboard?limit=2&user_id=dave")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(4), body["total"])
+	entries := body["entries"].([]any)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "carol", entries[0].(map[string]any)["user_id"])
+	assert.Equal(t, "alice", entries[1].(map[string]any)["user_id"])
+	assert.Equal(t, float64(4), body["me"].(map[string]any)["rank"])
+
+	code, body = get("/quiz/1/leaderboard?limit=2&cursor=" + body["next_cursor"].(string))
+	require.Equal(t, http.StatusOK, code)
+	entries = body["entries"].([]any)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "bob", entries[0].(map[string]any)["user_id"])
+	assert.Nil(t, body["next_cursor"])
+
+	code, _ = get("/quiz/1/leaderboard?user_id=nobody")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get("/quiz/1/leaderboard?cursor=bogus")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("/quiz/missing/leaderboard")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestStorage_ListResults(t *testing.T) {
+	for name, newStore := range newClockedBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			require.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+
+			results, err := store.ListResults(context.Background(), "1")
+			require.NoError(t, err)
+			assert.Empty(t, results)
+
+			for _, user := range []string{"bob", "alice"} {
+				_, _, err := store.SubmitAnswer(context.Background(), "1", user, &models.Answer{QuestionID: "q1", SelectedOption: 1})
+				require.NoError(t, err)
+			}
+			results, err = store.ListResults(context.Background(), "1")
+			require.NoError(t, err)
+			require.Len(t, results, 2)
+			assert.Equal(t, "alice", results[0].UserID)
+			assert.Equal(t, float32(2), results[1].Score)
+
+			_, err = store.ListResults(context.Background(), "missing")
+			assert.ErrorIs(t, err, storage.ErrQuizNotFound)
+		})
+	}
+}
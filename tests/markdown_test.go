@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"quiz-app/internal/models"
+	"quiz-app/internal/quizfmt"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const markdownSample = `---
+id: capitals
+title: Capitals
+is_negative_marking: true
+penalty: 0.5
+---
+
+# Capitals of Europe
+
+Some notes for the author.
+
+## What is the capital of France? {#france marks=2}
+
+- [x] Paris
+- [ ] Lyon
+
+## Which of these are capitals?
+
+Pick all that apply.
+
+- [x] Rome
+- [ ] Milan
+* [X] Madrid
+
+## Distance from Paris to Rome in km {#distance kind=numeric}
+
+= 1105 ± 5%
+
+## Capital of Italy? {kind=short_text}
+
+= Rome
+= %50%Roma
+`
+
+func TestParseMarkdown(t *testing.T) {
+	quiz, err := quizfmt.ParseMarkdown(strings.NewReader(markdownSample))
+	require.NoError(t, err)
+
+	assert.Equal(t, &models.Quiz{
+		ID:                "capitals",
+		Title:             "Capitals",
+		IsNegativeMarking: true,
+		Penalty:           0.5,
+		Questions: []models.Question{
+			{ID: "france", Kind: models.KindSingleChoice, Text: "What is the capital of France?",
+				Options: []string{"Paris", "Lyon"}, CorrectOption: 0, Marks: 2},
+			{ID: "q2", Kind: models.KindMultipleSelect, Text: "Which of these are capitals?\nPick all that apply.",
+				Options: []string{"Rome", "Milan", "Madrid"}, CorrectOptions: []int{0, 2}, Marks: 1},
+			{ID: "distance", Kind: models.KindNumeric, Text: "Distance from Paris to Rome in km",
+				Numeric: &models.NumericKey{Value: 1105, Tolerance: 0.05, Relative: true}, Marks: 1},
+			{ID: "q4", Kind: models.KindShortText, Text: "Capital of Italy?", Marks: 1, TextRules: []models.TextRule{
+				{Match: models.MatchCaseInsensitive, Pattern: "Rome"},
+				{Match: models.MatchCaseInsensitive, Pattern: "Roma", Score: 0.5},
+			}},
+		},
+	}, quiz)
+}
+
+func TestParseMarkdown_ReportsLines(t *testing.T) {
+	input := `---
+title: Broken
+penalty: lots
+colour: blue
+---
+
+## Nothing ticked
+
+- [ ] a
+- [ ] b
+
+## Duplicate {#dup}
+
+- [x] a
+- [ ] b
+
+## Again {#dup marks=0}
+
+- [x] a
+- [ ] b
+stray text
+
+## Number {kind=numeric}
+
+= twelve
+`
+	_, err := quizfmt.ParseMarkdown(strings.NewReader(input))
+	var problems quizfmt.ErrorList
+	require.True(t, errors.As(err, &problems), err)
+
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.Error())
+	}
+	assert.Equal(t, []string{
+		"line 3: invalid penalty: lots",
+		"line 4: unknown setting \"colour\"",
+		"line 7: no option is ticked as correct",
+		"line 17: marks must be a positive whole number",
+		"line 17: question ID \"dup\" is already used on line 12",
+		"line 21: unexpected text after the answers",
+		"line 25: invalid number \"twelve\"",
+	}, got)
+}
+
+func TestMarkdown_RoundTrip(t *testing.T) {
+	quiz, err := quizfmt.ParseMarkdown(strings.NewReader(markdownSample))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, quizfmt.WriteMarkdown(&out, quiz))
+	again, err := quizfmt.ParseMarkdown(&out)
+	require.NoError(t, err, out.String())
+	assert.Equal(t, quiz, again)
+}
+
+func TestImportMarkdownEndpoint(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+
+	post := func(url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("/quiz/import?format=markdown&validate=true", markdownSample)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"valid": true, "errors": [], "question_count": 4}`, rr.Body.String())
+	_, err := store.GetQuiz(context.Background(), "capitals")
+	assert.ErrorIs(t, err, storage.ErrQuizNotFound, "validation creates nothing")
+
+	rr = post("/quiz/import?format=markdown&validate=true", "## Lonely\n\n- [x] only\n")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"valid": false, "errors": [{"line": 1, "message": "question needs at least two options"}]}`, rr.Body.String())
+
+	rr = post("/quiz/import?format=markdown", markdownSample)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	quiz, err := store.GetQuiz(context.Background(), "capitals")
+	require.NoError(t, err)
+	assert.Len(t, quiz.Questions, 4)
+
+	req, _ := http.NewRequest("GET", "/quiz/capitals/export?format=markdown", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, strings.HasPrefix(rr.Body.String(), "---\nid: capitals\ntitle: Capitals\n"))
+	assert.Contains(t, rr.Body.String(), "## What is the capital of France? {#france marks=2}\n\n- [x] Paris\n- [ ] Lyon\n")
+}
@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"quiz-app/internal/auth"
+	"quiz-app/internal/config"
+	"quiz-app/internal/oidc"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/server"
+	"quiz-app/internal/storage"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type oidcHarness struct {
+	idp   *fakeIdP
+	app   *httptest.Server
+	store *storage.MemoryStorage
+}
+
+func newOIDCHarness(t *testing.T) *oidcHarness {
+	t.Helper()
+	idp := newFakeIdP(t)
+	h := &oidcHarness{idp: idp, store: storage.NewMemoryStorage()}
+	require.NoError(t, h.store.CreateQuiz(context.Background(), sampleQuiz()))
+
+	// The app's URL is only known once it is listening, so the router is
+	// plugged in afterwards
+	var router http.Handler
+	h.app = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(h.app.Close)
+
+	cfg := config.Default()
+	cfg.OIDCIssuer = idp.URL
+	cfg.OIDCClientID = idp.clientID
+	cfg.OIDCClientSecret = idp.clientSecret
+	cfg.OIDCRedirectURL = h.app.URL + "/auth/callback"
+	authn, err := server.NewAuthenticator(context.Background(), cfg, nil)
+	require.NoError(t, err)
+	router = routes.SetupRoutes(h.store, authn, nil, nil, nil)
+	return h
+}
+
+// browser returns a client that keeps cookies and follows redirects, as a
+// browser would
+func (h *oidcHarness) browser(t *testing.T) *http.Client {
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	return &http.Client{Jar: jar}
+}
+
+func (h *oidcHarness) login(t *testing.T, client *http.Client) map[string]string {
+	t.Helper()
+	resp, err := client.Get(h.app.URL + "/auth/login")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var session map[string]string
+	require.NoError(t, json.Unmarshal(body, &session))
+	return session
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	h := newOIDCHarness(t)
+	h.idp.signIn(jwt.MapClaims{"sub": "alice", "email": "alice@example.com"})
+	client := h.browser(t)
+
+	session := h.login(t, client)
+	assert.Equal(t, "alice", session["user_id"])
+	assert.Equal(t, "taker", session["role"])
+
+	// The session cookie identifies the taker, so no user is named
+	resp, err := client.Post(h.app.URL+"/quiz/1/answer", "application/json",
+		strings.NewReader(`{"question_id": "q1", "selected_option": 1}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	result, err := h.store.GetResults(context.Background(), "1", "alice")
+	require.NoError(t, err)
+	assert.Contains(t, result.Answers, "q1")
+
+	resp, err = client.Get(h.app.URL + "/quiz/1/results")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = client.Get(h.app.URL + "/quiz/1/results/bob")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// The ID token also works as a bearer token, without the cookie
+	req, _ := http.NewRequest("GET", h.app.URL+"/quiz/1/results", nil)
+	req.Header.Set("Authorization", "Bearer "+session["id_token"])
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = client.Post(h.app.URL+"/auth/logout", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	resp, err = client.Get(h.app.URL + "/quiz/1/results")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestOIDCRoleClaim(t *testing.T) {
+	h := newOIDCHarness(t)
+	h.idp.signIn(jwt.MapClaims{"sub": "ada", "role": []string{"staff", "author"}})
+	client := h.browser(t)
+
+	session := h.login(t, client)
+	assert.Equal(t, "author", session["role"])
+
+	resp, err := client.Get(h.app.URL + "/quiz/1/analytics/stats")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestOIDCCallbackNeedsMatchingState(t *testing.T) {
+	h := newOIDCHarness(t)
+
+	// A callback this browser did not start, as in a login CSRF attempt
+	resp, err := h.browser(t).Get(h.app.URL + "/auth/callback?code=stolen&state=forged")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Stopping at the provider's redirect and replaying it from another
+	// browser fails too, since the state cookie is missing
+	client := h.browser(t)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if strings.HasPrefix(req.URL.String(), h.app.URL+"/auth/callback") {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+	resp, err = client.Get(h.app.URL + "/auth/login")
+	require.NoError(t, err)
+	resp.Body.Close()
+	callback := resp.Header.Get("Location")
+	require.Contains(t, callback, "code=")
+
+	resp, err = h.browser(t).Get(callback)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestOIDCTokenVerification(t *testing.T) {
+	h := newOIDCHarness(t)
+	get := func(token string) int {
+		req, _ := http.NewRequest("GET", h.app.URL+"/quiz/1/results", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusNotFound, get(h.idp.idToken(jwt.MapClaims{"sub": "carol"})), "valid token, no results yet")
+	assert.Equal(t, http.StatusUnauthorized, get(h.idp.idToken(jwt.MapClaims{"sub": "carol", "aud": "another-app"})))
+	assert.Equal(t, http.StatusUnauthorized, get(h.idp.idToken(jwt.MapClaims{"sub": "carol", "exp": 1})))
+	assert.Equal(t, http.StatusUnauthorized, get(h.idp.idToken(jwt.MapClaims{
+		"sub": "carol", "aud": []string{"quiz-app", "another-app"}, "azp": "another-app",
+	})))
+
+	tampered := h.idp.idToken(jwt.MapClaims{"sub": "carol"})
+	parts := strings.Split(tampered, ".")
+	claims, _ := json.Marshal(map[string]interface{}{"iss": h.idp.URL, "aud": "quiz-app", "sub": "admin", "exp": 1 << 40})
+	parts[1] = base64.RawURLEncoding.EncodeToString(claims)
+	assert.Equal(t, http.StatusUnauthorized, get(strings.Join(parts, ".")))
+
+	// After the provider rotates its key, tokens with the new key ID are
+	// verified by fetching the keys again
+	h.idp.rotateKey()
+	assert.Equal(t, http.StatusNotFound, get(h.idp.idToken(jwt.MapClaims{"sub": "carol"})))
+}
+
+func TestOIDCDiscoveryChecksIssuer(t *testing.T) {
+	idp := newFakeIdP(t)
+	_, err := oidc.Discover(context.Background(), idp.URL+"/", nil)
+	assert.ErrorContains(t, err, "issuer")
+}
+
+func TestOIDCCapsPendingLogins(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.signIn(jwt.MapClaims{"sub": "alice"})
+	provider, err := oidc.Discover(context.Background(), idp.URL, nil)
+	require.NoError(t, err)
+	authn, err := auth.New(auth.Options{OIDC: oidc.New(provider, oidc.Config{
+		ClientID:         idp.clientID,
+		ClientSecret:     idp.clientSecret,
+		RedirectURL:      "http://quiz.example/auth/callback",
+		MaxPendingLogins: 2,
+	})})
+	require.NoError(t, err)
+	login := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		authn.Login(rr, httptest.NewRequest("GET", "/auth/login", nil))
+		return rr
+	}
+
+	first := login()
+	require.Equal(t, http.StatusFound, first.Code)
+	require.Equal(t, http.StatusFound, login().Code)
+	rr := login()
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "logins nobody completes cannot pile up")
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	// Completing a login makes room for another
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirects.Get(first.Header().Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	callback := httptest.NewRequest("GET", resp.Header.Get("Location"), nil)
+	for _, cookie := range first.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	rr = httptest.NewRecorder()
+	authn.Callback(rr, callback)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, http.StatusFound, login().Code)
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"quiz-app/internal/models"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pooledQuiz() *models.Quiz {
+	quiz := &models.Quiz{
+		ID:               "pooled",
+		Title:            "Pooled Quiz",
+		Draws:            []models.PoolDraw{{Pool: "easy", Count: 2}, {Pool: "hard", Count: 1}},
+		ShuffleQuestions: true,
+		ShuffleOptions:   true,
+		Seed:             7,
+		Questions: []models.Question{
+			{ID: "intro", Text: "Always asked", Options: []string{"a", "b", "c"}, CorrectOption: 2, Marks: 1},
+		},
+	}
+	for i := 0; i < 4; i++ {
+		quiz.Questions = append(quiz.Questions, models.Question{
+			ID: fmt.Sprintf("e%d", i), Text: fmt.Sprintf("Easy %d", i), Pool: "easy", Options: []string{"w", "x", "y", "z"}, CorrectOption: 1, Marks: 1,
+		})
+	}
+	for i := 0; i < 3; i++ {
+		quiz.Questions = append(quiz.Questions, models.Question{
+			ID: fmt.Sprintf("h%d", i), Text: fmt.Sprintf("Hard %d", i), Pool: "hard", Kind: models.KindMultipleSelect,
+			Options: []string{"w", "x", "y", "z"}, CorrectOptions: []int{0, 3}, Marks: 2,
+		})
+	}
+	return quiz
+}
+
+func paperIDs(paper *models.Paper) []string {
+	var ids []string
+	for _, q := range paper.Quiz.Questions {
+		ids = append(ids, q.ID)
+	}
+	return ids
+}
+
+func TestQuiz_PaperFor(t *testing.T) {
+	quiz := pooledQuiz()
+
+	paper := quiz.PaperFor("alice")
+	assert.Equal(t, paper, quiz.PaperFor("alice"), "papers are reproducible")
+	assert.Len(t, paper.Quiz.Questions, 4)
+	assert.NotNil(t, paper.Question("intro"))
+
+	pools := map[string]int{}
+	for _, q := range paper.Quiz.Questions {
+		pools[q.Pool]++
+		// Answer keys follow the options to their new positions
+		canonical := quiz.Questions[0]
+		for _, c := range quiz.Questions {
+			if c.ID == q.ID {
+				canonical = c
+			}
+		}
+		assert.ElementsMatch(t, canonical.Options, q.Options)
+		if q.Kind == models.KindMultipleSelect {
+			assert.Equal(t, "w", q.Options[q.CorrectOptions[0]])
+			assert.Equal(t, "z", q.Options[q.CorrectOptions[1]])
+		} else {
+			assert.Equal(t, canonical.Options[canonical.CorrectOption], q.Options[q.CorrectOption])
+		}
+	}
+	assert.Equal(t, map[string]int{"": 1, "easy": 2, "hard": 1}, pools)
+
+	// Across many users, papers differ
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		seen[fmt.Sprint(paperIDs(quiz.PaperFor(fmt.Sprintf("user%d", i))))] = true
+	}
+	assert.Greater(t, len(seen), 1)
+
+	// The stored quiz is untouched
+	assert.Equal(t, pooledQuiz(), quiz)
+}
+
+func TestQuiz_PaperForFixedQuiz(t *testing.T) {
+	quiz := sampleQuiz()
+	assert.False(t, quiz.IsRandomized())
+	assert.Equal(t, quiz, quiz.PaperFor("alice").Quiz)
+}
+
+func TestStorage_GradesShuffledOptions(t *testing.T) {
+	for name, newStore := range map[string]func(t *testing.T) storage.Storage{
+		"memory": func(t *testing.T) storage.Storage { return storage.NewMemoryStorage() },
+		"sqlite": func(t *testing.T) storage.Storage { s, _ := newSQLiteStorage(t); return s },
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			quiz := pooledQuiz()
+			require.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+			stored, err := store.GetQuiz(context.Background(), "pooled")
+			require.NoError(t, err)
+			assert.Equal(t, quiz, stored)
+
+			paper := quiz.PaperFor("alice")
+			for _, q := range paper.Quiz.Questions {
+				answer := &models.Answer{QuestionID: q.ID, SelectedOption: q.CorrectOption, SelectedOptions: q.CorrectOptions}
+				isCorrect, _, err := store.SubmitAnswer(context.Background(), "pooled", "alice", answer)
+				require.NoError(t, err)
+				assert.True(t, isCorrect, q.ID)
+			}
+
+			result, err := store.GetResults(context.Background(), "pooled", "alice")
+			require.NoError(t, err)
+			assert.Equal(t, float32(5), result.Score)
+			// Answers are stored with canonical option indexes
+			assert.Equal(t, 2, result.Answers["intro"].SelectedOption)
+
+			for _, q := range quiz.Questions {
+				if paper.Question(q.ID) == nil {
+					_, _, err := store.SubmitAnswer(context.Background(), "pooled", "alice", &models.Answer{QuestionID: q.ID})
+					assert.EqualError(t, err, "question not found")
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestGetQuiz_RandomizedNeedsUser(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.CreateQuiz(context.Background(), pooledQuiz()))
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+
+	req, _ := http.NewRequest("GET", "/quiz/pooled", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req, _ = http.NewRequest("GET", "/quiz/pooled?user_id=alice", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var view models.TakerQuiz
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &view))
+	paper := pooledQuiz().PaperFor("alice")
+	require.Len(t, view.Questions, len(paper.Quiz.Questions))
+	for i, q := range paper.Quiz.Questions {
+		assert.Equal(t, q.ID, view.Questions[i].ID)
+		assert.Equal(t, q.Options, view.Questions[i].Options)
+	}
+}
+
+func TestGetResults_ShuffledOptionsInPaperOrder(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.CreateQuiz(context.Background(), pooledQuiz()))
+	h := &authHarness{store: store, router: routes.SetupRoutes(store, nil, nil, nil, nil)}
+
+	paper := pooledQuiz().PaperFor("alice")
+	submitted := map[string]models.AnswerView{}
+	for _, q := range paper.Quiz.Questions {
+		answer := models.AnswerView{QuestionID: q.ID, SelectedOption: q.CorrectOption, SelectedOptions: q.CorrectOptions}
+		body, _ := json.Marshal(answer)
+		require.Equal(t, http.StatusOK, h.do("POST", "/quiz/pooled/answer/alice", nil, string(body)).Code, q.ID)
+		submitted[q.ID] = answer
+	}
+	stored, err := store.GetResults(context.Background(), "pooled", "alice")
+	require.NoError(t, err)
+	require.NotEqual(t, submitted["intro"].SelectedOption, stored.Answers["intro"].SelectedOption,
+		"the seed must move the intro's correct option")
+
+	rr := h.do("GET", "/quiz/pooled/results/alice", nil, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var view models.ResultView
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &view))
+	for id, answer := range submitted {
+		assert.Equal(t, answer.SelectedOption, view.Answers[id].SelectedOption, id)
+		assert.Equal(t, answer.SelectedOptions, view.Answers[id].SelectedOptions, id)
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"quiz-app/internal/models"
+	"quiz-app/internal/quizfmt"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func qtiQuiz() *models.Quiz {
+	return &models.Quiz{
+		ID:    "qti",
+		Title: "Mixed <Quiz> & more",
+		Questions: []models.Question{
+			{ID: "capital", Kind: models.KindSingleChoice, Text: "Capital of France?",
+				Options: []string{"Paris", "Lyon & Co"}, CorrectOption: 0, Marks: 2},
+			{ID: "primes", Kind: models.KindMultipleSelect, Text: "Which are prime?",
+				Options: []string{"2", "3", "4"}, CorrectOptions: []int{0, 1}, Scoring: models.ScoringRightMinusWrong, Marks: 2},
+			{ID: "evens", Kind: models.KindMultipleSelect, Text: "Which are even?",
+				Options: []string{"2", "3", "4"}, CorrectOptions: []int{0, 2}, Scoring: models.ScoringAllOrNothing, Marks: 1},
+			{ID: "pi", Kind: models.KindNumeric, Text: "Pi to two places?",
+				Numeric: &models.NumericKey{Value: 3.14, Tolerance: 0.01}, Marks: 1},
+			{ID: "g", Kind: models.KindNumeric, Text: "Gravity?",
+				Numeric: &models.NumericKey{Value: 9.81, Tolerance: 0.05, Relative: true}, Marks: 1},
+			{ID: "1", Kind: models.KindShortText, Text: "Who wrote Hamlet?",
+				TextRules: []models.TextRule{
+					{Match: models.MatchCaseInsensitive, Pattern: "Shakespeare"},
+					{Match: models.MatchCaseInsensitive, Pattern: "Shakspere", Score: 0.5},
+				}, Marks: 4},
+		},
+	}
+}
+
+func TestQTI_RoundTrip(t *testing.T) {
+	var pkg bytes.Buffer
+	require.NoError(t, quizfmt.WriteQTI(&pkg, qtiQuiz()))
+
+	files := zipFiles(t, pkg.Bytes())
+	assert.Contains(t, files, "imsmanifest.xml")
+	assert.Contains(t, files, "assessment.xml")
+	assert.Contains(t, files, "items/capital.xml")
+	// "1" is not a QTI identifier, so it is kept as the item label
+	assert.Contains(t, files, "items/item_6.xml")
+	assert.Contains(t, files["items/item_6.xml"], `label="1"`)
+
+	quiz, err := quizfmt.ParseQTI(&pkg)
+	require.NoError(t, err)
+	expected := qtiQuiz()
+	expected.ID = ""
+	assert.Equal(t, expected, quiz)
+}
+
+func TestParseQTI_ReportsUnsupportedInteractions(t *testing.T) {
+	manifest := `<manifest xmlns="http://www.imsglobal.org/xsd/imscp_v1p1" identifier="m"><resources>
+		<resource identifier="r1" type="imsqti_item_xmlv2p1" href="order.xml"/>
+		<resource identifier="r2" type="imsqti_item_xmlv2p1" href="choice.xml"/>
+		<resource identifier="r3" type="imsqti_item_xmlv2p1" href="missing.xml"/>
+	</resources></manifest>`
+	order := `<assessmentItem xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" identifier="order" title="Order">
+		<responseDeclaration identifier="RESPONSE" cardinality="ordered" baseType="identifier"/>
+		<itemBody><orderInteraction responseIdentifier="RESPONSE"><simpleChoice identifier="a">A</simpleChoice></orderInteraction></itemBody>
+	</assessmentItem>`
+	choice := `<assessmentItem xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" identifier="choice" title="Choice">
+		<responseDeclaration identifier="RESPONSE" cardinality="single" baseType="identifier">
+			<correctResponse><value>b</value></correctResponse>
+		</responseDeclaration>
+		<itemBody><p>Pick <b>one</b></p><choiceInteraction responseIdentifier="RESPONSE" maxChoices="1">
+			<simpleChoice identifier="a">A</simpleChoice><simpleChoice identifier="b">B</simpleChoice>
+		</choiceInteraction></itemBody>
+	</assessmentItem>`
+	pkg := buildZip(t, map[string]string{"imsmanifest.xml": manifest, "order.xml": order, "choice.xml": choice})
+
+	_, err := quizfmt.ParseQTI(bytes.NewReader(pkg))
+	var problems quizfmt.ItemErrorList
+	require.True(t, errors.As(err, &problems), err)
+	require.Len(t, problems, 2)
+	assert.EqualError(t, problems[0], "item order: unsupported interaction orderInteraction")
+	assert.Equal(t, "missing.xml", problems[1].Item)
+
+	// Without the broken items the choice item imports
+	pkg = buildZip(t, map[string]string{"imsmanifest.xml": `<manifest identifier="m"><resources>
+		<resource identifier="r2" type="imsqti_item_xmlv2p1" href="choice.xml"/></resources></manifest>`, "choice.xml": choice})
+	quiz, err := quizfmt.ParseQTI(bytes.NewReader(pkg))
+	require.NoError(t, err)
+	assert.Equal(t, []models.Question{{ID: "choice", Kind: models.KindSingleChoice, Text: "Pick one",
+		Options: []string{"A", "B"}, CorrectOption: 1, Marks: 1}}, quiz.Questions)
+}
+
+func TestParseQTI_LimitsDecompressedSize(t *testing.T) {
+	// Zeros compress to almost nothing, as in a zip bomb
+	big := strings.Repeat("\x00", 9<<20)
+	_, err := quizfmt.ParseQTI(bytes.NewReader(buildZip(t, map[string]string{"imsmanifest.xml": big})))
+	assert.EqualError(t, err, "file imsmanifest.xml is too large")
+
+	// Files under the limit each still add up to too much
+	var resources strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&resources, `<resource identifier="r%d" type="imsqti_item_xmlv2p1" href="item.xml"/>`, i)
+	}
+	pkg := buildZip(t, map[string]string{
+		"imsmanifest.xml": `<manifest identifier="m"><resources>` + resources.String() + `</resources></manifest>`,
+		"item.xml":        strings.Repeat("\x00", 7<<20),
+	})
+	_, err = quizfmt.ParseQTI(bytes.NewReader(pkg))
+	assert.EqualError(t, err, "package decompresses to too much data")
+}
+
+func TestImportExportQTIEndpoints(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.CreateQuiz(context.Background(), qtiQuiz()))
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+
+	req, _ := http.NewRequest("GET", "/quiz/qti/export?format=qti", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+
+	req, _ = http.NewRequest("POST", "/quiz/import?format=qti&id=copy", bytes.NewReader(rr.Body.Bytes()))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	copied, err := store.GetQuiz(context.Background(), "copy")
+	require.NoError(t, err)
+	assert.Equal(t, qtiQuiz().Questions, copied.Questions)
+
+	req, _ = http.NewRequest("POST", "/quiz/import?format=qti&id=junk", bytes.NewReader([]byte("not a zip")))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	quiz := qtiQuiz()
+	quiz.ID = "regex"
+	quiz.Questions[5].TextRules = []models.TextRule{{Match: models.MatchRegex, Pattern: "a+"}}
+	require.NoError(t, store.CreateQuiz(context.Background(), quiz))
+	req, _ = http.NewRequest("GET", "/quiz/regex/export?format=qti", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		require.NoError(t, err)
+		io.WriteString(f, content)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func zipFiles(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+	return files
+}
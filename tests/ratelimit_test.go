@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"quiz-app/internal/auth"
+	"quiz-app/internal/middleware"
+	"quiz-app/internal/ratelimit"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimiter_TokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+	limiter := ratelimit.NewMemory()
+	limiter.SetClock(clock.Now)
+	limit := ratelimit.Limit{Tokens: 3, Per: time.Minute}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		ok, _, err := limiter.Allow(ctx, "ada", limit)
+		require.NoError(t, err)
+		assert.True(t, ok, "a full bucket allows a burst")
+	}
+	ok, retryAfter, err := limiter.Allow(ctx, "ada", limit)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 20*time.Second, retryAfter)
+
+	ok, _, _ = limiter.Allow(ctx, "bob", limit)
+	assert.True(t, ok, "each key has its own bucket")
+
+	clock.Advance(20 * time.Second)
+	ok, _, _ = limiter.Allow(ctx, "ada", limit)
+	assert.True(t, ok, "a token refills every 20 seconds")
+	ok, _, _ = limiter.Allow(ctx, "ada", limit)
+	assert.False(t, ok)
+
+	// A bucket left alone refills completely, but no further
+	clock.Advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		ok, _, _ = limiter.Allow(ctx, "ada", limit)
+		assert.True(t, ok)
+	}
+	ok, _, _ = limiter.Allow(ctx, "ada", limit)
+	assert.False(t, ok)
+}
+
+func TestParseRateLimits(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("100/15m")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Tokens: 100, Per: 15 * time.Minute}, limit)
+
+	rules, err := ratelimit.ParseRules("*=600/m, POST /quiz/{quizId}/answer=30/s")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ratelimit.Limit{
+		"*":                          {Tokens: 600, Per: time.Minute},
+		"POST /quiz/{quizId}/answer": {Tokens: 30, Per: time.Second},
+	}, rules)
+
+	for _, bad := range []string{"10", "0/s", "ten/s", "10/fortnight", "10/-1s"} {
+		_, err := ratelimit.ParseLimit(bad)
+		assert.Error(t, err, bad)
+	}
+	_, err = ratelimit.ParseRules("=10/s")
+	assert.Error(t, err)
+	_, err = ratelimit.ParseRules("*=10/s,*=20/s")
+	assert.Error(t, err)
+}
+
+func newThrottledHarness(t *testing.T, opts middleware.ThrottleOptions) *authHarness {
+	t.Helper()
+	keys, err := auth.ParseAPIKeys("tom:taker:tom-key, eve:taker:eve-key")
+	require.NoError(t, err)
+	authn, err := auth.New(auth.Options{APIKeys: keys})
+	require.NoError(t, err)
+	throttle, err := middleware.NewThrottle(opts)
+	require.NoError(t, err)
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+	return &authHarness{store: store, router: routes.SetupRoutes(store, authn, nil, throttle, nil)}
+}
+
+func TestThrottle_RouteLimits(t *testing.T) {
+	h := newThrottledHarness(t, middleware.ThrottleOptions{Routes: map[string]ratelimit.Limit{
+		"GET /quiz/{id}": {Tokens: 2, Per: time.Minute},
+		"*":              {Tokens: 100, Per: time.Minute},
+	}})
+	tom := http.Header{auth.APIKeyHeader: {"tom-key"}}
+	eve := http.Header{auth.APIKeyHeader: {"eve-key"}}
+
+	assert.Equal(t, http.StatusOK, h.do("GET", "/quiz/1", tom, "").Code)
+	assert.Equal(t, http.StatusOK, h.do("GET", "/orgs/default/quiz/1", tom, "").Code)
+	rr := h.do("GET", "/quiz/1", tom, "")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error": {"code": "too_many_requests", "message": "Too many requests"}}`, rr.Body.String())
+
+	assert.Equal(t, http.StatusOK, h.do("GET", "/quiz/1", eve, "").Code, "users are limited separately")
+	assert.Equal(t, http.StatusOK, h.do("GET", "/quiz", tom, "").Code, "routes are limited separately")
+}
+
+func TestThrottle_AnswersPerQuestion(t *testing.T) {
+	h := newThrottledHarness(t, middleware.ThrottleOptions{Answers: ratelimit.Limit{Tokens: 2, Per: time.Hour}})
+	tom := http.Header{auth.APIKeyHeader: {"tom-key"}}
+	answer := func(header http.Header, body string) int {
+		return h.do("POST", "/quiz/1/answer", header, body).Code
+	}
+
+	// Trying every option of q1 in turn is cut off
+	assert.Equal(t, http.StatusOK, answer(tom, `{"question_id": "q1", "selected_option": 0}`))
+	assert.Equal(t, http.StatusOK, answer(tom, `{"question_id": "q1", "selected_option": 1}`))
+	assert.Equal(t, http.StatusTooManyRequests, answer(tom, `{"question_id": "q1", "selected_option": 2}`))
+
+	assert.Equal(t, http.StatusOK, answer(tom, `{"question_id": "q2", "selected_option": 2}`), "each question has its own cap")
+	assert.Equal(t, http.StatusOK, answer(http.Header{auth.APIKeyHeader: {"eve-key"}}, `{"question_id": "q1", "selected_option": 1}`))
+
+	// Retrying with an idempotency key replays the answer without using up the cap
+	retry := http.Header{auth.APIKeyHeader: {"tom-key"}, middleware.IdempotencyHeader: {"k1"}}
+	assert.Equal(t, http.StatusOK, answer(retry, `{"question_id": "q2", "selected_option": 2}`))
+	assert.Equal(t, http.StatusOK, answer(retry, `{"question_id": "q2", "selected_option": 2}`))
+	assert.Equal(t, http.StatusTooManyRequests, answer(tom, `{"question_id": "q2", "selected_option": 1}`))
+}
+
+// failingLimiter is a shared limiter whose store is down
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, ratelimit.Limit) (bool, time.Duration, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+func TestThrottle_LimiterFailureAllowsRequests(t *testing.T) {
+	h := newThrottledHarness(t, middleware.ThrottleOptions{
+		Limiter: failingLimiter{},
+		Routes:  map[string]ratelimit.Limit{"*": {Tokens: 1, Per: time.Hour}},
+	})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, h.do("GET", "/quiz/1", http.Header{auth.APIKeyHeader: {"tom-key"}}, "").Code)
+	}
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"quiz-app/internal/analytics"
+	"quiz-app/internal/models"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalytics_SummarizeDichotomous(t *testing.T) {
+	store, quiz := analysedQuiz(t)
+	results, err := store.ListResults(context.Background(), "1")
+	require.NoError(t, err)
+
+	stats := analytics.Summarize(quiz, results, 3)
+	assert.Equal(t, 4, stats.Respondents)
+	assert.Equal(t, 3, stats.Items)
+	assert.InDelta(t, 0.75, stats.Mean, 1e-9)
+	assert.InDelta(t, math.Sqrt(0.6875), stats.StdDev, 1e-9)
+	assert.Equal(t, 0.0, stats.Min)
+	assert.Equal(t, 2.0, stats.Max)
+
+	// Item variances .25 + .1875 + 0 against a total variance of .6875
+	want := 1.5 * (1 - 0.4375/0.6875)
+	require.NotNil(t, stats.KR20)
+	require.NotNil(t, stats.Alpha)
+	assert.InDelta(t, want, *stats.KR20, 1e-9)
+	assert.InDelta(t, want, *stats.Alpha, 1e-9)
+	assert.InDelta(t, math.Sqrt(0.6875)*math.Sqrt(1-want), *stats.SEM, 1e-9)
+
+	assert.Equal(t, []analytics.Bin{
+		{From: 0, To: 1, Count: 2},
+		{From: 1, To: 2, Count: 1},
+		{From: 2, To: 3, Count: 1},
+	}, stats.Histogram)
+}
+
+func TestAnalytics_SummarizePartialCredit(t *testing.T) {
+	question := func(id string) models.Question {
+		return models.Question{
+			ID: id, Kind: models.KindMultipleSelect, Options: []string{"a", "b", "c", "d"},
+			CorrectOptions: []int{0, 1}, Scoring: models.ScoringProportional, Marks: 2,
+		}
+	}
+	quiz := &models.Quiz{ID: "1", Questions: []models.Question{question("q1"), question("q2")}}
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+	picks := map[string][]int{"u1": {0, 1}, "u2": {0}, "u3": {2}}
+	for user, selected := range picks {
+		for _, q := range quiz.Questions {
+			_, _, err := store.SubmitAnswer(context.Background(), "1", user, &models.Answer{QuestionID: q.ID, SelectedOptions: selected})
+			require.NoError(t, err)
+		}
+	}
+	results, _ := store.ListResults(context.Background(), "1")
+
+	stats := analytics.Summarize(quiz, results, 4)
+	assert.Nil(t, stats.KR20)
+	require.NotNil(t, stats.Alpha)
+	assert.InDelta(t, 1.0, *stats.Alpha, 1e-9)
+	assert.InDelta(t, 0.0, *stats.SEM, 1e-9)
+	assert.Len(t, stats.Histogram, 4)
+}
+
+func TestAnalytics_SummarizeSEMOnItemScale(t *testing.T) {
+	quiz := &models.Quiz{ID: "1", IsNegativeMarking: true, Penalty: 1, Questions: []models.Question{
+		{ID: "q1", Options: []string{"a", "b"}, CorrectOption: 0, Marks: 2},
+		{ID: "q2", Options: []string{"a", "b"}, CorrectOption: 0, Marks: 2},
+	}}
+	right := models.Answer{IsCorrect: true, Score: 2}
+	wrong := models.Answer{SelectedOption: 1, Score: -1}
+	results := []models.Result{
+		{QuizID: "1", UserID: "u1", Score: 4, Answers: map[string]models.Answer{"q1": right, "q2": right}},
+		{QuizID: "1", UserID: "u2", Score: 1, Answers: map[string]models.Answer{"q1": right, "q2": wrong}},
+		{QuizID: "1", UserID: "u3", Score: -2, Answers: map[string]models.Answer{"q1": wrong, "q2": wrong}},
+	}
+
+	stats := analytics.Summarize(quiz, results, 2)
+	assert.InDelta(t, math.Sqrt(6), stats.StdDev, 1e-9)
+	// Item variances 2/9 + 2/9 against a variance of 2/3 in the number right
+	require.NotNil(t, stats.KR20)
+	assert.InDelta(t, 2.0/3, *stats.KR20, 1e-9)
+	require.NotNil(t, stats.SEM)
+	assert.InDelta(t, math.Sqrt(2.0/3)*math.Sqrt(1.0/3), *stats.SEM, 1e-9, "penalties do not inflate the SEM")
+}
+
+func TestAnalytics_SummarizeWithoutResults(t *testing.T) {
+	stats := analytics.Summarize(sampleQuiz(), nil, 5)
+	assert.Equal(t, 0, stats.Respondents)
+	assert.Nil(t, stats.Alpha)
+	assert.Nil(t, stats.SEM)
+	require.Len(t, stats.Histogram, 5)
+	assert.Equal(t, 5.0, stats.Histogram[4].To)
+}
+
+func TestQuizStatsEndpoint(t *testing.T) {
+	store, _ := analysedQuiz(t)
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+
+	req, _ := http.NewRequest("GET", "/quiz/1/analytics/stats?bins=2", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var stats analytics.QuizStats
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	assert.Len(t, stats.Histogram, 2)
+	assert.NotNil(t, stats.KR20)
+
+	for _, url := range []string{"/quiz/1/analytics/stats?bins=0", "/quiz/1/analytics/stats?bins=x"} {
+		req, _ = http.NewRequest("GET", url, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, url)
+	}
+}
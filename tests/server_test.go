@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"quiz-app/internal/config"
+	"quiz-app/internal/server"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerDrainsRequestsOnShutdown(t *testing.T) {
+	arrived, release := make(chan struct{}), make(chan struct{})
+	mockStorage := new(MockStorage)
+	mockStorage.On("GetQuiz", "1").Run(func(mock.Arguments) {
+		close(arrived)
+		<-release
+	}).Return(sampleQuiz(), nil)
+	mockStorage.On("Close").Return(nil)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.New(config.Default(), mockStorage, nil, nil, nil, nil).Serve(ctx, ln) }()
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/quiz/1")
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+
+	<-arrived
+	cancel()
+	select {
+	case <-served:
+		t.Fatal("server stopped before the in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-status)
+	require.NoError(t, <-served)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestServerLimitsBodySize(t *testing.T) {
+	cfg := config.Default()
+	cfg.MaxBodyBytes = 16
+	mockStorage := new(MockStorage)
+	mockStorage.On("Close").Return(nil)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.New(cfg, mockStorage, nil, nil, nil, nil).Serve(ctx, ln) }()
+
+	resp, err := http.Post("http://"+ln.Addr().String()+"/quiz", "application/json",
+		strings.NewReader(`{"id": "a-quiz-with-a-long-id"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	cancel()
+	require.NoError(t, <-served)
+	mockStorage.AssertNotCalled(t, "CreateQuiz", mock.Anything)
+}
+
+func TestServerWithoutRequestTimeout(t *testing.T) {
+	cfg := config.Default()
+	cfg.RequestTimeout = 0
+	require.NoError(t, cfg.Validate())
+	// Memory storage fails calls whose context is done
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.New(cfg, store, nil, nil, nil, nil).Serve(ctx, ln) }()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/quiz/1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "a zero timeout sets no deadline")
+
+	cancel()
+	require.NoError(t, <-served)
+}
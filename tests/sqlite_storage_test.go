@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"quiz-app/internal/models"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteStorage(t *testing.T) (*storage.SQLiteStorage, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "quiz.db")
+	store, err := storage.NewSQLiteStorage(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store, path
+}
+
+func sampleQuiz() *models.Quiz {
+	return &models.Quiz{
+		ID:                "1",
+		Title:             "Test Quiz",
+		IsNegativeMarking: true,
+		Penalty:           0.5,
+		Questions: []models.Question{
+			{
+				ID:            "q1",
+				Text:          "What is 1+1?",
+				Options:       []string{"1", "2", "3", "4"},
+				CorrectOption: 1,
+				Marks:         2,
+			},
+			{
+				ID:            "q2",
+				Text:          "What is 2+2?",
+				Options:       []string{"2", "3", "4", "5"},
+				CorrectOption: 2,
+				Marks:         3,
+			},
+		},
+	}
+}
+
+func TestSQLiteStorage_CreateAndGetQuiz(t *testing.T) {
+	store, _ := newSQLiteStorage(t)
+
+	quiz := sampleQuiz()
+	assert.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+	retrievedQuiz, err := store.GetQuiz(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, quiz, retrievedQuiz)
+
+	_, err = store.GetQuiz(context.Background(), "nonexistent")
+	assert.Error(t, err)
+	assert.Equal(t, "quiz not found", err.Error())
+}
+
+func TestSQLiteStorage_SubmitAnswerAndGetResults(t *testing.T) {
+	store, _ := newSQLiteStorage(t)
+	assert.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+
+	isCorrect, correctAnswer, err := store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+	assert.NoError(t, err)
+	assert.True(t, isCorrect)
+	assert.Empty(t, correctAnswer)
+
+	isCorrect, correctAnswer, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q2", SelectedOption: 1})
+	assert.NoError(t, err)
+	assert.False(t, isCorrect)
+	assert.Equal(t, "4", correctAnswer)
+
+	result, err := store.GetResults(context.Background(), "1", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1.5), result.Score)
+	assert.Equal(t, 2, len(result.Answers))
+	assert.True(t, result.Answers["q1"].IsCorrect)
+	assert.False(t, result.Answers["q2"].IsCorrect)
+
+	_, err = store.GetResults(context.Background(), "1", "nonexistent")
+	assert.Equal(t, "no results found for this user", err.Error())
+	_, err = store.GetResults(context.Background(), "nonexistent", "user1")
+	assert.Equal(t, "no results found for this quiz", err.Error())
+
+	_, _, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "nonexistent"})
+	assert.Equal(t, "question not found", err.Error())
+}
+
+func TestSQLiteStorage_PersistsAcrossReopen(t *testing.T) {
+	store, path := newSQLiteStorage(t)
+	assert.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+	_, _, err := store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	reopened, err := storage.NewSQLiteStorage(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	expected := sampleQuiz()
+	expected.Version = 1
+	quiz, err := reopened.GetQuiz(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, quiz)
+
+	result, err := reopened.GetResults(context.Background(), "1", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(2), result.Score)
+	assert.True(t, result.Answers["q1"].IsCorrect)
+}
+
+func TestSQLiteStorage_MultipleSelect(t *testing.T) {
+	store, _ := newSQLiteStorage(t)
+
+	quiz := &models.Quiz{
+		ID: "1",
+		Questions: []models.Question{{
+			ID:             "q1",
+			Text:           "Which are prime?",
+			Options:        []string{"2", "4", "5", "9"},
+			CorrectOptions: []int{0, 2},
+			Scoring:        models.ScoringProportional,
+			Marks:          4,
+		}},
+	}
+	assert.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+	retrievedQuiz, err := store.GetQuiz(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, quiz, retrievedQuiz)
+
+	_, _, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOptions: []int{0}})
+	assert.NoError(t, err)
+
+	result, err := store.GetResults(context.Background(), "1", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(3), result.Score)
+	assert.Equal(t, []int{0}, result.Answers["q1"].SelectedOptions)
+	assert.Equal(t, float32(3), result.Answers["q1"].Score)
+}
+
+func TestSQLiteStorage_TypedAnswers(t *testing.T) {
+	store, _ := newSQLiteStorage(t)
+
+	quiz := &models.Quiz{
+		ID: "1",
+		Questions: []models.Question{
+			{ID: "n", Kind: models.KindNumeric, Numeric: &models.NumericKey{Value: 42, Tolerance: 0.5}, Marks: 1},
+			{ID: "t", Kind: models.KindShortText, TextRules: []models.TextRule{{Match: models.MatchRegex, Pattern: "blue|azure"}}, Marks: 1},
+		},
+	}
+	assert.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+	retrievedQuiz, err := store.GetQuiz(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, quiz, retrievedQuiz)
+
+	value := 42.25
+	_, _, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "n", NumericValue: &value})
+	assert.NoError(t, err)
+	_, _, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "t", Text: "azure"})
+	assert.NoError(t, err)
+
+	result, err := store.GetResults(context.Background(), "1", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(2), result.Score)
+	assert.Equal(t, &value, result.Answers["n"].NumericValue)
+	assert.Nil(t, result.Answers["t"].NumericValue)
+	assert.Equal(t, "azure", result.Answers["t"].Text)
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"testing"
 
 	"quiz-app/internal/models"
@@ -28,10 +29,10 @@ func TestMemoryStorage_CreateAndGetQuiz(t *testing.T) {
 		},
 	}
 
-	err := store.CreateQuiz(quiz)
+	err := store.CreateQuiz(context.Background(), quiz)
 	assert.NoError(t, err)
 
-	retrievedQuiz, err := store.GetQuiz("1")
+	retrievedQuiz, err := store.GetQuiz(context.Background(), "1")
 	assert.NoError(t, err)
 	assert.Equal(t, quiz.ID, retrievedQuiz.ID)
 	assert.Equal(t, quiz.Title, retrievedQuiz.Title)
@@ -68,23 +69,23 @@ func TestMemoryStorage_SubmitAnswerAndGetResults(t *testing.T) {
 		},
 	}
 
-	err := store.CreateQuiz(quiz)
+	err := store.CreateQuiz(context.Background(), quiz)
 	assert.NoError(t, err)
 
 	// Test correct answer
-	isCorrect, correctAnswer, err := store.SubmitAnswer("1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+	isCorrect, correctAnswer, err := store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
 	assert.NoError(t, err)
 	assert.True(t, isCorrect)
 	assert.Empty(t, correctAnswer)
 
 	// Test incorrect answer
-	isCorrect, correctAnswer, err = store.SubmitAnswer("1", "user1", &models.Answer{QuestionID: "q2", SelectedOption: 1})
+	isCorrect, correctAnswer, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q2", SelectedOption: 1})
 	assert.NoError(t, err)
 	assert.False(t, isCorrect)
 	assert.Equal(t, "4", correctAnswer)
 
 	// Get results
-	result, err := store.GetResults("1", "user1")
+	result, err := store.GetResults(context.Background(), "1", "user1")
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, "1", result.QuizID)
@@ -99,7 +100,7 @@ func TestMemoryStorage_GetResultsNotFound(t *testing.T) {
 	store := storage.NewMemoryStorage()
 
 	// Test for non-existent quiz
-	_, err := store.GetResults("nonexistent", "user1")
+	_, err := store.GetResults(context.Background(), "nonexistent", "user1")
 	assert.Error(t, err)
 	assert.Equal(t, "no results found for this quiz", err.Error())
 
@@ -113,23 +114,24 @@ func TestMemoryStorage_GetResultsNotFound(t *testing.T) {
 			Marks:         1,
 		},
 	}}
-	store.CreateQuiz(quiz)
-	store.SubmitAnswer("1", "userX", &models.Answer{
+	store.CreateQuiz(context.Background(), quiz)
+	store.SubmitAnswer(context.Background(), "1", "userX", &models.Answer{
 		QuestionID:     "1",
 		SelectedOption: 1,
 		IsCorrect:      true,
 	})
 
 	// Test for non-existent user
-	_, err = store.GetResults("1", "nonexistent")
+	_, err = store.GetResults(context.Background(), "1", "nonexistent")
 	assert.Error(t, err)
 	assert.Equal(t, "no results found for this user", err.Error())
+	assert.ErrorIs(t, err, storage.ErrResultsNotFound)
 }
 
 func TestMemoryStorage_SubmitAnswerQuizNotFound(t *testing.T) {
 	store := storage.NewMemoryStorage()
 
-	_, _, err := store.SubmitAnswer("nonexistent", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 0})
+	_, _, err := store.SubmitAnswer(context.Background(), "nonexistent", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 0})
 	assert.Error(t, err)
 	assert.Equal(t, "quiz not found", err.Error())
 }
@@ -151,18 +153,21 @@ func TestMemoryStorage_SubmitAnswerQuestionNotFound(t *testing.T) {
 		},
 	}
 
-	store.CreateQuiz(quiz)
+	store.CreateQuiz(context.Background(), quiz)
 
-	_, _, err := store.SubmitAnswer("1", "user1", &models.Answer{QuestionID: "nonexistent", SelectedOption: 0})
+	_, _, err := store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "nonexistent", SelectedOption: 0})
 	assert.Error(t, err)
 	assert.Equal(t, "question not found", err.Error())
+	assert.ErrorIs(t, err, storage.ErrQuestionNotFound)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.NotErrorIs(t, err, storage.ErrConflict)
 }
 
 func TestMemoryStorage_GetQuizNotFound(t *testing.T) {
 	store := storage.NewMemoryStorage()
 
 	// Attempt to get a quiz that doesn't exist
-	_, err := store.GetQuiz("nonexistent")
+	_, err := store.GetQuiz(context.Background(), "nonexistent")
 	assert.Error(t, err)
 	assert.Equal(t, "quiz not found", err.Error())
 }
@@ -185,11 +190,255 @@ func TestMemoryStorage_SubmitAnswerInvalidCorrectOption(t *testing.T) {
 		},
 	}
 
-	err := store.CreateQuiz(quiz)
+	err := store.CreateQuiz(context.Background(), quiz)
 	assert.NoError(t, err)
 
 	// Attempt to submit an answer for this question
-	_, _, err = store.SubmitAnswer("1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 0})
+	_, _, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 0})
 	assert.Error(t, err)
 	assert.Equal(t, "invalid correct option", err.Error())
 }
+
+func TestStorage_QuizLifecycle(t *testing.T) {
+	backends := map[string]func(t *testing.T) storage.Storage{
+		"memory": func(t *testing.T) storage.Storage { return storage.NewMemoryStorage() },
+		"sqlite": func(t *testing.T) storage.Storage { s, _ := newSQLiteStorage(t); return s },
+	}
+
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+
+			quiz := sampleQuiz()
+			assert.NoError(t, store.CreateQuiz(context.Background(), quiz))
+			assert.Equal(t, 1, quiz.Version)
+			assert.ErrorIs(t, store.CreateQuiz(context.Background(), sampleQuiz()), storage.ErrQuizExists)
+
+			other := sampleQuiz()
+			other.ID, other.Title = "2", "Geography Basics"
+			assert.NoError(t, store.CreateQuiz(context.Background(), other))
+
+			quizzes, total, err := store.ListQuizzes(context.Background(), storage.QuizFilter{Title: "geography"})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, total)
+			assert.Equal(t, []models.QuizSummary{{ID: "2", Title: "Geography Basics", QuestionCount: 2, Version: 1}}, quizzes)
+
+			// Titles fold case beyond ASCII
+			greek := sampleQuiz()
+			greek.ID, greek.Title = "3", "ΓΕΩΓΡΑΦΊΑ"
+			assert.NoError(t, store.CreateQuiz(context.Background(), greek))
+			quizzes, total, err = store.ListQuizzes(context.Background(), storage.QuizFilter{Title: "γεω"})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, total)
+			assert.Equal(t, "3", quizzes[0].ID)
+			assert.NoError(t, store.DeleteQuiz(context.Background(), "3", false))
+
+			quizzes, total, err = store.ListQuizzes(context.Background(), storage.QuizFilter{Offset: 1, Limit: 1})
+			assert.NoError(t, err)
+			assert.Equal(t, 2, total)
+			assert.Equal(t, []string{"2"}, []string{quizzes[0].ID})
+
+			// Updating from a stale version is a conflict
+			update := sampleQuiz()
+			update.Title = "Renamed"
+			update.Version = 1
+			assert.NoError(t, store.UpdateQuiz(context.Background(), update))
+			assert.Equal(t, 2, update.Version)
+			update.Version = 1
+			assert.ErrorIs(t, store.UpdateQuiz(context.Background(), update), storage.ErrVersionConflict)
+
+			stored, err := store.GetQuiz(context.Background(), "1")
+			assert.NoError(t, err)
+			assert.Equal(t, "Renamed", stored.Title)
+			assert.Equal(t, 2, stored.Version)
+
+			missing := sampleQuiz()
+			missing.ID = "missing"
+			assert.ErrorIs(t, store.UpdateQuiz(context.Background(), missing), storage.ErrQuizNotFound)
+
+			// Deleting removes the quiz and its results
+			_, _, err = store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+			assert.NoError(t, err)
+			assert.NoError(t, store.DeleteQuiz(context.Background(), "1", true))
+			_, err = store.GetQuiz(context.Background(), "1")
+			assert.ErrorIs(t, err, storage.ErrQuizNotFound)
+			_, err = store.GetResults(context.Background(), "1", "user1")
+			assert.Error(t, err)
+			assert.ErrorIs(t, store.DeleteQuiz(context.Background(), "1", false), storage.ErrQuizNotFound)
+
+			// The ID can be reused afterwards
+			assert.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+		})
+	}
+}
+
+func TestMemoryStorage_MultipleSelectScoring(t *testing.T) {
+	// Options 0 and 2 are correct out of four, worth 4 marks
+	tests := []struct {
+		name      string
+		scoring   models.ScoringMode
+		negative  bool
+		selected  []int
+		score     float32
+		isCorrect bool
+	}{
+		{"all or nothing exact", models.ScoringAllOrNothing, false, []int{2, 0}, 4, true},
+		{"all or nothing partial", models.ScoringAllOrNothing, false, []int{0}, 0, false},
+		{"all or nothing partial with penalty", models.ScoringAllOrNothing, true, []int{0}, -0.5, false},
+		{"proportional one missing", models.ScoringProportional, false, []int{0}, 3, false},
+		{"proportional one extra", models.ScoringProportional, false, []int{0, 1, 2}, 3, false},
+		{"proportional partial is not penalised", models.ScoringProportional, true, []int{0}, 3, false},
+		{"proportional everything inverted", models.ScoringProportional, true, []int{1, 3}, -0.5, false},
+		{"right minus wrong", models.ScoringRightMinusWrong, false, []int{0, 1}, 0, false},
+		{"right minus wrong net positive", models.ScoringRightMinusWrong, false, []int{0, 2, 3}, 2, false},
+		{"right minus wrong floors then penalises", models.ScoringRightMinusWrong, true, []int{1, 3}, -0.5, false},
+		{"out of range selection counts as wrong", models.ScoringRightMinusWrong, false, []int{0, 2, 9}, 2, false},
+		{"duplicate selections count once", models.ScoringAllOrNothing, false, []int{0, 0, 2}, 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStorage()
+			quiz := &models.Quiz{
+				ID:                "1",
+				IsNegativeMarking: tt.negative,
+				Penalty:           0.5,
+				Questions: []models.Question{{
+					ID:             "q1",
+					Text:           "Which are prime?",
+					Options:        []string{"2", "4", "5", "9"},
+					CorrectOptions: []int{0, 2},
+					Scoring:        tt.scoring,
+					Marks:          4,
+				}},
+			}
+			assert.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+			answer := &models.Answer{QuestionID: "q1", SelectedOptions: tt.selected}
+			isCorrect, correctAnswer, err := store.SubmitAnswer(context.Background(), "1", "user1", answer)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.isCorrect, isCorrect)
+			assert.Equal(t, tt.score, answer.Score)
+			if !tt.isCorrect {
+				assert.Equal(t, "2, 5", correctAnswer)
+			}
+
+			result, err := store.GetResults(context.Background(), "1", "user1")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.score, result.Score)
+		})
+	}
+}
+
+func TestMemoryStorage_TypedAnswers(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+
+	quiz := &models.Quiz{
+		ID: "1",
+		Questions: []models.Question{
+			{ID: "abs", Kind: models.KindNumeric, Numeric: &models.NumericKey{Value: 9.81, Tolerance: 0.05}, Marks: 2},
+			{ID: "rel", Kind: models.KindNumeric, Numeric: &models.NumericKey{Value: 300, Tolerance: 0.1, Relative: true}, Marks: 2},
+			{ID: "text", Kind: models.KindShortText, Marks: 4, TextRules: []models.TextRule{
+				{Match: models.MatchNormalized, Pattern: "George Washington"},
+				{Match: models.MatchEditDistance, Pattern: "George Washington", MaxDistance: 2, Score: 0.5},
+				{Match: models.MatchRegex, Pattern: `(?i)\w+ washington`, Score: 0.25},
+				{Match: models.MatchCaseInsensitive, Pattern: "GW", Score: 0.75},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		answer        models.Answer
+		score         float32
+		correctAnswer string
+	}{
+		{"absolute within tolerance", models.Answer{QuestionID: "abs", NumericValue: value(9.86)}, 2, ""},
+		{"absolute outside tolerance", models.Answer{QuestionID: "abs", NumericValue: value(9.9)}, 0, "9.81 ± 0.05"},
+		{"missing number", models.Answer{QuestionID: "abs"}, 0, "9.81 ± 0.05"},
+		{"relative within tolerance", models.Answer{QuestionID: "rel", NumericValue: value(330)}, 2, ""},
+		{"relative outside tolerance", models.Answer{QuestionID: "rel", NumericValue: value(331)}, 0, "300 ± 10%"},
+		{"normalized text", models.Answer{QuestionID: "text", Text: "  george   WASHINGTON "}, 4, ""},
+		{"misspelt text", models.Answer{QuestionID: "text", Text: "Gorge Washingtn"}, 2, "George Washington"},
+		{"regex text", models.Answer{QuestionID: "text", Text: "Martha Washington"}, 1, "George Washington"},
+		{"case-insensitive text", models.Answer{QuestionID: "text", Text: "gw"}, 3, "George Washington"},
+		{"unmatched text", models.Answer{QuestionID: "text", Text: "Lincoln"}, 0, "George Washington"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStorage()
+			assert.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+			answer := tt.answer
+			isCorrect, correctAnswer, err := store.SubmitAnswer(context.Background(), "1", "user1", &answer)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.correctAnswer == "", isCorrect)
+			assert.Equal(t, tt.correctAnswer, correctAnswer)
+			assert.Equal(t, tt.score, answer.Score)
+		})
+	}
+}
+
+func TestStorage_AnswerPolicies(t *testing.T) {
+	backends := map[string]func(t *testing.T) storage.Storage{
+		"memory": func(t *testing.T) storage.Storage { return storage.NewMemoryStorage() },
+		"sqlite": func(t *testing.T) storage.Storage { s, _ := newSQLiteStorage(t); return s },
+	}
+
+	// Each policy sees the same answers to q1 (worth 2, penalty 0.5): right, wrong, right
+	tests := []struct {
+		policy models.AnswerPolicy
+		scores []float32 // result score after each answer
+		errs   []error
+		kept   bool // whether the last stored answer is correct
+	}{
+		{models.PolicyLastWins, []float32{2, -0.5, 2}, []error{nil, nil, nil}, true},
+		{"", []float32{2, -0.5, 2}, []error{nil, nil, nil}, true},
+		{models.PolicyFirstFinal, []float32{2, 2, 2}, []error{nil, storage.ErrAlreadyAnswered, storage.ErrAlreadyAnswered}, true},
+		{models.PolicyBest, []float32{2, 2, 2}, []error{nil, nil, nil}, true},
+	}
+
+	for name, newStore := range backends {
+		for _, tt := range tests {
+			t.Run(name+"/"+string(tt.policy), func(t *testing.T) {
+				store := newStore(t)
+				quiz := sampleQuiz()
+				quiz.AnswerPolicy = tt.policy
+				assert.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+				for i, selected := range []int{1, 0, 1} {
+					_, _, err := store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: selected})
+					assert.ErrorIs(t, err, tt.errs[i])
+
+					result, err := store.GetResults(context.Background(), "1", "user1")
+					assert.NoError(t, err)
+					assert.Equal(t, tt.scores[i], result.Score, "after answer %d", i+1)
+				}
+
+				result, _ := store.GetResults(context.Background(), "1", "user1")
+				assert.Equal(t, tt.kept, result.Answers["q1"].IsCorrect)
+			})
+		}
+	}
+}
+
+func TestStorage_BestPolicyKeepsEarlierAnswer(t *testing.T) {
+	store, _ := newSQLiteStorage(t)
+	quiz := sampleQuiz()
+	quiz.AnswerPolicy = models.PolicyBest
+	assert.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+	_, _, err := store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+	assert.NoError(t, err)
+	answer := &models.Answer{QuestionID: "q1", SelectedOption: 3}
+	isCorrect, _, err := store.SubmitAnswer(context.Background(), "1", "user1", answer)
+	assert.NoError(t, err)
+	assert.False(t, isCorrect)
+	assert.Equal(t, float32(-0.5), answer.Score)
+
+	result, err := store.GetResults(context.Background(), "1", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Answers["q1"].SelectedOption)
+	assert.Equal(t, float32(2), result.Score)
+}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"quiz-app/internal/auth"
+	"quiz-app/internal/models"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+	"quiz-app/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStorageBackends() map[string]func(t *testing.T) storage.Storage {
+	return map[string]func(t *testing.T) storage.Storage{
+		"memory": func(t *testing.T) storage.Storage { return storage.NewMemoryStorage() },
+		"sqlite": func(t *testing.T) storage.Storage { s, _ := newSQLiteStorage(t); return s },
+	}
+}
+
+func TestStorage_OrganizationsAreIsolated(t *testing.T) {
+	physics := tenant.WithOrg(context.Background(), &tenant.Org{ID: "physics"})
+	chemistry := tenant.WithOrg(context.Background(), &tenant.Org{ID: "chemistry"})
+
+	for name, newStore := range newStorageBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			quiz := sampleQuiz()
+			quiz.Title = "Mechanics"
+			require.NoError(t, store.CreateQuiz(physics, quiz))
+			quiz = sampleQuiz()
+			quiz.Title = "Titration"
+			require.NoError(t, store.CreateQuiz(chemistry, quiz), "IDs are only unique within an organization")
+
+			got, err := store.GetQuiz(physics, "1")
+			require.NoError(t, err)
+			assert.Equal(t, "Mechanics", got.Title)
+			got, err = store.GetQuiz(chemistry, "1")
+			require.NoError(t, err)
+			assert.Equal(t, "Titration", got.Title)
+			_, err = store.GetQuiz(context.Background(), "1")
+			assert.ErrorIs(t, err, storage.ErrQuizNotFound, "the default organization has no quizzes")
+
+			_, _, err = store.SubmitAnswer(physics, "1", "ada", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+			require.NoError(t, err)
+			_, err = store.GetResults(chemistry, "1", "ada")
+			assert.ErrorIs(t, err, storage.ErrResultsNotFound)
+			results, err := store.ListResults(chemistry, "1")
+			require.NoError(t, err)
+			assert.Empty(t, results)
+
+			quizzes, total, err := store.ListQuizzes(chemistry, storage.QuizFilter{})
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			assert.Equal(t, "Titration", quizzes[0].Title)
+
+			require.NoError(t, store.DeleteQuiz(chemistry, "1", false))
+			_, err = store.GetResults(physics, "1", "ada")
+			assert.NoError(t, err, "deleting another organization's quiz leaves this one alone")
+		})
+	}
+}
+
+func TestStorage_OrganizationQuotas(t *testing.T) {
+	ctx := tenant.WithOrg(context.Background(), &tenant.Org{ID: "physics", Quotas: tenant.Quotas{Quizzes: 2, Attempts: 2}})
+
+	for name, newStore := range newStorageBackends() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			for _, id := range []string{"1", "2", "3"} {
+				quiz := sampleQuiz()
+				quiz.ID = id
+				err := store.CreateQuiz(ctx, quiz)
+				if id == "3" {
+					assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+				} else {
+					require.NoError(t, err)
+				}
+			}
+			require.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()), "other organizations have their own quotas")
+
+			_, err := store.StartAttempt(ctx, "1", "ada")
+			require.NoError(t, err)
+			_, _, err = store.SubmitAnswer(ctx, "2", "ada", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+			require.NoError(t, err)
+			_, _, err = store.SubmitAnswer(ctx, "2", "ada", &models.Answer{QuestionID: "q2", SelectedOption: 2})
+			assert.NoError(t, err, "answering within an attempt does not count again")
+
+			_, err = store.StartAttempt(ctx, "1", "bob")
+			assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+			_, _, err = store.SubmitAnswer(ctx, "2", "bob", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+			assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+
+			require.NoError(t, store.DeleteQuiz(ctx, "1", true))
+			require.NoError(t, store.CreateQuiz(ctx, sampleQuiz()), "deleting a quiz frees its place")
+			_, err = store.StartAttempt(ctx, "1", "bob")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParseOrgs(t *testing.T) {
+	orgs, err := tenant.ParseOrgs("physics:quizzes=50:attempts=2000:admin=ada:admin=grace, chemistry")
+	require.NoError(t, err)
+	assert.Equal(t, []tenant.Org{
+		{ID: "physics", Quotas: tenant.Quotas{Quizzes: 50, Attempts: 2000}, Admins: []string{"ada", "grace"}},
+		{ID: "chemistry"},
+	}, orgs)
+
+	for _, bad := range []string{"physics:quizzes=many", "physics:attempts=-1", "physics:admin=", "physics:colour=blue"} {
+		_, err := tenant.ParseOrgs(bad)
+		assert.Error(t, err, bad)
+	}
+	_, err = tenant.NewDirectory([]tenant.Org{{ID: "a/b"}})
+	assert.Error(t, err)
+	_, err = tenant.NewDirectory([]tenant.Org{{ID: "physics"}, {ID: "physics"}})
+	assert.Error(t, err)
+}
+
+func newTenantHarness(t *testing.T) *authHarness {
+	t.Helper()
+	keys, err := auth.ParseAPIKeys("physics/ada:author:ada-key, physics/tom:taker:tom-key, physics/grace:taker:grace-key," +
+		"chemistry/bob:author:bob-key, root:admin:root-key, carol:author:carol-key")
+	require.NoError(t, err)
+	orgs, err := tenant.NewDirectory([]tenant.Org{
+		{ID: "physics", Quotas: tenant.Quotas{Quizzes: 1}, Admins: []string{"grace"}},
+		{ID: "chemistry"},
+	})
+	require.NoError(t, err)
+	return newAuthHarness(t, auth.Options{APIKeys: keys, Orgs: orgs})
+}
+
+func TestTenantRoutes(t *testing.T) {
+	h := newTenantHarness(t)
+	key := func(k string) http.Header { return http.Header{auth.APIKeyHeader: {k}} }
+	quiz := func(title string) string {
+		q := sampleQuiz()
+		q.Title = title
+		body, _ := json.Marshal(q)
+		return string(body)
+	}
+	title := func(path, k string) string {
+		rr := h.do("GET", path, key(k), "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var q models.Quiz
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &q))
+		return q.Title
+	}
+
+	// Without the prefix, callers act in their own organization
+	assert.Equal(t, http.StatusCreated, h.do("POST", "/quiz", key("ada-key"), quiz("Mechanics")).Code)
+	assert.Equal(t, http.StatusCreated, h.do("POST", "/quiz", key("bob-key"), quiz("Titration")).Code)
+	assert.Equal(t, http.StatusCreated, h.do("POST", "/quiz", key("carol-key"), quiz("General")).Code)
+	assert.Equal(t, "Mechanics", title("/quiz/1", "ada-key"))
+	assert.Equal(t, "Mechanics", title("/orgs/physics/quiz/1", "ada-key"))
+	assert.Equal(t, "Titration", title("/quiz/1", "bob-key"))
+	assert.Equal(t, "General", title("/orgs/default/quiz/1", "carol-key"))
+
+	// Other organizations are off limits, except to admins of none
+	rr := h.do("GET", "/orgs/chemistry/quiz/1", key("ada-key"), "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.JSONEq(t, `{"error": {"code": "forbidden", "message": "You may not act in this organization"}}`, rr.Body.String())
+	assert.Equal(t, http.StatusForbidden, h.do("GET", "/orgs/physics/quiz/1", key("carol-key"), "").Code)
+	assert.Equal(t, "Titration", title("/orgs/chemistry/quiz/1", "root-key"))
+	assert.Equal(t, http.StatusNotFound, h.do("GET", "/orgs/biology/quiz/1", key("root-key"), "").Code)
+
+	// Quotas are per organization
+	rr = h.do("POST", "/quiz", key("ada-key"), `{"id": "2", "title": "Optics", "questions": [{"id": "q1", "text": "?", "options": ["a", "b"], "correct_option": 0, "marks": 1}]}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.JSONEq(t, `{"error": {"code": "forbidden", "message": "Organization quota exceeded"}}`, rr.Body.String())
+
+	// An organization's admins may do anything in it, and only in it
+	assert.Equal(t, http.StatusForbidden, h.do("DELETE", "/quiz/1", key("tom-key"), "").Code)
+	assert.Equal(t, http.StatusOK, h.do("GET", "/quiz/1/analytics/stats", key("grace-key"), "").Code)
+	assert.Equal(t, http.StatusForbidden, h.do("GET", "/orgs/chemistry/quiz/1/analytics/stats", key("grace-key"), "").Code)
+
+	// Results are kept per organization too
+	assert.Equal(t, http.StatusOK, h.do("POST", "/quiz/1/answer", key("tom-key"), `{"question_id": "q1", "selected_option": 1}`).Code)
+	assert.Equal(t, http.StatusOK, h.do("GET", "/orgs/physics/quiz/1/results/tom", key("root-key"), "").Code)
+	assert.Equal(t, http.StatusNotFound, h.do("GET", "/orgs/chemistry/quiz/1/results/tom", key("root-key"), "").Code)
+}
+
+func TestTenantRoutesWithoutAuth(t *testing.T) {
+	orgs, err := tenant.NewDirectory([]tenant.Org{{ID: "physics"}})
+	require.NoError(t, err)
+	store := storage.NewMemoryStorage()
+	h := &authHarness{store: store, router: routes.SetupRoutes(store, nil, orgs, nil, nil)}
+	body, _ := json.Marshal(sampleQuiz())
+
+	assert.Equal(t, http.StatusCreated, h.do("POST", "/orgs/physics/quiz", nil, string(body)).Code)
+	assert.Equal(t, http.StatusOK, h.do("GET", "/orgs/physics/quiz/1", nil, "").Code)
+	assert.Equal(t, http.StatusNotFound, h.do("GET", "/quiz/1", nil, "").Code)
+	assert.Equal(t, http.StatusNotFound, h.do("GET", "/orgs/chemistry/quiz", nil, "").Code)
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"quiz-app/internal/models"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+	"quiz-app/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fields(errs validation.Errors) []string {
+	var out []string
+	for _, fe := range errs {
+		out = append(out, fe.Field)
+	}
+	return out
+}
+
+func TestValidateQuiz(t *testing.T) {
+	t.Run("valid quiz", func(t *testing.T) {
+		assert.Nil(t, validation.ValidateQuiz(sampleQuiz()))
+		assert.Nil(t, validation.ValidateQuiz(pooledQuiz()))
+	})
+
+	t.Run("quiz fields", func(t *testing.T) {
+		opens := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+		closes := opens.Add(-time.Hour)
+		quiz := &models.Quiz{
+			Penalty:         0.5,
+			AnswerPolicy:    "random",
+			DurationSeconds: -1,
+			OpensAt:         &opens,
+			ClosesAt:        &closes,
+		}
+		assert.Equal(t, []string{"id", "penalty", "answer_policy", "duration_seconds", "closes_at"},
+			fields(validation.ValidateQuiz(quiz)))
+	})
+
+	t.Run("every question problem is reported by path", func(t *testing.T) {
+		quiz := sampleQuiz()
+		quiz.Questions[1].ID = "q1"
+		quiz.Questions[1].CorrectOption = 4
+		quiz.Questions[1].Marks = -1
+		quiz.Questions = append(quiz.Questions,
+			models.Question{ID: "q3", Text: "Pick", Kind: models.KindMultipleSelect,
+				Options: []string{"a", "b"}, CorrectOptions: []int{1, 1, 2}},
+			models.Question{ID: "q4", Text: "Pi?", Kind: models.KindNumeric},
+			models.Question{ID: "q5", Text: "Name", Kind: models.KindShortText,
+				TextRules: []models.TextRule{{Match: models.MatchRegex, Pattern: "("}}},
+		)
+
+		errs := validation.ValidateQuiz(quiz)
+		assert.Equal(t, []string{
+			"questions[1].id",
+			"questions[1].marks",
+			"questions[1].correct_option",
+			"questions[2].correct_options[1]",
+			"questions[2].correct_options[2]",
+			"questions[3].numeric",
+			"questions[4].text_rules[0].pattern",
+		}, fields(errs))
+		assert.Equal(t, "duplicates questions[0].id", errs[0].Message)
+	})
+
+	t.Run("draws must name a pool with enough questions", func(t *testing.T) {
+		quiz := pooledQuiz()
+		quiz.Draws = append(quiz.Draws, models.PoolDraw{Pool: "missing", Count: 1}, models.PoolDraw{Pool: quiz.Draws[0].Pool})
+		errs := validation.ValidateQuiz(quiz)
+		assert.Equal(t, []string{"draws[2].pool", "draws[3].pool", "draws[3].count"}, fields(errs))
+	})
+}
+
+func TestCreateQuizValidation(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+
+	body := `{"id":"v","penalty":0.5,"questions":[
+		{"id":"q1","text":"1+1?","options":["1","2"],"correct_option":2,"marks":1},
+		{"id":"q1","text":"2+2?","options":["3","4"],"correct_option":1,"marks":-2}]}`
+	req := httptest.NewRequest("POST", "/quiz", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var resp struct {
+		Error struct {
+			Code    string                  `json:"code"`
+			Details []validation.FieldError `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, "unprocessable_entity", resp.Error.Code)
+	assert.Equal(t, []validation.FieldError{
+		{Field: "penalty", Message: "requires is_negative_marking"},
+		{Field: "questions[0].correct_option", Message: "must be the index of an option (0 to 1)"},
+		{Field: "questions[1].id", Message: "duplicates questions[0].id"},
+		{Field: "questions[1].marks", Message: "must not be negative"},
+	}, resp.Error.Details)
+
+	_, err := store.GetQuiz(context.Background(), "v")
+	assert.ErrorIs(t, err, storage.ErrQuizNotFound)
+
+	t.Run("updates are validated too", func(t *testing.T) {
+		require.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+		req := httptest.NewRequest("PATCH", "/quiz/1", bytes.NewBufferString(`{"is_negative_marking": false}`))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"field":"penalty"`)
+	})
+}
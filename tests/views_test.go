@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"quiz-app/internal/models"
+	"quiz-app/internal/routes"
+	"quiz-app/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetQuiz_DoesNotMutateStoredQuiz(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.CreateQuiz(context.Background(), sampleQuiz()))
+	router := routes.SetupRoutes(store, nil, nil, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/quiz/1", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	stored, err := store.GetQuiz(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Questions[0].CorrectOption)
+	assert.Equal(t, 2, stored.Questions[0].Marks)
+
+	isCorrect, _, err := store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+	require.NoError(t, err)
+	assert.True(t, isCorrect)
+}
+
+func TestMemoryStorage_ReturnsDeepCopies(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	quiz := sampleQuiz()
+	require.NoError(t, store.CreateQuiz(context.Background(), quiz))
+
+	// Changing the caller's quiz after creation does not reach the store
+	quiz.Questions[0].Options[1] = "changed"
+
+	retrieved, _ := store.GetQuiz(context.Background(), "1")
+	retrieved.Questions[0].CorrectOption = 3
+	retrieved.Questions[1].Options[2] = "changed"
+
+	stored, _ := store.GetQuiz(context.Background(), "1")
+	assert.Equal(t, sampleQuiz().Questions[0].Options, stored.Questions[0].Options)
+	assert.Equal(t, 1, stored.Questions[0].CorrectOption)
+	assert.Equal(t, "4", stored.Questions[1].Options[2])
+
+	_, _, err := store.SubmitAnswer(context.Background(), "1", "user1", &models.Answer{QuestionID: "q1", SelectedOption: 1})
+	require.NoError(t, err)
+	result, _ := store.GetResults(context.Background(), "1", "user1")
+	delete(result.Answers, "q1")
+	result, _ = store.GetResults(context.Background(), "1", "user1")
+	assert.Len(t, result.Answers, 1)
+}
+
+func TestQuizViews(t *testing.T) {
+	quiz := sampleQuiz()
+	quiz.Questions[0].CorrectOption = 0
+	quiz.Questions = append(quiz.Questions, models.Question{
+		ID: "q3", Kind: models.KindNumeric, Text: "g?", Numeric: &models.NumericKey{Value: 9.81}, Marks: 1,
+	})
+
+	t.Run("taker view hides answer keys", func(t *testing.T) {
+		raw, _ := json.Marshal(models.NewTakerQuiz(quiz))
+		var view map[string]interface{}
+		json.Unmarshal(raw, &view)
+
+		questions := view["questions"].([]interface{})
+		first := questions[0].(map[string]interface{})
+		assert.Equal(t, "single_choice", first["kind"])
+		assert.NotContains(t, first, "correct_option")
+		assert.NotContains(t, first, "marks")
+		third := questions[2].(map[string]interface{})
+		assert.Equal(t, "numeric", third["kind"])
+		assert.NotContains(t, third, "numeric")
+	})
+
+	t.Run("taker view shows marks when allowed", func(t *testing.T) {
+		shown := quiz.Clone()
+		shown.ShowMarks = true
+		view := models.NewTakerQuiz(shown)
+		require.NotNil(t, view.Questions[1].Marks)
+		assert.Equal(t, 3, *view.Questions[1].Marks)
+	})
+
+	t.Run("author view keeps option zero", func(t *testing.T) {
+		raw, _ := json.Marshal(models.NewAuthorQuiz(quiz))
+		var view map[string]interface{}
+		json.Unmarshal(raw, &view)
+
+		assert.Equal(t, 6.0, view["total_marks"])
+		first := view["questions"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, 0.0, first["correct_option"])
+	})
+
+	t.Run("views share nothing with the quiz", func(t *testing.T) {
+		view := models.NewTakerQuiz(quiz)
+		view.Questions[0].Options[0] = "changed"
+		author := models.NewAuthorQuiz(quiz)
+		author.Questions[1].Options[0] = "changed"
+		assert.Equal(t, sampleQuiz().Questions[0].Options[0], quiz.Questions[0].Options[0])
+		assert.Equal(t, sampleQuiz().Questions[1].Options[0], quiz.Questions[1].Options[0])
+	})
+}